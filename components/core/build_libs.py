@@ -23,6 +23,9 @@ def main():
                       help="Path to library output",
                       required=True)
   parser.add_argument("--depfile", help="Path to write depfile", required=False)
+  parser.add_argument("--fixture",
+                      help="Build with the fixture tag to read BEACON_FIXTURE",
+                      action="store_true")
 
   args = parser.parse_args()
 
@@ -95,7 +98,7 @@ def call(command, env):
     raise e
 
 # Builds this project must be called after build_hsk
-def build_core(out, env):
+def build_core(out, env, fixture=False):
   goBin = 'go'
 
   # We use MSYS on windows to compile CGO code
@@ -108,6 +111,8 @@ def build_core(out, env):
                # https://github.com/golang/go/issues/6940#issuecomment-66089199
                '-ldflags', '-extldflags=-fno-PIC',
                '-trimpath','-buildmode=c-shared', '-o', out]
+  if fixture:
+    call_args += ['-tags', 'fixture']
    
   if sys.platform == 'win32':
     call(call_args, env)
@@ -186,7 +191,7 @@ def run_build(args):
 
   # Building this project  
   os.chdir(this_dir)
-  build_core(args.output, env)
+  build_core(args.output, env, args.fixture)
  
 
 if __name__ == '__main__':
@@ -9,10 +9,17 @@ typedef const char cchar_t;
 import "C"
 import (
 	"log"
+	"sync"
 
 	"github.com/imperviousinc/beacon/components/core/internal"
 )
 
+// the launched service to flush on shutdown
+var (
+	apiMu sync.Mutex
+	api   *internal.Config
+)
+
 //export BeaconHelper_Launch
 //goland:noinspection GoSnakeCaseUsage
 func BeaconHelper_Launch() C.int32_t {
@@ -20,6 +27,11 @@ func BeaconHelper_Launch() C.int32_t {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	apiMu.Lock()
+	api = a
+	apiMu.Unlock()
+
 	a.Launch()
 	return C.int32_t(0)
 }
@@ -28,6 +40,12 @@ func BeaconHelper_Launch() C.int32_t {
 //goland:noinspection GoSnakeCaseUsage
 func BeaconHelper_Shutdown() {
 	log.Println("shutdown called this is cgo")
+
+	apiMu.Lock()
+	defer apiMu.Unlock()
+	if api != nil {
+		api.Flush()
+	}
 }
 
 // TODO: add cert verification bindings here so it can be exposed via Mojo interface
@@ -3,7 +3,9 @@ package content
 import (
 	"embed"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 )
 
 var (
@@ -18,11 +20,26 @@ type HandshakeStatus struct {
 	Urkel       string `json:"urkel"`
 	Synced      bool   `json:"synced"`
 	Progress    int    `json:"progress"`
+
+	// PinEvents recent TLSA sets that were replaced at once
+	PinEvents []PinEvent `json:"pinEvents,omitempty"`
+}
+
+// PinEvent a TLSA set of a service was replaced
+type PinEvent struct {
+	Name     string    `json:"name"`
+	Previous []string  `json:"previous"`
+	Current  []string  `json:"current"`
+	Time     time.Time `json:"time"`
 }
 
 type Config struct {
 	GetHandshakeStatus func() *HandshakeStatus
 	Port               string
+
+	// Updates signals every change of the status until cancel
+	// is called. The events stream is only served if it's set.
+	Updates func() (updates <-chan struct{}, cancel func())
 }
 
 func NewContent(port string, handler func() *HandshakeStatus) *Config {
@@ -30,8 +47,14 @@ func NewContent(port string, handler func() *HandshakeStatus) *Config {
 }
 
 func (c *Config) Serve() error {
+	return http.ListenAndServe("127.0.0.1:"+c.Port, c.Handler())
+}
+
+// Handler serves the pages and the status
+func (c *Config) Handler() http.Handler {
+	mux := http.NewServeMux()
 	fs := http.FileServer(http.FS(resources))
-	http.Handle("/resources/info.json", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	mux.Handle("/resources/info.json", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Content-Security-Policy",
 			"frame-ancestors chrome://welcome chrome://hns-internals;")
@@ -47,11 +70,49 @@ func (c *Config) Serve() error {
 		w.Write(resp)
 	}))
 
-	http.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	// pushes the status to the pages as server-sent events
+	mux.Handle("/resources/events", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok || c.Updates == nil {
+			http.NotFound(w, req)
+			return
+		}
+
+		updates, cancel := c.Updates()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Security-Policy",
+			"frame-ancestors chrome://welcome chrome://hns-internals;")
+		w.WriteHeader(200)
+
+		for {
+			resp, err := json.Marshal(c.GetHandshakeStatus())
+			if err != nil {
+				return
+			}
+			if _, err = fmt.Fprintf(w, "data: %s\n\n", resp); err != nil {
+				return
+			}
+			flusher.Flush()
+
+			select {
+			case <-req.Context().Done():
+				return
+			case _, ok := <-updates:
+				if !ok {
+					return
+				}
+			}
+		}
+	}))
+
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Security-Policy",
 			"frame-ancestors chrome://welcome chrome://hns-internals;")
 		fs.ServeHTTP(w, req)
 	}))
 
-	return http.ListenAndServe("127.0.0.1:"+c.Port, nil)
+	return mux
 }
@@ -3,10 +3,13 @@ package internal
 import (
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/imperviousinc/beacon/components/core/internal/content"
@@ -21,27 +24,139 @@ const (
 	resourcesEndpoint    = "44962"
 )
 
+// defaultUpstreams handshake aware resolvers in order
+// of preference. They're only trusted to transport
+// records since every answer is validated locally.
+var defaultUpstreams = []string{
+	"https://hs.dnssec.dev/dns-query",
+	"https://query.hdns.io/dns-query",
+}
+
+// defaultCertPolicy rules for certificates of handshake hosts
+// authenticated by TLSA records. ICANN hosts already passed
+// WebPKI verification. Validity isn't limited since DANE-EE
+// certificates are often self signed and long lived.
+var defaultCertPolicy = &hnsquery.CertPolicy{
+	AppliesTo: func(host string) bool {
+		return !isICANN(host)
+	},
+	RestrictNames:   true,
+	MinECDSAKeySize: 256,
+}
+
+// defaultMaxAnchorAge how long after they were fetched TLD zones
+// are served while they're fetched again, including zones cached
+// on disk before the node synced
+const defaultMaxAnchorAge = 24 * time.Hour
+
+// HandshakeNode a Handshake light client serving TLD zones.
+// It's an hnsquery.Client or a FixtureNode in tests.
+type HandshakeNode interface {
+	ZoneQuery
+	Run() error
+	Ready() bool
+	Progress() float32
+	PeerCount() int
+	ActivePeerCount() int
+	Subscribe() (events <-chan hnsquery.Event, cancel func())
+}
+
+// Options override the defaults of the service
+type Options struct {
+	// Node serves TLD zones instead of a new hnsquery.Client
+	Node HandshakeNode
+
+	// Upstreams replace defaultUpstreams if set
+	Upstreams []string
+
+	// CacheDir replaces the service cache directory if set
+	CacheDir string
+
+	// MaxAnchorAge replaces defaultMaxAnchorAge if set
+	MaxAnchorAge time.Duration
+
+	// TrustServicePort and ResourcesPort replace the default ports
+	TrustServicePort string
+	ResourcesPort    string
+}
+
 type Config struct {
-	hsq      *hnsquery.Client
+	hsq      HandshakeNode
 	verifier *hnsquery.DNSCertVerifier
 	server   *grpc.Server
+	tldCache *tldDiskCache
+
+	trustServicePort string
+	resourcesPort    string
+
+	// pin replacements pushed to status subscribers
+	pinMu   sync.Mutex
+	pinSubs map[chan struct{}]struct{}
 }
 
+// NewAPI creates the service backed by the Handshake network. Builds
+// with the fixture tag use the fixture file named by BEACON_FIXTURE
+// instead if it's set.
 func NewAPI() (*Config, error) {
+	opts, err := fixtureOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAPIWithOptions(opts)
+}
+
+func NewAPIWithOptions(opts *Options) (*Config, error) {
 	var err error
-	c := &Config{}
+	if opts == nil {
+		opts = &Options{}
+	}
+	c := &Config{
+		trustServicePort: opts.TrustServicePort,
+		resourcesPort:    opts.ResourcesPort,
+	}
+	if c.trustServicePort == "" {
+		c.trustServicePort = trustServiceEndpoint
+	}
+	if c.resourcesPort == "" {
+		c.resourcesPort = resourcesEndpoint
+	}
+
+	cacheDir := opts.CacheDir
+	if cacheDir == "" {
+		if cacheDir, err = serviceCacheDir(); err != nil {
+			return nil, err
+		}
+	}
 
 	// create hsq client which is a libhsk binding
-	if c.hsq, err = NewHNSQueryClient(); err != nil {
-		return nil, err
+	if c.hsq = opts.Node; c.hsq == nil {
+		if c.hsq, err = NewHNSQueryClient(cacheDir); err != nil {
+			return nil, err
+		}
+	}
+
+	upstreams := opts.Upstreams
+	if len(upstreams) == 0 {
+		upstreams = defaultUpstreams
+	}
+
+	maxAnchorAge := opts.MaxAnchorAge
+	if maxAnchorAge == 0 {
+		maxAnchorAge = defaultMaxAnchorAge
 	}
 
 	// create a cert verifier which is a stub dnssec validating
 	// resolver that uses hsq as a trust anchor
-	if c.verifier, err = NewCertVerifier("https://hs.dnssec.dev/dns-query", c.hsq); err != nil {
+	c.tldCache = openTLDDiskCache(filepath.Join(cacheDir, tldDiskCacheFile), maxAnchorAge)
+	if c.verifier, err = newCertVerifier(upstreams, c.hsq, c.tldCache, maxAnchorAge); err != nil {
 		return nil, err
 	}
 
+	// remember TLSA sets to report ones replaced at once
+	c.verifier.Pins = hnsquery.OpenPinHistory(filepath.Join(cacheDir, "tlsa_pins.json"))
+	c.verifier.Pins.OnReplaced = c.pinReplaced
+
 	c.server = NewGRPCCertVerifierServer(c)
 	return c, nil
 }
@@ -55,11 +170,12 @@ func (c *Config) Launch() {
 	}
 
 	go hsqLaunch()
+	go c.watchNode()
 	pages := NewContentPages(c)
 	go pages.Serve()
 
 	// TODO: replace with sockets
-	listen, err := net.Listen("tcp", "127.0.0.1:"+trustServiceEndpoint)
+	listen, err := net.Listen("tcp", "127.0.0.1:"+c.trustServicePort)
 	if err != nil {
 		panic(err)
 	}
@@ -69,10 +185,98 @@ func (c *Config) Launch() {
 	}
 }
 
-func NewHNSQueryClient() (*hnsquery.Client, error) {
+// Flush writes the TLD cache and pin history
+// changes that are kept to be written in the background
+func (c *Config) Flush() {
+	if err := c.tldCache.flush(); err != nil {
+		log.Printf("disk cache: %v", err)
+	}
+	if err := c.verifier.Pins.Flush(); err != nil {
+		log.Printf("pin history: %v", err)
+	}
+}
+
+// watchNode logs changes of the node and keeps the
+// anchor tag of the resolver current until it stops
+func (c *Config) watchNode() {
+	events, cancel := c.hsq.Subscribe()
+	defer cancel()
+
+	// the root may have changed before subscribing
+	c.verifier.Resolver.SetAnchorTag(hex.EncodeToString(c.hsq.NameRoot()))
+
+	for e := range events {
+		switch e.Type {
+		case hnsquery.EventSynced:
+			log.Printf("synced at height %d", e.State.Height)
+		case hnsquery.EventNameRootChanged:
+			log.Printf("name root changed to %x at height %d", e.State.NameRoot, e.State.Height)
+			c.verifier.Resolver.SetAnchorTag(hex.EncodeToString(e.State.NameRoot[:]))
+		case hnsquery.EventError:
+			log.Printf("handshake node stopped: %v", e.Err)
+		}
+	}
+}
+
+// statusUpdates signals the content pages once for any number
+// of node events they didn't pick up yet
+func (c *Config) statusUpdates() (<-chan struct{}, func()) {
+	events, cancel := c.hsq.Subscribe()
+	updates := make(chan struct{}, 1)
+	pins := make(chan struct{}, 1)
+
+	c.pinMu.Lock()
+	if c.pinSubs == nil {
+		c.pinSubs = make(map[chan struct{}]struct{})
+	}
+	c.pinSubs[pins] = struct{}{}
+	c.pinMu.Unlock()
+
+	go func() {
+		defer close(updates)
+		defer func() {
+			c.pinMu.Lock()
+			delete(c.pinSubs, pins)
+			c.pinMu.Unlock()
+		}()
+
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+			case <-pins:
+			}
+
+			select {
+			case updates <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return updates, cancel
+}
+
+// pinReplaced notifies status subscribers of a replaced TLSA set
+func (c *Config) pinReplaced(hnsquery.PinEvent) {
+	c.pinMu.Lock()
+	defer c.pinMu.Unlock()
+
+	for sub := range c.pinSubs {
+		select {
+		case sub <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// serviceCacheDir the directory the service caches are kept in
+func serviceCacheDir() (string, error) {
 	cacheDir, err := os.UserConfigDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed getting cache dir: %v", err)
+		return "", fmt.Errorf("failed getting cache dir: %v", err)
 	}
 
 	if runtime.GOOS == "windows" {
@@ -82,9 +286,13 @@ func NewHNSQueryClient() (*hnsquery.Client, error) {
 	}
 
 	if err = os.MkdirAll(cacheDir, 0700); err != nil {
-		return nil, fmt.Errorf("failed making cache dir `%s`: %v", cacheDir, err)
+		return "", fmt.Errorf("failed making cache dir `%s`: %v", cacheDir, err)
 	}
 
+	return cacheDir, nil
+}
+
+func NewHNSQueryClient(cacheDir string) (*hnsquery.Client, error) {
 	client, err := hnsquery.NewClient(&hnsquery.Config{DataDir: cacheDir})
 	if err != nil {
 		return nil, fmt.Errorf("failed creating new hnsquery instance: %v", err)
@@ -93,14 +301,24 @@ func NewHNSQueryClient() (*hnsquery.Client, error) {
 	return client, nil
 }
 
-func NewCertVerifier(dohURL string, q ZoneQuery) (*hnsquery.DNSCertVerifier, error) {
+// NewCertVerifier TLD zones are kept in cacheDir to serve them
+// before q synced if they're no older than maxAnchorAge
+func NewCertVerifier(upstreams []string, q ZoneQuery, cacheDir string, maxAnchorAge time.Duration) (*hnsquery.DNSCertVerifier, error) {
+	disk := openTLDDiskCache(filepath.Join(cacheDir, tldDiskCacheFile), maxAnchorAge)
+	return newCertVerifier(upstreams, q, disk, maxAnchorAge)
+}
+
+func newCertVerifier(upstreams []string, q ZoneQuery, disk *tldDiskCache, maxAnchorAge time.Duration) (*hnsquery.DNSCertVerifier, error) {
 	h := &RootZoneConfig{}
 	h.client = q
+	h.maxAnchorAge = maxAnchorAge
+	h.tldDiskCache = disk
 
 	var resolver *hnsquery.Resolver
 	var err error
 	if resolver, err = hnsquery.NewResolver(&hnsquery.ResolverConfig{
-		Forward: dohURL,
+		Upstreams: upstreams,
+		Strategy:  hnsquery.StrategyFailover,
 	}); err != nil {
 		return nil, err
 	}
@@ -111,9 +329,14 @@ func NewCertVerifier(dohURL string, q ZoneQuery) (*hnsquery.DNSCertVerifier, err
 
 	var certVerify *hnsquery.DNSCertVerifier
 	resolver.TrustAnchorPointHandler = getPowTrustAnchor(h)
+	resolver.SetAnchorTag(nameRoot(h))
+	h.refreshed = func(name string) {
+		resolver.RefreshAnchor(name)
+	}
 	if certVerify, err = hnsquery.NewDNSCertVerifier(resolver); err != nil {
 		return nil, err
 	}
+	certVerify.Policy = defaultCertPolicy
 
 	return certVerify, nil
 }
@@ -125,8 +348,18 @@ func NewGRPCCertVerifierServer(c *Config) *grpc.Server {
 }
 
 func NewContentPages(c *Config) *content.Config {
-	return content.NewContent(resourcesEndpoint, func() *content.HandshakeStatus {
+	pages := content.NewContent(c.resourcesPort, func() *content.HandshakeStatus {
 		root := c.hsq.NameRoot()
+		var pinEvents []content.PinEvent
+		for _, event := range c.verifier.Pins.Events() {
+			pinEvents = append(pinEvents, content.PinEvent{
+				Name:     event.Name,
+				Previous: event.Previous,
+				Current:  event.Current,
+				Time:     event.Time,
+			})
+		}
+
 		return &content.HandshakeStatus{
 			TotalPeers:  c.hsq.PeerCount(),
 			ActivePeers: c.hsq.ActivePeerCount(),
@@ -134,6 +367,10 @@ func NewContentPages(c *Config) *content.Config {
 			Urkel:       hex.EncodeToString(root[:]),
 			Synced:      c.hsq.Ready(),
 			Progress:    int(c.hsq.Progress() * 100),
+			PinEvents:   pinEvents,
 		}
 	})
+
+	pages.Updates = c.statusUpdates
+	return pages
 }
@@ -0,0 +1,366 @@
+package internal
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imperviousinc/hnsquery"
+	"github.com/miekg/dns"
+)
+
+// Fixture zones and network conditions served by a FixtureNode
+type Fixture struct {
+	// Zones records of each TLD in presentation format
+	// including any glue records
+	Zones map[string][]string `json:"zones"`
+
+	Height      uint64 `json:"height"`
+	NameRoot    string `json:"nameRoot"`
+	Peers       int    `json:"peers"`
+	ActivePeers int    `json:"activePeers"`
+
+	// SyncTime how long the node takes to sync after Run.
+	// Zones can't be queried before that.
+	SyncTime duration `json:"syncTime"`
+
+	// Latency added to each zone query
+	Latency duration `json:"latency"`
+
+	// Timeouts TLDs whose queries time out
+	Timeouts []string `json:"timeouts"`
+
+	// Upstreams resolvers used instead of the defaults
+	Upstreams []string `json:"upstreams"`
+
+	// CacheDir used instead of the service cache directory
+	CacheDir string `json:"cacheDir"`
+
+	// MaxAnchorAge how long cached TLD zones are served
+	// while they're fetched again
+	MaxAnchorAge duration `json:"maxAnchorAge"`
+
+	TrustServicePort string `json:"trustServicePort"`
+	ResourcesPort    string `json:"resourcesPort"`
+}
+
+// duration a time.Duration decoded from strings like "1.5s"
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+	return nil
+}
+
+// LoadFixture reads a JSON fixture or a zone file. Records of a zone
+// file are grouped by TLD and served by a synced node with one peer.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading fixture: %v", err)
+	}
+
+	if filepath.Ext(path) == ".json" {
+		f := &Fixture{}
+		if err = json.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("failed decoding fixture: %v", err)
+		}
+		return f, nil
+	}
+
+	f := &Fixture{Zones: make(map[string][]string), Peers: 1, ActivePeers: 1}
+	zp := dns.NewZoneParser(strings.NewReader(string(data)), ".", path)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		labels := dns.SplitDomainName(rr.Header().Name)
+		if len(labels) == 0 {
+			continue
+		}
+
+		tld := strings.ToLower(labels[len(labels)-1])
+		f.Zones[tld] = append(f.Zones[tld], rr.String())
+	}
+	if err = zp.Err(); err != nil {
+		return nil, fmt.Errorf("failed parsing fixture: %v", err)
+	}
+
+	return f, nil
+}
+
+// FixtureNode an in-memory Handshake node serving zones from a
+// Fixture. It simulates syncing and peer failures with the same
+// errors as hnsquery.Client so the service can run without
+// libhsk or the p2p network.
+type FixtureNode struct {
+	fixture  *Fixture
+	zones    map[string][]dns.RR
+	timeouts map[string]bool
+	root     []byte
+
+	mu          sync.RWMutex
+	start       time.Time
+	activePeers int
+	done        chan struct{}
+
+	events hnsquery.EventFeed
+}
+
+// NewFixtureNode parses the records of f
+func NewFixtureNode(f *Fixture) (*FixtureNode, error) {
+	n := &FixtureNode{
+		fixture:     f,
+		zones:       make(map[string][]dns.RR),
+		timeouts:    make(map[string]bool),
+		root:        make([]byte, 32),
+		activePeers: f.ActivePeers,
+		done:        make(chan struct{}),
+	}
+
+	for tld, records := range f.Zones {
+		rrs, err := parseFixtureZone(tld, records)
+		if err != nil {
+			return nil, err
+		}
+		n.zones[strings.ToLower(strings.TrimSuffix(tld, "."))] = rrs
+	}
+
+	for _, tld := range f.Timeouts {
+		n.timeouts[strings.ToLower(strings.TrimSuffix(tld, "."))] = true
+	}
+
+	if f.NameRoot != "" {
+		root, err := hex.DecodeString(f.NameRoot)
+		if err != nil || len(root) != 32 {
+			return nil, fmt.Errorf("bad name root %q", f.NameRoot)
+		}
+		n.root = root
+	}
+
+	return n, nil
+}
+
+func parseFixtureZone(tld string, records []string) ([]dns.RR, error) {
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return nil, fmt.Errorf("bad record for %s: %v", tld, err)
+		}
+		if rr == nil {
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+
+	return rrs, nil
+}
+
+// Run starts syncing and blocks until Destroy is called
+func (n *FixtureNode) Run() error {
+	n.mu.Lock()
+	n.start = time.Now()
+	n.mu.Unlock()
+
+	n.update()
+	defer n.events.Close()
+
+	synced := time.NewTimer(time.Duration(n.fixture.SyncTime))
+	defer synced.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return nil
+		case <-synced.C:
+			n.update()
+		}
+	}
+}
+
+// Destroy stops Run
+func (n *FixtureNode) Destroy() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	select {
+	case <-n.done:
+	default:
+		close(n.done)
+	}
+	return nil
+}
+
+// SetActivePeers changes the number of connected peers.
+// Zones can't be queried without any.
+func (n *FixtureNode) SetActivePeers(peers int) {
+	n.mu.Lock()
+	n.activePeers = peers
+	n.mu.Unlock()
+
+	n.update()
+}
+
+// SetZone replaces the records of tld like a new tree would.
+// Caches only notice once SetNameRoot commits it.
+func (n *FixtureNode) SetZone(tld string, records []string) error {
+	rrs, err := parseFixtureZone(tld, records)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.zones[strings.ToLower(strings.TrimSuffix(tld, "."))] = rrs
+	n.mu.Unlock()
+	return nil
+}
+
+// SetNameRoot commits a new name root
+func (n *FixtureNode) SetNameRoot(root []byte) {
+	n.mu.Lock()
+	n.root = append([]byte{}, root...)
+	n.mu.Unlock()
+
+	n.update()
+}
+
+// Subscribe is like hnsquery.Client.Subscribe
+func (n *FixtureNode) Subscribe() (<-chan hnsquery.Event, func()) {
+	return n.events.Subscribe()
+}
+
+// update publishes the current state to subscribers
+func (n *FixtureNode) update() {
+	state := hnsquery.NodeState{
+		Ready:       n.Ready(),
+		Progress:    n.Progress(),
+		Height:      n.Height(),
+		TotalPeers:  n.PeerCount(),
+		ActivePeers: n.ActivePeerCount(),
+	}
+	copy(state.NameRoot[:], n.NameRoot())
+
+	n.events.Update(state)
+}
+
+func (n *FixtureNode) GetZone(ctx context.Context, name string) ([]dns.RR, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if !n.Ready() {
+		return nil, fmt.Errorf("failed resolving zone %s: %w", name, hnsquery.ErrNotSynced)
+	}
+	if n.ActivePeerCount() == 0 {
+		return nil, fmt.Errorf("failed resolving zone %s: %w", name, hnsquery.ErrNoPeers)
+	}
+
+	if latency := time.Duration(n.fixture.Latency); latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed resolving zone %s: %w", name, hnsquery.ErrCancelled)
+		case <-timer.C:
+		}
+	}
+
+	if n.timeouts[name] {
+		return nil, fmt.Errorf("failed resolving zone %s: %w", name, hnsquery.ErrTimeout)
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	var rrs []dns.RR
+	for _, rr := range n.zones[name] {
+		rrs = append(rrs, dns.Copy(rr))
+	}
+
+	return rrs, nil
+}
+
+func (n *FixtureNode) Ready() bool {
+	return n.Progress() >= 1
+}
+
+// Progress grows from 0 to 1 over SyncTime once Run is called
+func (n *FixtureNode) Progress() float32 {
+	syncTime := time.Duration(n.fixture.SyncTime)
+	if syncTime <= 0 {
+		return 1
+	}
+
+	n.mu.RLock()
+	start := n.start
+	n.mu.RUnlock()
+
+	if start.IsZero() {
+		return 0
+	}
+	if progress := float32(time.Since(start)) / float32(syncTime); progress < 1 {
+		return progress
+	}
+
+	return 1
+}
+
+func (n *FixtureNode) Height() uint64 {
+	return uint64(float64(n.fixture.Height) * float64(n.Progress()))
+}
+
+func (n *FixtureNode) PeerCount() int {
+	return n.fixture.Peers
+}
+
+func (n *FixtureNode) ActivePeerCount() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.activePeers
+}
+
+func (n *FixtureNode) NameRoot() []byte {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return append([]byte{}, n.root...)
+}
+
+// FixtureOptions the options to run the service
+// with the fixture stored at path
+func FixtureOptions(path string) (*Options, error) {
+	f, err := LoadFixture(path)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := NewFixtureNode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("serving zones from fixture %s", path)
+	return &Options{
+		Node:             node,
+		Upstreams:        f.Upstreams,
+		CacheDir:         f.CacheDir,
+		MaxAnchorAge:     time.Duration(f.MaxAnchorAge),
+		TrustServicePort: f.TrustServicePort,
+		ResourcesPort:    f.ResourcesPort,
+	}, nil
+}
@@ -0,0 +1,21 @@
+//go:build fixture
+
+package internal
+
+import "os"
+
+// fixtureEnv path to a fixture file. If set the service
+// serves zones from it instead of the Handshake network.
+// It's only read by builds with the fixture tag.
+const fixtureEnv = "BEACON_FIXTURE"
+
+// fixtureOptions the options to run the service with the
+// fixture named by the environment if there's one
+func fixtureOptions() (*Options, error) {
+	path := os.Getenv(fixtureEnv)
+	if path == "" {
+		return nil, nil
+	}
+
+	return FixtureOptions(path)
+}
@@ -0,0 +1,9 @@
+//go:build !fixture
+
+package internal
+
+// fixtureOptions release builds always use the Handshake
+// network, build with the fixture tag to read BEACON_FIXTURE
+func fixtureOptions() (*Options, error) {
+	return nil, nil
+}
@@ -0,0 +1,591 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imperviousinc/beacon/components/core/internal/content"
+	"github.com/imperviousinc/beacon/components/core/public/proto"
+	"github.com/imperviousinc/hnsquery"
+	"github.com/miekg/dns"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestFixtureNode(t *testing.T) {
+	node, err := NewFixtureNode(&Fixture{
+		Zones: map[string][]string{
+			"example": {"example. 3600 IN NS ns1.example.", "ns1.example. 3600 IN A 127.0.0.1"},
+		},
+		Height:      100,
+		Peers:       8,
+		ActivePeers: 2,
+		SyncTime:    duration(time.Hour),
+		Timeouts:    []string{"slow"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err = node.GetZone(ctx, "example"); !errors.Is(err, hnsquery.ErrNotSynced) {
+		t.Fatalf("got %v, want %v", err, hnsquery.ErrNotSynced)
+	}
+	if node.Ready() || node.Progress() != 0 || node.Height() != 0 {
+		t.Fatal("node shouldn't sync before Run")
+	}
+
+	// sync at once
+	node.fixture.SyncTime = 0
+	if !node.Ready() || node.Height() != 100 {
+		t.Fatalf("got height %d, want synced at 100", node.Height())
+	}
+
+	rrs, err := node.GetZone(ctx, "Example.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rrs) != 2 {
+		t.Fatalf("got %d records, want 2", len(rrs))
+	}
+
+	if rrs, err = node.GetZone(ctx, "unknown"); err != nil || len(rrs) != 0 {
+		t.Fatalf("got %v, %v, want no records", rrs, err)
+	}
+
+	if _, err = node.GetZone(ctx, "slow"); !errors.Is(err, hnsquery.ErrTimeout) {
+		t.Fatalf("got %v, want %v", err, hnsquery.ErrTimeout)
+	}
+
+	node.SetActivePeers(0)
+	if _, err = node.GetZone(ctx, "example"); !errors.Is(err, hnsquery.ErrNoPeers) {
+		t.Fatalf("got %v, want %v", err, hnsquery.ErrNoPeers)
+	}
+	node.SetActivePeers(2)
+
+	node.fixture.Latency = duration(time.Hour)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = node.GetZone(cancelled, "example"); !errors.Is(err, hnsquery.ErrCancelled) {
+		t.Fatalf("got %v, want %v", err, hnsquery.ErrCancelled)
+	}
+
+	done := make(chan error)
+	go func() { done <- node.Run() }()
+	node.Destroy()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFixtureNode_Subscribe(t *testing.T) {
+	node, err := NewFixtureNode(&Fixture{
+		Height:      100,
+		Peers:       8,
+		ActivePeers: 2,
+		SyncTime:    duration(50 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, cancel := node.Subscribe()
+	defer cancel()
+
+	next := func(want hnsquery.EventType) hnsquery.Event {
+		select {
+		case e := <-events:
+			if e.Type != want {
+				t.Fatalf("got event %s, want %s", e.Type, want)
+			}
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+		return hnsquery.Event{}
+	}
+
+	done := make(chan error)
+	go func() { done <- node.Run() }()
+
+	next(hnsquery.EventPeerConnected)
+	next(hnsquery.EventNewHeight)
+	// the height may move once more while syncing
+	for e := range events {
+		if e.Type == hnsquery.EventSynced {
+			if e.State.Height != 100 {
+				t.Fatalf("got height %d, want 100", e.State.Height)
+			}
+			break
+		}
+	}
+
+	node.SetNameRoot(bytes.Repeat([]byte{0xaa}, 32))
+	if e := next(hnsquery.EventNameRootChanged); e.State.NameRoot[0] != 0xaa {
+		t.Fatalf("got root %x", e.State.NameRoot)
+	}
+
+	node.SetActivePeers(0)
+	next(hnsquery.EventPeerDisconnected)
+
+	node.Destroy()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("events should be closed when the node stops")
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	dir := t.TempDir()
+
+	zonePath := filepath.Join(dir, "zones.db")
+	if err := ioutil.WriteFile(zonePath, []byte(
+		"example. 3600 IN NS ns1.example.\n"+
+			"ns1.example. 3600 IN A 127.0.0.1\n"+
+			"other. 3600 IN TXT \"hello\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LoadFixture(zonePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Zones["example"]) != 2 || len(f.Zones["other"]) != 1 || f.ActivePeers != 1 {
+		t.Fatalf("got %+v", f)
+	}
+
+	jsonPath := filepath.Join(dir, "fixture.json")
+	if err = ioutil.WriteFile(jsonPath, []byte(`{
+		"zones": {"example": ["example. 3600 IN NS ns1.example."]},
+		"height": 5,
+		"syncTime": "1.5s",
+		"timeouts": ["slow"]
+	}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if f, err = LoadFixture(jsonPath); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(f.SyncTime) != 1500*time.Millisecond || f.Height != 5 || f.Timeouts[0] != "slow" {
+		t.Fatalf("got %+v", f)
+	}
+
+	if err = ioutil.WriteFile(jsonPath, []byte(`{"syncTime": "soon"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadFixture(jsonPath); err == nil {
+		t.Fatal("want error for a bad duration")
+	}
+}
+
+// testSignedZone serves a signed zone over udp
+type testSignedZone struct {
+	t    *testing.T
+	zone string
+
+	mu   sync.Mutex
+	key  *dns.DNSKEY
+	priv crypto.Signer
+	rrs  map[string][]dns.RR
+}
+
+func newTestSignedZone(t *testing.T, zone string) *testSignedZone {
+	z := &testSignedZone{t: t, zone: zone}
+	z.rotate()
+	return z
+}
+
+// rotate replaces the key and removes all records
+func (z *testSignedZone) rotate() {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: z.zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		z.t.Fatal(err)
+	}
+
+	z.mu.Lock()
+	z.key, z.priv, z.rrs = key, priv.(crypto.Signer), make(map[string][]dns.RR)
+	z.mu.Unlock()
+
+	z.add(key)
+}
+
+// ds the DS record of the current key
+func (z *testSignedZone) ds() string {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	return z.key.ToDS(dns.SHA256).String()
+}
+
+// add signs rrs and serves them for their name and type
+func (z *testSignedZone) add(rrs ...dns.RR) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	now := time.Now()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrs[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 300},
+		Algorithm:  z.key.Algorithm,
+		SignerName: z.zone,
+		KeyTag:     z.key.KeyTag(),
+		Inception:  uint32(now.Add(-time.Hour).Unix()),
+		Expiration: uint32(now.Add(time.Hour).Unix()),
+	}
+	if err := sig.Sign(z.priv, rrs); err != nil {
+		z.t.Fatal(err)
+	}
+
+	k := rrs[0].Header().Name + dns.TypeToString[rrs[0].Header().Rrtype]
+	z.rrs[k] = append(rrs, sig)
+}
+
+// addTLSA pins the key of cert for HTTPS
+func (z *testSignedZone) addTLSA(cert *x509.Certificate) {
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	z.add(&dns.TLSA{
+		Hdr:          dns.RR_Header{Name: "_443._tcp." + z.zone, Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300},
+		Usage:        3,
+		Selector:     1,
+		MatchingType: 1,
+		Certificate:  hex.EncodeToString(spki[:]),
+	})
+}
+
+// serve answers queries on a local udp port until the test ends
+func (z *testSignedZone) serve() string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		z.t.Fatal(err)
+	}
+
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		re := new(dns.Msg)
+		re.SetReply(req)
+		q := req.Question[0]
+
+		z.mu.Lock()
+		re.Answer = z.rrs[dns.CanonicalName(q.Name)+dns.TypeToString[q.Qtype]]
+		z.mu.Unlock()
+
+		w.WriteMsg(re)
+	})}
+	go server.ActivateAndServe()
+	z.t.Cleanup(func() { server.Shutdown() })
+
+	return "udp://" + pc.LocalAddr().String()
+}
+
+func testSelfSignedCert(t *testing.T, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// startFixtureService runs the trust service and its gRPC server
+// with the TLD of zone served from a fixture. Fields replace
+// those of the default fixture.
+func startFixtureService(t *testing.T, zone *testSignedZone, fields map[string]interface{}) (*Config, *FixtureNode, proto.CertVerifierClient) {
+	tld := strings.TrimSuffix(zone.zone, ".")
+	fixture := map[string]interface{}{
+		"zones":       map[string][]string{tld: {zone.ds()}},
+		"height":      120000,
+		"peers":       8,
+		"activePeers": 4,
+		"upstreams":   []string{zone.serve()},
+		"cacheDir":    t.TempDir(),
+	}
+	for k, v := range fields {
+		fixture[k] = v
+	}
+
+	data, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := FixtureOptions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewAPIWithOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the caches are written in the background
+	t.Cleanup(c.Flush)
+	node, ok := c.hsq.(*FixtureNode)
+	if !ok {
+		t.Fatalf("got node %T, want fixture", c.hsq)
+	}
+	go node.Run()
+	go c.watchNode()
+	t.Cleanup(func() { node.Destroy() })
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go c.server.Serve(listen)
+	t.Cleanup(c.server.Stop)
+
+	conn, err := grpc.Dial(listen.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return c, node, proto.NewCertVerifierClient(conn)
+}
+
+// TestFixture_Service runs the trust service, its gRPC server
+// and the content pages with zones served from a fixture
+func TestFixture_Service(t *testing.T) {
+	cert := testSelfSignedCert(t, "hermetic")
+	zone := newTestSignedZone(t, "hermetic.")
+	zone.addTLSA(cert)
+	c, node, client := startFixtureService(t, zone, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := client.VerifyCert(ctx, &proto.CertVerifyRequest{
+		Host: "hermetic",
+		Port: "443",
+		Cert: &proto.Certificate{DerCerts: [][]byte{cert.Raw}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.State != proto.SecurityState_SECURE {
+		t.Fatalf("got %s (%s), want secure", res.State, res.AdditionalInfo)
+	}
+
+	pages := httptest.NewServer(NewContentPages(c).Handler())
+	defer pages.Close()
+
+	resp, err := http.Get(pages.URL + "/resources/info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var status content.HandshakeStatus
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Synced || status.Height != 120000 || status.ActivePeers != 4 {
+		t.Fatalf("got status %+v", status)
+	}
+
+	stream, err := http.Get(pages.URL + "/resources/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	events := bufio.NewScanner(stream.Body)
+
+	// the current status is pushed first then on every change
+	nextStatus := func() *content.HandshakeStatus {
+		for events.Scan() {
+			data := strings.TrimPrefix(events.Text(), "data: ")
+			if data == events.Text() {
+				continue
+			}
+
+			status := &content.HandshakeStatus{}
+			if err := json.Unmarshal([]byte(data), status); err != nil {
+				t.Fatal(err)
+			}
+			return status
+		}
+		t.Fatalf("events stream ended: %v", events.Err())
+		return nil
+	}
+	if status := nextStatus(); status.ActivePeers != 4 {
+		t.Fatalf("got status %+v, want 4 active peers", status)
+	}
+
+	// zones that aren't cached can't be resolved without peers
+	node.SetActivePeers(0)
+	if status := nextStatus(); status.ActivePeers != 0 {
+		t.Fatalf("got status %+v, want no active peers", status)
+	}
+	unreachable := testSelfSignedCert(t, "unreachable")
+	if res, err = client.VerifyCert(ctx, &proto.CertVerifyRequest{
+		Host: "unreachable",
+		Port: "443",
+		Cert: &proto.Certificate{DerCerts: [][]byte{unreachable.Raw}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if res.State != proto.SecurityState_BOGUS || res.Code != proto.ErrorCode_ERR_HNS_NO_PEERS {
+		t.Fatalf("got %s (%s), want no peers", res.State, res.Code)
+	}
+}
+
+// TestFixture_NameRootChange a rotated key is only trusted
+// once the name root committing its DS changes
+func TestFixture_NameRootChange(t *testing.T) {
+	cert := testSelfSignedCert(t, "hermetic")
+	zone := newTestSignedZone(t, "hermetic.")
+	zone.addTLSA(cert)
+	_, node, client := startFixtureService(t, zone, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	verify := func(cert *x509.Certificate) *proto.CertVerifyResponse {
+		res, err := client.VerifyCert(ctx, &proto.CertVerifyRequest{
+			Host: "hermetic",
+			Port: "443",
+			Cert: &proto.Certificate{DerCerts: [][]byte{cert.Raw}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	if res := verify(cert); res.State != proto.SecurityState_SECURE {
+		t.Fatalf("got %s (%s), want secure", res.State, res.AdditionalInfo)
+	}
+
+	rotated := testSelfSignedCert(t, "hermetic")
+	zone.rotate()
+	zone.addTLSA(rotated)
+	if err := node.SetZone("hermetic", []string{zone.ds()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cached anchor still has the old key
+	if res := verify(rotated); res.State == proto.SecurityState_SECURE {
+		t.Fatal("rotated key trusted before the name root changed")
+	}
+
+	node.SetNameRoot(bytes.Repeat([]byte{2}, 32))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res := verify(rotated)
+		if res.State == proto.SecurityState_SECURE {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %s (%s), want secure after the name root changed", res.State, res.AdditionalInfo)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TestFixture_DiskCache anchors fetched before a restart
+// are served while the node syncs
+func TestFixture_DiskCache(t *testing.T) {
+	cert := testSelfSignedCert(t, "hermetic")
+	zone := newTestSignedZone(t, "hermetic.")
+	zone.addTLSA(cert)
+	cacheDir := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	verify := func(client proto.CertVerifierClient) *proto.CertVerifyResponse {
+		res, err := client.VerifyCert(ctx, &proto.CertVerifyRequest{
+			Host: "hermetic",
+			Port: "443",
+			Cert: &proto.Certificate{DerCerts: [][]byte{cert.Raw}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	c, _, client := startFixtureService(t, zone, map[string]interface{}{"cacheDir": cacheDir})
+	if res := verify(client); res.State != proto.SecurityState_SECURE || res.AnchorStale {
+		t.Fatalf("got %s (%s) with stale = %v, want secure", res.State, res.AdditionalInfo, res.AnchorStale)
+	}
+	// written on shutdown
+	c.Flush()
+
+	// restarted with a node that takes an hour to sync
+	_, _, client = startFixtureService(t, zone, map[string]interface{}{
+		"cacheDir": cacheDir,
+		"syncTime": "1h",
+		"height":   120036,
+	})
+	if res := verify(client); res.State != proto.SecurityState_SECURE {
+		t.Fatalf("got %s (%s), want secure from the disk cache", res.State, res.AdditionalInfo)
+	}
+
+	// anchors fetched at another name root are flagged
+	_, _, client = startFixtureService(t, zone, map[string]interface{}{
+		"cacheDir": cacheDir,
+		"syncTime": "1h",
+		"nameRoot": strings.Repeat("bb", 32),
+	})
+	if res := verify(client); res.State != proto.SecurityState_SECURE || !res.AnchorStale {
+		t.Fatalf("got %s (%s) with stale = %v, want secure from a stale anchor", res.State, res.AdditionalInfo, res.AnchorStale)
+	}
+
+	// anchors older than the policy aren't served
+	_, _, client = startFixtureService(t, zone, map[string]interface{}{
+		"cacheDir":     cacheDir,
+		"syncTime":     "1h",
+		"maxAnchorAge": "1ns",
+	})
+	if res := verify(client); res.State != proto.SecurityState_BOGUS || res.Code != proto.ErrorCode_ERR_HNS_IS_SYNCING {
+		t.Fatalf("got %s (%s), want syncing", res.State, res.Code)
+	}
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// tldDiskCacheFile name of the TLD cache in the service cache directory
+	tldDiskCacheFile = "tld_cache.json"
+
+	// maxTLDDiskEntries TLDs kept on disk
+	maxTLDDiskEntries = 1000
+
+	// tldDiskSaveDelay entries set within it are written at once
+	tldDiskSaveDelay = time.Second
+)
+
+// tldDiskEntry a TLD zone and the chain state it was fetched at
+type tldDiskEntry struct {
+	Records []string  `json:"records"`
+	Height  uint64    `json:"height"`
+	Root    string    `json:"root"`
+	Fetched time.Time `json:"fetched"`
+	Expire  time.Time `json:"expire"`
+}
+
+// tldDiskCache keeps TLD zones across restarts so trust anchors can
+// be served before the node synced. Entries older than maxAge are
+// dropped. It's safe for concurrent use.
+type tldDiskCache struct {
+	path   string
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*tldDiskEntry
+
+	// entries not written yet
+	dirty bool
+	timer *time.Timer
+
+	// serializes writes of the file
+	saveMu sync.Mutex
+}
+
+// openTLDDiskCache loads the cache stored at path. A cache that
+// can't be read is logged and replaced on the next write.
+func openTLDDiskCache(path string, maxAge time.Duration) *tldDiskCache {
+	d := &tldDiskCache{
+		path:    path,
+		maxAge:  maxAge,
+		entries: make(map[string]*tldDiskEntry),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &d.entries)
+	}
+	if err != nil {
+		log.Printf("disk cache: ignoring tld cache: %v", err)
+		d.entries = make(map[string]*tldDiskEntry)
+	}
+
+	return d
+}
+
+// get the entry of name unless it's older than maxAge
+func (d *tldDiskCache) get(name string) (tldCacheEntry, bool) {
+	d.mu.Lock()
+	e, ok := d.entries[name]
+	d.mu.Unlock()
+	if !ok || time.Since(e.Fetched) > d.maxAge {
+		return tldCacheEntry{}, false
+	}
+
+	entry := tldCacheEntry{
+		expire:  e.Expire,
+		root:    e.Root,
+		height:  e.Height,
+		fetched: e.Fetched,
+	}
+	for _, record := range e.Records {
+		rr, err := dns.NewRR(record)
+		if err != nil || rr == nil {
+			log.Printf("disk cache: bad record for name %s: %v", name, err)
+			return tldCacheEntry{}, false
+		}
+		entry.rrs = append(entry.rrs, rr)
+	}
+
+	return entry, true
+}
+
+// set stores the entry of name. The cache is written
+// in the background shortly after.
+func (d *tldDiskCache) set(name string, entry tldCacheEntry) {
+	e := &tldDiskEntry{
+		Height:  entry.height,
+		Root:    entry.root,
+		Fetched: entry.fetched,
+		Expire:  entry.expire,
+	}
+	for _, rr := range entry.rrs {
+		e.Records = append(e.Records, rr.String())
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.entries[name] = e
+	d.evict()
+
+	d.dirty = true
+	if d.timer == nil {
+		d.timer = time.AfterFunc(tldDiskSaveDelay, func() {
+			if err := d.flush(); err != nil {
+				log.Printf("disk cache: %v", err)
+			}
+		})
+	}
+}
+
+// flush writes entries that were set since the last write
+func (d *tldDiskCache) flush() error {
+	if d == nil {
+		return nil
+	}
+
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	d.mu.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	d.dirty = false
+	data, err := json.Marshal(d.entries)
+	d.mu.Unlock()
+
+	if err == nil {
+		err = d.save(data)
+	}
+	if err != nil {
+		// retried with the next entry
+		d.mu.Lock()
+		d.dirty = true
+		d.mu.Unlock()
+	}
+
+	return err
+}
+
+// evict drops entries that can't be served anymore
+// and the oldest ones once the cache is full
+func (d *tldDiskCache) evict() {
+	names := make([]string, 0, len(d.entries))
+	for name, e := range d.entries {
+		if time.Since(e.Fetched) > d.maxAge {
+			delete(d.entries, name)
+			continue
+		}
+		names = append(names, name)
+	}
+
+	if len(names) <= maxTLDDiskEntries {
+		return
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return d.entries[names[i]].Fetched.Before(d.entries[names[j]].Fetched)
+	})
+	for _, name := range names[:len(names)-maxTLDDiskEntries] {
+		delete(d.entries, name)
+	}
+}
+
+// save writes data to a temporary file first
+// so that a crash never leaves a partial file behind
+func (d *tldDiskCache) save(data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(d.path), filepath.Base(d.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed writing tld cache: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed writing tld cache: %v", err)
+	}
+
+	if err = os.Rename(tmp.Name(), d.path); err != nil {
+		return fmt.Errorf("failed writing tld cache: %v", err)
+	}
+
+	return nil
+}
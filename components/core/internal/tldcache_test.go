@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestTLDDiskCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), tldDiskCacheFile)
+	d := openTLDDiskCache(path, time.Hour)
+
+	ds, err := dns.NewRR("example. 3600 IN DS 12345 13 2 " + strings.Repeat("a", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	d.set("example", tldCacheEntry{
+		expire:  now.Add(time.Minute),
+		rrs:     []dns.RR{ds},
+		root:    "01",
+		height:  120000,
+		fetched: now,
+	})
+	d.set("old", tldCacheEntry{
+		rrs:     []dns.RR{ds},
+		fetched: now.Add(-2 * time.Hour),
+	})
+
+	// written in the background
+	if _, err = ioutil.ReadFile(path); err == nil {
+		t.Fatal("cache written before the save delay")
+	}
+	if err = d.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopened like after a restart
+	d = openTLDDiskCache(path, time.Hour)
+	entry, ok := d.get("example")
+	if !ok {
+		t.Fatal("want entry for example")
+	}
+	if entry.root != "01" || entry.height != 120000 || !entry.fetched.Equal(now) {
+		t.Fatalf("got root %s height %d fetched %v", entry.root, entry.height, entry.fetched)
+	}
+	if len(entry.rrs) != 1 || entry.rrs[0].String() != ds.String() {
+		t.Fatalf("got records %v, want %v", entry.rrs, ds)
+	}
+
+	// older than maxAge
+	if _, ok = d.get("old"); ok {
+		t.Fatal("got entry older than max age")
+	}
+	if _, ok = d.entries["old"]; ok {
+		t.Fatal("entry older than max age should be evicted")
+	}
+
+	for i := 0; i < maxTLDDiskEntries+10; i++ {
+		d.entries["tld"+strconv.Itoa(i)] = &tldDiskEntry{Fetched: now.Add(time.Duration(i) * time.Second)}
+	}
+	d.evict()
+	if len(d.entries) != maxTLDDiskEntries {
+		t.Fatalf("got %d entries, want %d", len(d.entries), maxTLDDiskEntries)
+	}
+	if _, ok = d.entries["example"]; ok {
+		t.Fatal("oldest entry should be evicted")
+	}
+}
+
+func TestTLDDiskCache_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, tldDiskCacheFile)
+	if err := ioutil.WriteFile(path, []byte(`{"example": {"rec`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := openTLDDiskCache(path, time.Hour)
+	if _, ok := d.get("example"); ok {
+		t.Fatal("got entry from a partial file")
+	}
+
+	d.set("example", tldCacheEntry{fetched: time.Now()})
+	if err := d.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := openTLDDiskCache(path, time.Hour).get("example"); !ok {
+		t.Fatal("want entry after the cache was written again")
+	}
+
+	// no temporary files left behind
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+}
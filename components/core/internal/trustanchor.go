@@ -2,9 +2,12 @@ package internal
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -14,50 +17,173 @@ import (
 	"github.com/miekg/dns"
 )
 
+var (
+	errHIP5Failed   = errors.New("hip-5 handler failed")
+	errHIP5TimedOut = errors.New("hip-5 handler timed out")
+)
+
+const (
+	// tldRefreshTimeout max time spent fetching a TLD
+	// again after it went stale
+	tldRefreshTimeout = 10 * time.Second
+
+	// staleAnchorTTL how long a trust anchor from a stale
+	// entry is used before it's checked again
+	staleAnchorTTL = 30 * time.Second
+)
+
 type tldCacheEntry struct {
 	expire time.Time
 	rrs    []dns.RR
 	hip5   bool
+
+	// root and height the entry was fetched at
+	root    string
+	height  uint64
+	fetched time.Time
 }
 
+// ZoneQuery serves TLD zones committed to NameRoot at Height
 type ZoneQuery interface {
 	GetZone(ctx context.Context, name string) (rrs []dns.RR, err error)
+	NameRoot() []byte
+	Height() uint64
 }
 
 type RootZoneConfig struct {
-	client      ZoneQuery
-	eth         *hip5.Ethereum
-	tldMemCache *lru.Cache
+	client       ZoneQuery
+	eth          *hip5.Ethereum
+	tldMemCache  *lru.Cache
+	tldDiskCache *tldDiskCache
+
+	// maxAnchorAge how long after it was fetched a stale
+	// entry is served while it's fetched again
+	maxAnchorAge time.Duration
+
+	// TLDs fetched again in the background
+	refreshMu  sync.Mutex
+	refreshing map[string]bool
+
+	// refreshed if set is called after a TLD
+	// was fetched again in the background
+	refreshed func(name string)
 }
 
-func queryTLDWithCache(ctx context.Context, h *RootZoneConfig, name string) ([]dns.RR, time.Duration, error) {
+// nameRoot the tag of trust anchors from the current name root
+func nameRoot(h *RootZoneConfig) string {
+	return hex.EncodeToString(h.client.NameRoot())
+}
+
+// queryTLDWithCache entries that expired or were fetched at an older
+// name root are returned while they're fetched again in the background
+// unless they're older than maxAnchorAge
+func queryTLDWithCache(ctx context.Context, h *RootZoneConfig, name string) (tldCacheEntry, error) {
 	name = dns.CanonicalName(name)
 	// remove dot
 	name = name[:len(name)-1]
 	if dns.CountLabel(name) != 1 {
-		return nil, 0, fmt.Errorf("not a tld")
+		return tldCacheEntry{}, fmt.Errorf("not a tld")
 	}
 
-	if res, ok := h.tldMemCache.Get(name); ok {
-		entry := res.(tldCacheEntry)
-		if time.Now().Before(entry.expire) {
-			log.Printf("mem cache hit for name %s", name)
-			return entry.rrs, time.Now().Sub(entry.expire), nil
-		}
+	entry, ok := cachedTLD(h, name)
+	if !ok {
+		return fetchTLD(ctx, h, name)
+	}
+
+	if time.Now().Before(entry.expire) && entry.root == nameRoot(h) {
+		log.Printf("cache hit for name %s", name)
+		return entry, nil
+	}
+
+	age := time.Since(entry.fetched)
+	if age > h.maxAnchorAge {
 		h.tldMemCache.Remove(name)
+		return fetchTLD(ctx, h, name)
+	}
+
+	log.Printf("cache stale for name %s fetched %s ago at height %d", name, age.Round(time.Second), entry.height)
+	refreshTLD(h, name)
+	return entry, nil
+}
+
+// cachedTLD the entry of name from memory or from disk
+func cachedTLD(h *RootZoneConfig, name string) (tldCacheEntry, bool) {
+	if res, ok := h.tldMemCache.Get(name); ok {
+		return res.(tldCacheEntry), true
 	}
+	if h.tldDiskCache == nil {
+		return tldCacheEntry{}, false
+	}
+
+	entry, ok := h.tldDiskCache.get(name)
+	if ok {
+		log.Printf("disk cache hit for name %s", name)
+		h.tldMemCache.Add(name, entry)
+	}
+
+	return entry, ok
+}
+
+// fetchTLD queries name and caches it with the root it was fetched at
+func fetchTLD(ctx context.Context, h *RootZoneConfig, name string) (tldCacheEntry, error) {
+	// a root committed during the query only
+	// makes the entry stale sooner
+	root, height := nameRoot(h), h.client.Height()
 
 	rrs, ttl, err := queryTLD(ctx, h, name)
 	if err != nil {
-		return nil, 0, err
+		return tldCacheEntry{}, err
 	}
 
-	h.tldMemCache.Add(name, tldCacheEntry{
-		expire: time.Now().Add(ttl),
-		rrs:    rrs,
-	})
+	now := time.Now()
+	entry := tldCacheEntry{
+		expire:  now.Add(ttl),
+		rrs:     rrs,
+		root:    root,
+		height:  height,
+		fetched: now,
+	}
+	h.tldMemCache.Add(name, entry)
+
+	// no disk caching for names that don't exist
+	if h.tldDiskCache != nil && len(rrs) > 0 {
+		h.tldDiskCache.set(name, entry)
+	}
 
-	return rrs, ttl, nil
+	return entry, nil
+}
+
+// refreshTLD fetches name again unless it's already being fetched
+func refreshTLD(h *RootZoneConfig, name string) {
+	h.refreshMu.Lock()
+	defer h.refreshMu.Unlock()
+
+	if h.refreshing[name] {
+		return
+	}
+	if h.refreshing == nil {
+		h.refreshing = make(map[string]bool)
+	}
+	h.refreshing[name] = true
+
+	go func() {
+		defer func() {
+			h.refreshMu.Lock()
+			delete(h.refreshing, name)
+			h.refreshMu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), tldRefreshTimeout)
+		defer cancel()
+
+		if _, err := fetchTLD(ctx, h, name); err != nil {
+			log.Printf("failed refreshing name %s: %v", name, err)
+			return
+		}
+		if h.refreshed != nil {
+			h.refreshed(name)
+		}
+	}()
 }
 
 func queryTLD(ctx context.Context, h *RootZoneConfig, name string) (rrs []dns.RR, ttl time.Duration, err error) {
@@ -72,6 +198,14 @@ func queryTLD(ctx context.Context, h *RootZoneConfig, name string) (rrs []dns.RR
 		return
 	}
 
+	// names that don't exist are cached as long
+	ttl = time.Duration(hnsquery.HandshakeTTL) * time.Second
+	for _, rr := range rrs {
+		if rrTTL := time.Duration(rr.Header().Ttl) * time.Second; rrTTL < ttl {
+			ttl = rrTTL
+		}
+	}
+
 	return
 }
 
@@ -87,16 +221,16 @@ func getPowTrustAnchor(h *RootZoneConfig) hnsquery.TrustAnchorPointFunc {
 		}
 
 		cut = strings.TrimSuffix(strings.ToLower(cut), ".")
-		rrs, ttl, err := queryTLDWithCache(ctx, h, cut)
+		entry, err := queryTLDWithCache(ctx, h, cut)
 		if err != nil {
 			return nil, err
 		}
-		if len(rrs) == 0 {
+		if len(entry.rrs) == 0 {
 			return nil, nil
 		}
 
 		var dsSet []dns.RR
-		for _, rr := range rrs {
+		for _, rr := range entry.rrs {
 			if rr.Header().Rrtype == dns.TypeDS {
 				dsSet = append(dsSet, rr)
 			}
@@ -104,7 +238,16 @@ func getPowTrustAnchor(h *RootZoneConfig) hnsquery.TrustAnchorPointFunc {
 
 		zone, err := dnssec.NewZone(cut, dsSet)
 		if err == nil {
-			zone.Expire = time.Now().Add(ttl)
+			zone.Expire = entry.expire
+			zone.Tag = entry.root
+			zone.Fetched = entry.fetched
+			if time.Now().After(zone.Expire) {
+				zone.Expire = time.Now().Add(staleAnchorTTL)
+				zone.Stale = true
+			}
+			if entry.root != nameRoot(h) {
+				zone.Stale = true
+			}
 		}
 
 		return zone, err
@@ -128,11 +271,12 @@ func rootVerify(ctx context.Context, h *RootZoneConfig, msg *dns.Msg) (bool, err
 			return false, fmt.Errorf("can't verify type %d from root", qtype)
 		}
 
-		rrs, _, err := queryTLDWithCache(ctx, h, tld)
+		entry, err := queryTLDWithCache(ctx, h, tld)
 		if err != nil {
 			return false, err
 		}
 
+		rrs := entry.rrs
 		if len(rrs) == 0 {
 			// name doesn't exist on handshake
 			// icann fallback
@@ -158,10 +302,11 @@ func rootVerify(ctx context.Context, h *RootZoneConfig, msg *dns.Msg) (bool, err
 	}
 
 	// could be a HIP-5 SLD
-	rrs, _, err := queryTLDWithCache(ctx, h, tld)
+	entry, err := queryTLDWithCache(ctx, h, tld)
 	if err != nil {
 		return false, err
 	}
+	rrs := entry.rrs
 
 	var hip5NS []*dns.NS
 	for _, rr := range rrs {
@@ -205,7 +350,7 @@ func rootVerify(ctx context.Context, h *RootZoneConfig, msg *dns.Msg) (bool, err
 
 		rrs, err := h.eth.Handler(ctx, qname, t, hip5NS[0], true)
 		if err != nil {
-			return false, fmt.Errorf("hip-5: %v", err)
+			return false, hip5Error(ctx, err)
 		}
 
 		msg.Rcode = dns.RcodeSuccess
@@ -223,7 +368,7 @@ func rootVerify(ctx context.Context, h *RootZoneConfig, msg *dns.Msg) (bool, err
 	}
 	rrs, err = h.eth.Handler(ctx, qname, qtype, hip5NS[0], true)
 	if err != nil {
-		return false, fmt.Errorf("hip-5: %v", err)
+		return false, hip5Error(ctx, err)
 	}
 
 	if len(rrs) == 0 {
@@ -236,6 +381,14 @@ func rootVerify(ctx context.Context, h *RootZoneConfig, msg *dns.Msg) (bool, err
 	return false, fmt.Errorf("hip-5: record exists")
 }
 
+func hip5Error(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || isTimeout(err) {
+		return fmt.Errorf("hip-5: %v: %w", err, errHIP5TimedOut)
+	}
+
+	return fmt.Errorf("hip-5: %v: %w", err, errHIP5Failed)
+}
+
 var RootAnchor = func(ctx context.Context, v *RootZoneConfig, cut string) (*dnssec.Zone, error) {
 	zone, err := dnssec.NewZone(cut, nil)
 	if err != nil {
@@ -3,12 +3,19 @@ package internal
 import (
 	"context"
 	"errors"
+	"net"
+	"time"
 
 	"github.com/imperviousinc/beacon/components/core/public/proto"
 	"github.com/imperviousinc/hnsquery"
+	"github.com/imperviousinc/hnsquery/dnssec"
 	"github.com/miekg/dns"
 )
 
+// icannLookupTimeout max time spent looking up TLSA records
+// for ICANN domains that already passed WebPKI verification
+const icannLookupTimeout = 3 * time.Second
+
 // GRPC Verifier should use a mojo pipe instead.
 type CertVerifierGRPC struct {
 	proto.UnimplementedCertVerifierServer
@@ -26,80 +33,300 @@ func (bc *CertVerifierGRPC) VerifyCert(ctx context.Context, req *proto.CertVerif
 		}, nil
 	}
 
-	leafDer := chain[0]
+	// ICANN domains are verified by WebPKI. They can only
+	// opt into DANE pinning on top of a verified chain.
+	icann := isICANN(req.Host)
+	if icann && !req.WebpkiVerified {
+		return &proto.CertVerifyResponse{
+			VerifiedCert:   nil,
+			State:          proto.SecurityState_INSECURE,
+			Code:           proto.ErrorCode_UNKNOWN_ERROR,
+			AdditionalInfo: "",
+		}, nil
+	}
 
-	// Skip ICANN domains. Consumer of this API should
-	// already skip those but just in case.
-	h := dns.SplitDomainName(req.Host)
-	if len(h) > 0 {
-		tld := h[len(h)-1]
-		if _, ok := nameConstraints[tld]; ok {
-			return &proto.CertVerifyResponse{
-				VerifiedCert:   nil,
-				State:          proto.SecurityState_INSECURE,
-				Code:           proto.ErrorCode_UNKNOWN_ERROR,
-				AdditionalInfo: "",
-			}, nil
-		}
+	// TLSA records for HTTPS over TCP by default
+	protocol := req.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	// don't hold up ICANN connections on slow lookups
+	if icann {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, icannLookupTimeout)
+		defer cancel()
 	}
 
-	secure, err := bc.config.verifier.Verify(ctx, &hnsquery.CertVerifyInfo{
-		Host:     req.Host,
-		Port:     req.Port,
-		Protocol: "tcp",
-		RawCerts: [][]byte{leafDer},
+	report, err := bc.config.verifier.VerifyWithReport(ctx, &hnsquery.CertVerifyInfo{
+		Host:           req.Host,
+		Port:           req.Port,
+		Protocol:       protocol,
+		RawCerts:       chain,
+		WebPKIVerified: req.WebpkiVerified,
+		WebPKIChain:    req.GetWebpkiChain().GetDerCerts(),
 	})
+	secure := report.Secure
+	records := tlsaRecords(report)
+	pin := pinChange(report.PinChange)
+	age := anchorAge(report)
+
+	// lookup failures fallback to WebPKI for ICANN domains
+	// only a TLSA record that doesn't match or a rejected
+	// certificate is bogus
+	if icann && err != nil && !errors.Is(err, hnsquery.ErrDNSAuthFailed) && !errors.Is(err, hnsquery.ErrCertVerifyFailed) {
+		secure, err = false, nil
+	}
 
 	// No errors
 	if err == nil {
 		// Insecure zone
 		if !secure {
 			return &proto.CertVerifyResponse{
-				State: proto.SecurityState_INSECURE,
-				Code:  proto.ErrorCode_UNKNOWN_ERROR,
+				State:            proto.SecurityState_INSECURE,
+				Code:             proto.ErrorCode_UNKNOWN_ERROR,
+				TlsaRecords:      records,
+				PinChange:        pin,
+				AnchorStale:      report.AnchorStale,
+				AnchorAgeSeconds: age,
 			}, nil
 		}
 		// DANE verified
 		return &proto.CertVerifyResponse{
-			State: proto.SecurityState_SECURE,
-			Code:  proto.ErrorCode_UNKNOWN_ERROR,
+			State:            proto.SecurityState_SECURE,
+			Code:             proto.ErrorCode_UNKNOWN_ERROR,
+			TlsaRecords:      records,
+			PinChange:        pin,
+			AnchorStale:      report.AnchorStale,
+			AnchorAgeSeconds: age,
 		}, nil
 	}
 
 	// Bogus
-	switch {
-	case errors.Is(err, hnsquery.ErrDNSAuthFailed):
-		return &proto.CertVerifyResponse{
-			State: proto.SecurityState_BOGUS,
-			Code:  proto.ErrorCode_ERR_DNSSEC_PINNED_KEY_NOT_IN_CERT_CHAIN,
-		}, nil
-	case errors.Is(err, hnsquery.ErrTimeout):
-		return &proto.CertVerifyResponse{
-			State: proto.SecurityState_BOGUS,
-			Code:  proto.ErrorCode_ERR_DNS_TIMED_OUT,
-		}, nil
-	case errors.Is(err, hnsquery.ErrCancelled):
-		return &proto.CertVerifyResponse{
-			State: proto.SecurityState_BOGUS,
-			// TODO: add more suitable error code
-			Code: proto.ErrorCode_ERR_ABORTED,
+	return &proto.CertVerifyResponse{
+		VerifiedCert:     nil,
+		State:            proto.SecurityState_BOGUS,
+		Code:             errorCode(err),
+		AdditionalInfo:   err.Error(),
+		TlsaRecords:      records,
+		PinChange:        pin,
+		AnchorStale:      report.AnchorStale,
+		AnchorAgeSeconds: age,
+	}, nil
+}
+
+// anchorAge seconds since the trust anchor of the
+// report was fetched or 0 if it's unknown
+func anchorAge(report *hnsquery.VerifyReport) int64 {
+	if report.AnchorFetched.IsZero() {
+		return 0
+	}
+
+	return int64(time.Since(report.AnchorFetched) / time.Second)
+}
+
+func pinChange(change hnsquery.PinChange) proto.PinChange {
+	switch change {
+	case hnsquery.PinNew:
+		return proto.PinChange_PIN_NEW
+	case hnsquery.PinUnchanged:
+		return proto.PinChange_PIN_UNCHANGED
+	case hnsquery.PinRotated:
+		return proto.PinChange_PIN_ROTATED
+	case hnsquery.PinReplaced:
+		return proto.PinChange_PIN_REPLACED
+	}
+
+	return proto.PinChange_PIN_UNKNOWN
+}
+
+// Prefetch warms the caches for handshake hosts. ICANN hosts
+// are skipped since they're rarely pinned with TLSA records.
+func (bc *CertVerifierGRPC) Prefetch(ctx context.Context, req *proto.PrefetchRequest) (*proto.PrefetchResponse, error) {
+	var hosts []hnsquery.HostPort
+	var requested []*proto.HostPort
+	for _, h := range req.Hosts {
+		if h.GetHost() == "" || isICANN(h.Host) {
+			continue
+		}
+
+		requested = append(requested, h)
+		hosts = append(hosts, hnsquery.HostPort{
+			Host:     h.Host,
+			Port:     h.Port,
+			Protocol: h.Protocol,
+		})
+	}
+
+	res := &proto.PrefetchResponse{}
+	for i, err := range bc.config.verifier.Prefetch(ctx, hosts) {
+		result := &proto.PrefetchResult{
+			Host: requested[i],
+			Code: proto.ErrorCode_UNKNOWN_ERROR,
+		}
+		if err != nil {
+			result.Code = errorCode(err)
+		}
+		res.Results = append(res.Results, result)
+	}
+
+	return res, nil
+}
+
+// LookupHTTPS resolves the HTTPS records of handshake hosts. ICANN
+// hosts return no endpoints and are left to the platform resolver.
+func (bc *CertVerifierGRPC) LookupHTTPS(ctx context.Context, req *proto.HTTPSRequest) (*proto.HTTPSResponse, error) {
+	if req.GetHost() == "" || isICANN(req.Host) {
+		return &proto.HTTPSResponse{Name: dns.Fqdn(req.GetHost())}, nil
+	}
+
+	result, err := bc.config.verifier.Resolver.LookupHTTPS(ctx, req.Host)
+	if err != nil {
+		return &proto.HTTPSResponse{
+			Name:           dns.Fqdn(req.Host),
+			Code:           errorCode(err),
+			AdditionalInfo: err.Error(),
 		}, nil
+	}
+
+	res := &proto.HTTPSResponse{
+		Name:   result.Name,
+		Secure: result.Secure,
+	}
+	for _, e := range result.Endpoints {
+		endpoint := &proto.HTTPSEndpoint{
+			Priority:      uint32(e.Priority),
+			Target:        e.Target,
+			Port:          uint32(e.Port),
+			Alpn:          e.ALPN,
+			NoDefaultAlpn: e.NoDefaultALPN,
+			EchConfig:     e.ECHConfig,
+		}
+		for _, ip := range e.IPv4Hints {
+			endpoint.Ipv4Hints = append(endpoint.Ipv4Hints, ip.To4())
+		}
+		for _, ip := range e.IPv6Hints {
+			endpoint.Ipv6Hints = append(endpoint.Ipv6Hints, ip.To16())
+		}
+		res.Endpoints = append(res.Endpoints, endpoint)
+	}
+
+	return res, nil
+}
+
+// isICANN host is under an ICANN TLD
+func isICANN(host string) bool {
+	labels := dns.SplitDomainName(host)
+	if len(labels) == 0 {
+		return false
+	}
+
+	_, ok := nameConstraints[labels[len(labels)-1]]
+	return ok
+}
+
+// tlsaRecords converts the per record results of a report
+func tlsaRecords(report *hnsquery.VerifyReport) []*proto.TLSARecordResult {
+	var records []*proto.TLSARecordResult
+	for _, r := range report.Records {
+		record := &proto.TLSARecordResult{
+			Usage:        uint32(r.Usage),
+			Selector:     uint32(r.Selector),
+			MatchingType: uint32(r.MatchingType),
+			Expected:     r.Expected,
+			Matched:      r.Matched,
+			Skipped:      r.Skipped,
+			Reason:       r.Reason,
+		}
+		for _, c := range r.Computed {
+			record.Computed = append(record.Computed, &proto.TLSADigest{
+				Subject: c.Subject,
+				Digest:  c.Digest,
+			})
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// errorCode maps a verification error to the most specific
+// error code. Unknown errors are bogus.
+func errorCode(err error) proto.ErrorCode {
+	var fetchErr *hnsquery.FetchError
+
+	switch {
+	// hns client
 	case errors.Is(err, hnsquery.ErrNotSynced):
-		return &proto.CertVerifyResponse{
-			State: proto.SecurityState_BOGUS,
-			Code:  proto.ErrorCode_ERR_HNS_IS_SYNCING,
-		}, nil
+		return proto.ErrorCode_ERR_HNS_IS_SYNCING
 	case errors.Is(err, hnsquery.ErrNoPeers):
-		return &proto.CertVerifyResponse{
-			State: proto.SecurityState_BOGUS,
-			Code:  proto.ErrorCode_ERR_HNS_NO_PEERS,
-		}, nil
+		return proto.ErrorCode_ERR_HNS_NO_PEERS
+	case errors.Is(err, hnsquery.ErrTimeout):
+		return proto.ErrorCode_ERR_HNS_PEER_TIMED_OUT
+	case errors.Is(err, hnsquery.ErrRequestFailed):
+		return proto.ErrorCode_ERR_HNS_REQUEST_FAILED
+	case errors.Is(err, hnsquery.ErrCancelled):
+		// TODO: add more suitable error code
+		return proto.ErrorCode_ERR_ABORTED
+	case errors.Is(err, errHIP5TimedOut):
+		return proto.ErrorCode_ERR_HNS_HIP5_HANDLER_TIMED_OUT
+	case errors.Is(err, errHIP5Failed):
+		return proto.ErrorCode_ERR_HNS_HIP5_HANDLER_FAILED
+
+	// certificate
+	case errors.Is(err, hnsquery.ErrUnsupportedService):
+		return proto.ErrorCode_ERR_TRUST_SERVICE_REQUEST_INVALID
+	case errors.Is(err, hnsquery.ErrDNSAuthFailed):
+		return proto.ErrorCode_ERR_DNSSEC_PINNED_KEY_NOT_IN_CERT_CHAIN
+	case errors.Is(err, hnsquery.ErrCertNameOutsideTLD):
+		return proto.ErrorCode_ERR_CERT_NAME_CONSTRAINT_VIOLATION
+	case errors.Is(err, hnsquery.ErrCertValidityTooLong):
+		return proto.ErrorCode_ERR_CERT_VALIDITY_TOO_LONG
+	case errors.Is(err, hnsquery.ErrCertWeakKey):
+		return proto.ErrorCode_ERR_CERT_WEAK_KEY
+	case errors.Is(err, hnsquery.ErrCertNameMismatch):
+		return proto.ErrorCode_ERR_CERT_COMMON_NAME_INVALID
+	case errors.Is(err, hnsquery.ErrCertVerifyFailed):
+		return proto.ErrorCode_ERR_CERT_INVALID
+
+	// building the chain of trust
+	case errors.As(err, &fetchErr):
+		if isTimeout(fetchErr.Err) {
+			return proto.ErrorCode_ERR_DNSSEC_FETCH_TIMED_OUT
+		}
+		return proto.ErrorCode_ERR_DNSSEC_FETCH_FAILED
+
+	// validation
+	case errors.Is(err, dnssec.ErrInvalidSignaturePeriod):
+		return proto.ErrorCode_ERR_DNSSEC_SIGNATURE_EXPIRED
+	case errors.Is(err, dnssec.ErrNoSignatures):
+		return proto.ErrorCode_ERR_DNSSEC_SIGNATURE_MISSING
+	case errors.Is(err, dnssec.ErrMissingDNSKEY), errors.Is(err, dnssec.ErrNoDNSKEY):
+		return proto.ErrorCode_ERR_DNSSEC_DNSKEY_MISSING
+	case errors.Is(err, dnssec.ErrMissingNSEC):
+		return proto.ErrorCode_ERR_DNSSEC_NSEC_MISSING
+	case errors.Is(err, dnssec.ErrUnexpectedRcode), errors.Is(err, hnsquery.ErrServerFailure):
+		return proto.ErrorCode_ERR_DNS_SERVER_FAILED
+	case errors.Is(err, hnsquery.ErrDNSSECFailed):
+		return proto.ErrorCode_ERR_DNSSEC_BOGUS
+
+	// lookup
+	case errors.Is(err, hnsquery.ErrMalformedResponse):
+		return proto.ErrorCode_ERR_DNS_MALFORMED_RESPONSE
+	case errors.Is(err, context.Canceled):
+		return proto.ErrorCode_ERR_DNS_REQUEST_CANCELLED
+	case isTimeout(err):
+		return proto.ErrorCode_ERR_DNS_TIMED_OUT
 	}
 
-	return &proto.CertVerifyResponse{
-		VerifiedCert:   nil,
-		State:          proto.SecurityState_BOGUS,
-		Code:           proto.ErrorCode_ERR_DNSSEC_BOGUS,
-		AdditionalInfo: "",
-	}, nil
+	return proto.ErrorCode_ERR_DNSSEC_BOGUS
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
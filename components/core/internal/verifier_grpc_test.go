@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/imperviousinc/beacon/components/core/public/proto"
+	"github.com/imperviousinc/hnsquery"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"github.com/miekg/dns"
+)
+
+func TestErrorCode(t *testing.T) {
+	validation := func(reason error) error {
+		return fmt.Errorf("lookup: %w", &hnsquery.ValidationError{Name: "example.", Reason: reason})
+	}
+
+	tests := []struct {
+		err  error
+		code proto.ErrorCode
+	}{
+		{validation(fmt.Errorf("error verifying signatures: %w", dnssec.ErrInvalidSignaturePeriod)), proto.ErrorCode_ERR_DNSSEC_SIGNATURE_EXPIRED},
+		{validation(dnssec.ErrNoSignatures), proto.ErrorCode_ERR_DNSSEC_SIGNATURE_MISSING},
+		{validation(dnssec.ErrMissingDNSKEY), proto.ErrorCode_ERR_DNSSEC_DNSKEY_MISSING},
+		{validation(fmt.Errorf("no nsec records found: %w", dnssec.ErrMissingNSEC)), proto.ErrorCode_ERR_DNSSEC_NSEC_MISSING},
+		{validation(dnssec.ErrUnexpectedRcode), proto.ErrorCode_ERR_DNS_SERVER_FAILED},
+		{validation(fmt.Errorf("bad proof")), proto.ErrorCode_ERR_DNSSEC_BOGUS},
+		{validation(&hnsquery.FetchError{Zone: "example.", Type: dns.TypeDNSKEY, Err: context.DeadlineExceeded}), proto.ErrorCode_ERR_DNSSEC_FETCH_TIMED_OUT},
+		{validation(&hnsquery.FetchError{Zone: "example.", Type: dns.TypeDS, Err: hnsquery.ErrServerFailure}), proto.ErrorCode_ERR_DNSSEC_FETCH_FAILED},
+		{validation(fmt.Errorf("hip-5: %w", errHIP5Failed)), proto.ErrorCode_ERR_HNS_HIP5_HANDLER_FAILED},
+		{validation(fmt.Errorf("failed getting trust anchor: %w", hnsquery.ErrNotSynced)), proto.ErrorCode_ERR_HNS_IS_SYNCING},
+		{hnsquery.ErrTimeout, proto.ErrorCode_ERR_HNS_PEER_TIMED_OUT},
+		{fmt.Errorf("hns error (code: 1): %w", hnsquery.ErrRequestFailed), proto.ErrorCode_ERR_HNS_REQUEST_FAILED},
+		{fmt.Errorf("no matching tlsa record: %w", hnsquery.ErrDNSAuthFailed), proto.ErrorCode_ERR_DNSSEC_PINNED_KEY_NOT_IN_CERT_CHAIN},
+		{fmt.Errorf("x509: certificate is valid for a.example: %w", hnsquery.ErrCertNameMismatch), proto.ErrorCode_ERR_CERT_COMMON_NAME_INVALID},
+		{fmt.Errorf("cert parse error: %w", hnsquery.ErrCertVerifyFailed), proto.ErrorCode_ERR_CERT_INVALID},
+		{fmt.Errorf("a.com isn't in example.: %w", hnsquery.ErrCertNameOutsideTLD), proto.ErrorCode_ERR_CERT_NAME_CONSTRAINT_VIOLATION},
+		{fmt.Errorf("valid for 87600h: %w", hnsquery.ErrCertValidityTooLong), proto.ErrorCode_ERR_CERT_VALIDITY_TOO_LONG},
+		{fmt.Errorf("rsa key size 1024: %w", hnsquery.ErrCertWeakKey), proto.ErrorCode_ERR_CERT_WEAK_KEY},
+		{fmt.Errorf("protocol \"quic\": %w", hnsquery.ErrUnsupportedService), proto.ErrorCode_ERR_TRUST_SERVICE_REQUEST_INVALID},
+		{fmt.Errorf("question mismatch: %w", hnsquery.ErrMalformedResponse), proto.ErrorCode_ERR_DNS_MALFORMED_RESPONSE},
+		{fmt.Errorf("upstream: %w", context.DeadlineExceeded), proto.ErrorCode_ERR_DNS_TIMED_OUT},
+		{context.Canceled, proto.ErrorCode_ERR_DNS_REQUEST_CANCELLED},
+		{fmt.Errorf("unknown"), proto.ErrorCode_ERR_DNSSEC_BOGUS},
+	}
+
+	for _, test := range tests {
+		if got := errorCode(test.err); got != test.code {
+			t.Fatalf("%v: got %s, want %s", test.err, got, test.code)
+		}
+	}
+}
+
+func TestDefaultCertPolicy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		DNSNames:  []string{"example.com", "example.net"},
+		PublicKey: &key.PublicKey,
+	}
+
+	// ICANN hosts already passed WebPKI
+	if err := defaultCertPolicy.Check(leaf, "example.com", true); err != nil {
+		t.Fatalf("got err = %v, want nil for an ICANN host", err)
+	}
+
+	leaf.DNSNames = []string{"example", "example.com"}
+	if err := defaultCertPolicy.Check(leaf, "example", true); !errors.Is(err, hnsquery.ErrCertNameOutsideTLD) {
+		t.Fatalf("got err = %v, want %v", err, hnsquery.ErrCertNameOutsideTLD)
+	}
+
+	// no minimum RSA key size unless configured
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf = &x509.Certificate{DNSNames: []string{"example"}, PublicKey: &rsaKey.PublicKey}
+	if err := defaultCertPolicy.Check(leaf, "example", true); err != nil {
+		t.Fatalf("got err = %v, want nil", err)
+	}
+}
@@ -20,6 +20,66 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type PinChange int32
+
+const (
+	// No pin history is kept.
+	PinChange_PIN_UNKNOWN PinChange = 0
+	// First time the service is seen.
+	PinChange_PIN_NEW       PinChange = 1
+	PinChange_PIN_UNCHANGED PinChange = 2
+	// Some records were kept like a normal key rollover.
+	PinChange_PIN_ROTATED PinChange = 3
+	// Every record was replaced at once. The previous
+	// key may have been compromised.
+	PinChange_PIN_REPLACED PinChange = 4
+)
+
+// Enum value maps for PinChange.
+var (
+	PinChange_name = map[int32]string{
+		0: "PIN_UNKNOWN",
+		1: "PIN_NEW",
+		2: "PIN_UNCHANGED",
+		3: "PIN_ROTATED",
+		4: "PIN_REPLACED",
+	}
+	PinChange_value = map[string]int32{
+		"PIN_UNKNOWN":   0,
+		"PIN_NEW":       1,
+		"PIN_UNCHANGED": 2,
+		"PIN_ROTATED":   3,
+		"PIN_REPLACED":  4,
+	}
+)
+
+func (x PinChange) Enum() *PinChange {
+	p := new(PinChange)
+	*p = x
+	return p
+}
+
+func (x PinChange) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PinChange) Descriptor() protoreflect.EnumDescriptor {
+	return file_dnssec_cert_verifier_proto_enumTypes[0].Descriptor()
+}
+
+func (PinChange) Type() protoreflect.EnumType {
+	return &file_dnssec_cert_verifier_proto_enumTypes[0]
+}
+
+func (x PinChange) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PinChange.Descriptor instead.
+func (PinChange) EnumDescriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{0}
+}
+
 type SecurityState int32
 
 const (
@@ -56,11 +116,11 @@ func (x SecurityState) String() string {
 }
 
 func (SecurityState) Descriptor() protoreflect.EnumDescriptor {
-	return file_dnssec_cert_verifier_proto_enumTypes[0].Descriptor()
+	return file_dnssec_cert_verifier_proto_enumTypes[1].Descriptor()
 }
 
 func (SecurityState) Type() protoreflect.EnumType {
-	return &file_dnssec_cert_verifier_proto_enumTypes[0]
+	return &file_dnssec_cert_verifier_proto_enumTypes[1]
 }
 
 func (x SecurityState) Number() protoreflect.EnumNumber {
@@ -69,7 +129,7 @@ func (x SecurityState) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use SecurityState.Descriptor instead.
 func (SecurityState) EnumDescriptor() ([]byte, []int) {
-	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{0}
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{1}
 }
 
 type ErrorCode int32
@@ -216,11 +276,11 @@ func (x ErrorCode) String() string {
 }
 
 func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
-	return file_dnssec_cert_verifier_proto_enumTypes[1].Descriptor()
+	return file_dnssec_cert_verifier_proto_enumTypes[2].Descriptor()
 }
 
 func (ErrorCode) Type() protoreflect.EnumType {
-	return &file_dnssec_cert_verifier_proto_enumTypes[1]
+	return &file_dnssec_cert_verifier_proto_enumTypes[2]
 }
 
 func (x ErrorCode) Number() protoreflect.EnumNumber {
@@ -229,7 +289,7 @@ func (x ErrorCode) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ErrorCode.Descriptor instead.
 func (ErrorCode) EnumDescriptor() ([]byte, []int) {
-	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{1}
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{2}
 }
 
 type CertVerifyRequest struct {
@@ -240,6 +300,14 @@ type CertVerifyRequest struct {
 	Host string       `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
 	Port string       `protobuf:"bytes,2,opt,name=port,proto3" json:"port,omitempty"`
 	Cert *Certificate `protobuf:"bytes,3,opt,name=cert,proto3" json:"cert,omitempty"`
+	// The platform verifier accepted the certificate. TLSA usages
+	// 0 (PKIX-TA) and 1 (PKIX-EE) are only enforced on top of it.
+	WebpkiVerified bool `protobuf:"varint,4,opt,name=webpki_verified,json=webpkiVerified,proto3" json:"webpki_verified,omitempty"`
+	// Chain built by the platform verifier, leaf first.
+	WebpkiChain *Certificate `protobuf:"bytes,5,opt,name=webpki_chain,json=webpkiChain,proto3" json:"webpki_chain,omitempty"`
+	// Protocol of the TLSA record: tcp, udp or sctp. Defaults to tcp.
+	// Use udp for QUIC.
+	Protocol string `protobuf:"bytes,6,opt,name=protocol,proto3" json:"protocol,omitempty"`
 }
 
 func (x *CertVerifyRequest) Reset() {
@@ -295,6 +363,27 @@ func (x *CertVerifyRequest) GetCert() *Certificate {
 	return nil
 }
 
+func (x *CertVerifyRequest) GetWebpkiVerified() bool {
+	if x != nil {
+		return x.WebpkiVerified
+	}
+	return false
+}
+
+func (x *CertVerifyRequest) GetWebpkiChain() *Certificate {
+	if x != nil {
+		return x.WebpkiChain
+	}
+	return nil
+}
+
+func (x *CertVerifyRequest) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
 type CertVerifyResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -304,6 +393,16 @@ type CertVerifyResponse struct {
 	State          SecurityState `protobuf:"varint,2,opt,name=state,proto3,enum=dnssec_cert_verifier.SecurityState" json:"state,omitempty"`
 	Code           ErrorCode     `protobuf:"varint,3,opt,name=code,proto3,enum=dnssec_cert_verifier.ErrorCode" json:"code,omitempty"`
 	AdditionalInfo string        `protobuf:"bytes,4,opt,name=additional_info,json=additionalInfo,proto3" json:"additional_info,omitempty"`
+	// How each TLSA record compared to the certificate.
+	TlsaRecords []*TLSARecordResult `protobuf:"bytes,5,rep,name=tlsa_records,json=tlsaRecords,proto3" json:"tlsa_records,omitempty"`
+	// How the TLSA set changed since it was last seen.
+	PinChange PinChange `protobuf:"varint,6,opt,name=pin_change,json=pinChange,proto3,enum=dnssec_cert_verifier.PinChange" json:"pin_change,omitempty"`
+	// The trust anchor of the TLD is served from the cache
+	// while a newer one is fetched.
+	AnchorStale bool `protobuf:"varint,7,opt,name=anchor_stale,json=anchorStale,proto3" json:"anchor_stale,omitempty"`
+	// Seconds since the trust anchor of the TLD was fetched
+	// or 0 if it's unknown.
+	AnchorAgeSeconds int64 `protobuf:"varint,8,opt,name=anchor_age_seconds,json=anchorAgeSeconds,proto3" json:"anchor_age_seconds,omitempty"`
 }
 
 func (x *CertVerifyResponse) Reset() {
@@ -366,16 +465,55 @@ func (x *CertVerifyResponse) GetAdditionalInfo() string {
 	return ""
 }
 
-type Certificate struct {
+func (x *CertVerifyResponse) GetTlsaRecords() []*TLSARecordResult {
+	if x != nil {
+		return x.TlsaRecords
+	}
+	return nil
+}
+
+func (x *CertVerifyResponse) GetPinChange() PinChange {
+	if x != nil {
+		return x.PinChange
+	}
+	return PinChange_PIN_UNKNOWN
+}
+
+func (x *CertVerifyResponse) GetAnchorStale() bool {
+	if x != nil {
+		return x.AnchorStale
+	}
+	return false
+}
+
+func (x *CertVerifyResponse) GetAnchorAgeSeconds() int64 {
+	if x != nil {
+		return x.AnchorAgeSeconds
+	}
+	return 0
+}
+
+type TLSARecordResult struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	DerCerts [][]byte `protobuf:"bytes,1,rep,name=der_certs,json=derCerts,proto3" json:"der_certs,omitempty"`
+	Usage        uint32 `protobuf:"varint,1,opt,name=usage,proto3" json:"usage,omitempty"`
+	Selector     uint32 `protobuf:"varint,2,opt,name=selector,proto3" json:"selector,omitempty"`
+	MatchingType uint32 `protobuf:"varint,3,opt,name=matching_type,json=matchingType,proto3" json:"matching_type,omitempty"`
+	// Certificate association data of the record.
+	Expected string `protobuf:"bytes,4,opt,name=expected,proto3" json:"expected,omitempty"`
+	// Data computed for each certificate compared to the record.
+	Computed []*TLSADigest `protobuf:"bytes,5,rep,name=computed,proto3" json:"computed,omitempty"`
+	Matched  bool          `protobuf:"varint,6,opt,name=matched,proto3" json:"matched,omitempty"`
+	// The record isn't usable and was ignored.
+	Skipped bool `protobuf:"varint,7,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	// Why the record was skipped or didn't match.
+	Reason string `protobuf:"bytes,8,opt,name=reason,proto3" json:"reason,omitempty"`
 }
 
-func (x *Certificate) Reset() {
-	*x = Certificate{}
+func (x *TLSARecordResult) Reset() {
+	*x = TLSARecordResult{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_dnssec_cert_verifier_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -383,13 +521,13 @@ func (x *Certificate) Reset() {
 	}
 }
 
-func (x *Certificate) String() string {
+func (x *TLSARecordResult) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Certificate) ProtoMessage() {}
+func (*TLSARecordResult) ProtoMessage() {}
 
-func (x *Certificate) ProtoReflect() protoreflect.Message {
+func (x *TLSARecordResult) ProtoReflect() protoreflect.Message {
 	mi := &file_dnssec_cert_verifier_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -401,206 +539,1058 @@ func (x *Certificate) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Certificate.ProtoReflect.Descriptor instead.
-func (*Certificate) Descriptor() ([]byte, []int) {
+// Deprecated: Use TLSARecordResult.ProtoReflect.Descriptor instead.
+func (*TLSARecordResult) Descriptor() ([]byte, []int) {
 	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *Certificate) GetDerCerts() [][]byte {
+func (x *TLSARecordResult) GetUsage() uint32 {
 	if x != nil {
-		return x.DerCerts
+		return x.Usage
+	}
+	return 0
+}
+
+func (x *TLSARecordResult) GetSelector() uint32 {
+	if x != nil {
+		return x.Selector
+	}
+	return 0
+}
+
+func (x *TLSARecordResult) GetMatchingType() uint32 {
+	if x != nil {
+		return x.MatchingType
+	}
+	return 0
+}
+
+func (x *TLSARecordResult) GetExpected() string {
+	if x != nil {
+		return x.Expected
+	}
+	return ""
+}
+
+func (x *TLSARecordResult) GetComputed() []*TLSADigest {
+	if x != nil {
+		return x.Computed
 	}
 	return nil
 }
 
-var File_dnssec_cert_verifier_proto protoreflect.FileDescriptor
+func (x *TLSARecordResult) GetMatched() bool {
+	if x != nil {
+		return x.Matched
+	}
+	return false
+}
 
-var file_dnssec_cert_verifier_proto_rawDesc = []byte{
-	0x0a, 0x1a, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65,
-	0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x14, 0x64, 0x6e,
-	0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69,
-	0x65, 0x72, 0x22, 0x72, 0x0a, 0x11, 0x43, 0x65, 0x72, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x79,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70,
-	0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x12,
-	0x35, 0x0a, 0x04, 0x63, 0x65, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e,
-	0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69,
-	0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65,
-	0x52, 0x04, 0x63, 0x65, 0x72, 0x74, 0x22, 0xf5, 0x01, 0x0a, 0x12, 0x43, 0x65, 0x72, 0x74, 0x56,
-	0x65, 0x72, 0x69, 0x66, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a,
-	0x0d, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65,
-	0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65, 0x72, 0x74,
-	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x0c, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65,
-	0x64, 0x43, 0x65, 0x72, 0x74, 0x12, 0x39, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0e, 0x32, 0x23, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65,
-	0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x53, 0x65, 0x63, 0x75,
-	0x72, 0x69, 0x74, 0x79, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65,
-	0x12, 0x33, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f,
-	0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72,
-	0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52,
-	0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f,
-	0x6e, 0x61, 0x6c, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e,
-	0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x49, 0x6e, 0x66, 0x6f, 0x22, 0x2a,
-	0x0a, 0x0b, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x1b, 0x0a,
-	0x09, 0x64, 0x65, 0x72, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c,
-	0x52, 0x08, 0x64, 0x65, 0x72, 0x43, 0x65, 0x72, 0x74, 0x73, 0x2a, 0x34, 0x0a, 0x0d, 0x53, 0x65,
-	0x63, 0x75, 0x72, 0x69, 0x74, 0x79, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x42,
-	0x4f, 0x47, 0x55, 0x53, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x45, 0x43, 0x55, 0x52, 0x45,
-	0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x49, 0x4e, 0x53, 0x45, 0x43, 0x55, 0x52, 0x45, 0x10, 0x02,
-	0x2a, 0x9b, 0x09, 0x0a, 0x09, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x11,
-	0x0a, 0x0d, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10,
-	0x00, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f,
-	0x42, 0x4f, 0x47, 0x55, 0x53, 0x10, 0x01, 0x12, 0x20, 0x0a, 0x1c, 0x45, 0x52, 0x52, 0x5f, 0x44,
-	0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45, 0x5f,
-	0x45, 0x58, 0x50, 0x49, 0x52, 0x45, 0x44, 0x10, 0x02, 0x12, 0x20, 0x0a, 0x1c, 0x45, 0x52, 0x52,
-	0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x54, 0x55, 0x52,
-	0x45, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x03, 0x12, 0x1d, 0x0a, 0x19, 0x45,
-	0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x44, 0x4e, 0x53, 0x4b, 0x45, 0x59,
-	0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x04, 0x12, 0x1b, 0x0a, 0x17, 0x45, 0x52,
-	0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x4e, 0x53, 0x45, 0x43, 0x5f, 0x4d, 0x49,
-	0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x05, 0x12, 0x2b, 0x0a, 0x27, 0x45, 0x52, 0x52, 0x5f, 0x44,
-	0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x50, 0x49, 0x4e, 0x4e, 0x45, 0x44, 0x5f, 0x4b, 0x45, 0x59,
-	0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x49, 0x4e, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x43, 0x48, 0x41,
-	0x49, 0x4e, 0x10, 0x06, 0x12, 0x1b, 0x0a, 0x17, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53,
-	0x45, 0x43, 0x5f, 0x46, 0x45, 0x54, 0x43, 0x48, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10,
-	0x07, 0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f,
-	0x46, 0x45, 0x54, 0x43, 0x48, 0x5f, 0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54, 0x10,
-	0x08, 0x12, 0x16, 0x0a, 0x12, 0x45, 0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x49, 0x53, 0x5f,
-	0x53, 0x59, 0x4e, 0x43, 0x49, 0x4e, 0x47, 0x10, 0x09, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52,
-	0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x4e, 0x4f, 0x5f, 0x50, 0x45, 0x45, 0x52, 0x53, 0x10, 0x0a, 0x12,
-	0x1a, 0x0a, 0x16, 0x45, 0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x50, 0x45, 0x45, 0x52, 0x5f,
-	0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54, 0x10, 0x0b, 0x12, 0x1a, 0x0a, 0x16, 0x45,
-	0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x5f, 0x46,
-	0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x0c, 0x12, 0x22, 0x0a, 0x1e, 0x45, 0x52, 0x52, 0x5f, 0x48,
-	0x4e, 0x53, 0x5f, 0x48, 0x49, 0x50, 0x35, 0x5f, 0x48, 0x41, 0x4e, 0x44, 0x4c, 0x45, 0x52, 0x5f,
-	0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54, 0x10, 0x0d, 0x12, 0x1f, 0x0a, 0x1b, 0x45,
-	0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x48, 0x49, 0x50, 0x35, 0x5f, 0x48, 0x41, 0x4e, 0x44,
-	0x4c, 0x45, 0x52, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x0e, 0x12, 0x24, 0x0a, 0x20,
-	0x45, 0x52, 0x52, 0x5f, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43,
-	0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44,
-	0x10, 0x0f, 0x12, 0x27, 0x0a, 0x23, 0x45, 0x52, 0x52, 0x5f, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f,
-	0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x5f,
-	0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54, 0x10, 0x10, 0x12, 0x25, 0x0a, 0x21, 0x45,
-	0x52, 0x52, 0x5f, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45,
-	0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44,
-	0x10, 0x11, 0x12, 0x26, 0x0a, 0x22, 0x45, 0x52, 0x52, 0x5f, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f,
-	0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45,
-	0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x12, 0x12, 0x36, 0x0a, 0x32, 0x45, 0x52,
-	0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x53, 0x45, 0x43, 0x55, 0x52, 0x45, 0x5f, 0x52, 0x45, 0x53,
-	0x4f, 0x4c, 0x56, 0x45, 0x52, 0x5f, 0x48, 0x4f, 0x53, 0x54, 0x4e, 0x41, 0x4d, 0x45, 0x5f, 0x52,
-	0x45, 0x53, 0x4f, 0x4c, 0x55, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44,
-	0x10, 0x13, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x54, 0x49,
-	0x4d, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54, 0x10, 0x14, 0x12, 0x19, 0x0a, 0x15, 0x45, 0x52, 0x52,
-	0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x45, 0x52, 0x5f, 0x46, 0x41, 0x49, 0x4c,
-	0x45, 0x44, 0x10, 0x15, 0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f,
-	0x4d, 0x41, 0x4c, 0x46, 0x4f, 0x52, 0x4d, 0x45, 0x44, 0x5f, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e,
-	0x53, 0x45, 0x10, 0x16, 0x12, 0x1d, 0x0a, 0x19, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f,
-	0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x5f, 0x43, 0x41, 0x4e, 0x43, 0x45, 0x4c, 0x4c, 0x45,
-	0x44, 0x10, 0x17, 0x12, 0x20, 0x0a, 0x1c, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f,
-	0x43, 0x4f, 0x4d, 0x4d, 0x4f, 0x4e, 0x5f, 0x4e, 0x41, 0x4d, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41,
-	0x4c, 0x49, 0x44, 0x10, 0x18, 0x12, 0x19, 0x0a, 0x15, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52,
-	0x54, 0x5f, 0x44, 0x41, 0x54, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x19,
-	0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x41, 0x55, 0x54,
-	0x48, 0x4f, 0x52, 0x49, 0x54, 0x59, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x1a,
-	0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x52, 0x45, 0x56,
-	0x4f, 0x4b, 0x45, 0x44, 0x10, 0x1b, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45,
-	0x52, 0x54, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x1c, 0x12, 0x25, 0x0a, 0x21,
-	0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x57, 0x45, 0x41, 0x4b, 0x5f, 0x53, 0x49,
-	0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45, 0x5f, 0x41, 0x4c, 0x47, 0x4f, 0x52, 0x49, 0x54, 0x48,
-	0x4d, 0x10, 0x1d, 0x12, 0x1c, 0x0a, 0x18, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f,
-	0x4e, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x49, 0x51, 0x55, 0x45, 0x5f, 0x4e, 0x41, 0x4d, 0x45, 0x10,
-	0x1e, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x57, 0x45,
-	0x41, 0x4b, 0x5f, 0x4b, 0x45, 0x59, 0x10, 0x1f, 0x12, 0x26, 0x0a, 0x22, 0x45, 0x52, 0x52, 0x5f,
-	0x43, 0x45, 0x52, 0x54, 0x5f, 0x4e, 0x41, 0x4d, 0x45, 0x5f, 0x43, 0x4f, 0x4e, 0x53, 0x54, 0x52,
-	0x41, 0x49, 0x4e, 0x54, 0x5f, 0x56, 0x49, 0x4f, 0x4c, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x20,
-	0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x56, 0x41, 0x4c,
-	0x49, 0x44, 0x49, 0x54, 0x59, 0x5f, 0x54, 0x4f, 0x4f, 0x5f, 0x4c, 0x4f, 0x4e, 0x47, 0x10, 0x21,
-	0x12, 0x27, 0x0a, 0x23, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x4b, 0x4e, 0x4f,
-	0x57, 0x4e, 0x5f, 0x49, 0x4e, 0x54, 0x45, 0x52, 0x43, 0x45, 0x50, 0x54, 0x49, 0x4f, 0x4e, 0x5f,
-	0x42, 0x4c, 0x4f, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x22, 0x12, 0x0e, 0x0a, 0x0a, 0x45, 0x52, 0x52,
-	0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x23, 0x12, 0x0f, 0x0a, 0x0b, 0x45, 0x52, 0x52,
-	0x5f, 0x41, 0x42, 0x4f, 0x52, 0x54, 0x45, 0x44, 0x10, 0x24, 0x12, 0x12, 0x0a, 0x0e, 0x45, 0x52,
-	0x52, 0x5f, 0x55, 0x4e, 0x45, 0x58, 0x50, 0x45, 0x43, 0x54, 0x45, 0x44, 0x10, 0x25, 0x32, 0x71,
-	0x0a, 0x0c, 0x43, 0x65, 0x72, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x12, 0x61,
-	0x0a, 0x0a, 0x56, 0x65, 0x72, 0x69, 0x66, 0x79, 0x43, 0x65, 0x72, 0x74, 0x12, 0x27, 0x2e, 0x64,
-	0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66,
-	0x69, 0x65, 0x72, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x79, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63,
-	0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65, 0x72,
-	0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
-	0x00, 0x42, 0x35, 0x48, 0x03, 0x5a, 0x31, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
-	0x6d, 0x2f, 0x69, 0x6d, 0x70, 0x65, 0x72, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x69, 0x6e, 0x63, 0x2f,
-	0x62, 0x65, 0x61, 0x63, 0x6f, 0x6e, 0x2f, 0x63, 0x6f, 0x72, 0x65, 0x2f, 0x63, 0x6c, 0x69, 0x65,
-	0x6e, 0x74, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+func (x *TLSARecordResult) GetSkipped() bool {
+	if x != nil {
+		return x.Skipped
+	}
+	return false
 }
 
-var (
-	file_dnssec_cert_verifier_proto_rawDescOnce sync.Once
-	file_dnssec_cert_verifier_proto_rawDescData = file_dnssec_cert_verifier_proto_rawDesc
-)
+func (x *TLSARecordResult) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
 
-func file_dnssec_cert_verifier_proto_rawDescGZIP() []byte {
-	file_dnssec_cert_verifier_proto_rawDescOnce.Do(func() {
-		file_dnssec_cert_verifier_proto_rawDescData = protoimpl.X.CompressGZIP(file_dnssec_cert_verifier_proto_rawDescData)
-	})
-	return file_dnssec_cert_verifier_proto_rawDescData
+type TLSADigest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Subject string `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	Digest  string `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
 }
 
-var file_dnssec_cert_verifier_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_dnssec_cert_verifier_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
-var file_dnssec_cert_verifier_proto_goTypes = []interface{}{
-	(SecurityState)(0),         // 0: dnssec_cert_verifier.SecurityState
-	(ErrorCode)(0),             // 1: dnssec_cert_verifier.ErrorCode
-	(*CertVerifyRequest)(nil),  // 2: dnssec_cert_verifier.CertVerifyRequest
-	(*CertVerifyResponse)(nil), // 3: dnssec_cert_verifier.CertVerifyResponse
-	(*Certificate)(nil),        // 4: dnssec_cert_verifier.Certificate
+func (x *TLSADigest) Reset() {
+	*x = TLSADigest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
 }
-var file_dnssec_cert_verifier_proto_depIdxs = []int32{
-	4, // 0: dnssec_cert_verifier.CertVerifyRequest.cert:type_name -> dnssec_cert_verifier.Certificate
-	4, // 1: dnssec_cert_verifier.CertVerifyResponse.verified_cert:type_name -> dnssec_cert_verifier.Certificate
-	0, // 2: dnssec_cert_verifier.CertVerifyResponse.state:type_name -> dnssec_cert_verifier.SecurityState
-	1, // 3: dnssec_cert_verifier.CertVerifyResponse.code:type_name -> dnssec_cert_verifier.ErrorCode
-	2, // 4: dnssec_cert_verifier.CertVerifier.VerifyCert:input_type -> dnssec_cert_verifier.CertVerifyRequest
-	3, // 5: dnssec_cert_verifier.CertVerifier.VerifyCert:output_type -> dnssec_cert_verifier.CertVerifyResponse
-	5, // [5:6] is the sub-list for method output_type
-	4, // [4:5] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+
+func (x *TLSADigest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func init() { file_dnssec_cert_verifier_proto_init() }
-func file_dnssec_cert_verifier_proto_init() {
-	if File_dnssec_cert_verifier_proto != nil {
-		return
+func (*TLSADigest) ProtoMessage() {}
+
+func (x *TLSADigest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_dnssec_cert_verifier_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CertVerifyRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TLSADigest.ProtoReflect.Descriptor instead.
+func (*TLSADigest) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TLSADigest) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *TLSADigest) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+type HostPort struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Port string `protobuf:"bytes,2,opt,name=port,proto3" json:"port,omitempty"`
+	// Defaults to tcp.
+	Protocol string `protobuf:"bytes,3,opt,name=protocol,proto3" json:"protocol,omitempty"`
+}
+
+func (x *HostPort) Reset() {
+	*x = HostPort{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HostPort) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostPort) ProtoMessage() {}
+
+func (x *HostPort) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_dnssec_cert_verifier_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CertVerifyResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostPort.ProtoReflect.Descriptor instead.
+func (*HostPort) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HostPort) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *HostPort) GetPort() string {
+	if x != nil {
+		return x.Port
+	}
+	return ""
+}
+
+func (x *HostPort) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+type PrefetchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hosts []*HostPort `protobuf:"bytes,1,rep,name=hosts,proto3" json:"hosts,omitempty"`
+}
+
+func (x *PrefetchRequest) Reset() {
+	*x = PrefetchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrefetchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrefetchRequest) ProtoMessage() {}
+
+func (x *PrefetchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_dnssec_cert_verifier_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrefetchRequest.ProtoReflect.Descriptor instead.
+func (*PrefetchRequest) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PrefetchRequest) GetHosts() []*HostPort {
+	if x != nil {
+		return x.Hosts
+	}
+	return nil
+}
+
+type PrefetchResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Host *HostPort `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	// UNKNOWN_ERROR if the lookup succeeded.
+	Code ErrorCode `protobuf:"varint,2,opt,name=code,proto3,enum=dnssec_cert_verifier.ErrorCode" json:"code,omitempty"`
+}
+
+func (x *PrefetchResult) Reset() {
+	*x = PrefetchResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrefetchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrefetchResult) ProtoMessage() {}
+
+func (x *PrefetchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrefetchResult.ProtoReflect.Descriptor instead.
+func (*PrefetchResult) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PrefetchResult) GetHost() *HostPort {
+	if x != nil {
+		return x.Host
+	}
+	return nil
+}
+
+func (x *PrefetchResult) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_UNKNOWN_ERROR
+}
+
+type PrefetchResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Results []*PrefetchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *PrefetchResponse) Reset() {
+	*x = PrefetchResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PrefetchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrefetchResponse) ProtoMessage() {}
+
+func (x *PrefetchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrefetchResponse.ProtoReflect.Descriptor instead.
+func (*PrefetchResponse) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PrefetchResponse) GetResults() []*PrefetchResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type HTTPSRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *HTTPSRequest) Reset() {
+	*x = HTTPSRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HTTPSRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HTTPSRequest) ProtoMessage() {}
+
+func (x *HTTPSRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HTTPSRequest.ProtoReflect.Descriptor instead.
+func (*HTTPSRequest) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *HTTPSRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type HTTPSEndpoint struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Priority uint32 `protobuf:"varint,1,opt,name=priority,proto3" json:"priority,omitempty"`
+	// Name to connect to.
+	Target string `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	// Replaces the default port if set.
+	Port          uint32   `protobuf:"varint,3,opt,name=port,proto3" json:"port,omitempty"`
+	Alpn          []string `protobuf:"bytes,4,rep,name=alpn,proto3" json:"alpn,omitempty"`
+	NoDefaultAlpn bool     `protobuf:"varint,5,opt,name=no_default_alpn,json=noDefaultAlpn,proto3" json:"no_default_alpn,omitempty"`
+	// ECHConfigList for Encrypted ClientHello.
+	EchConfig []byte `protobuf:"bytes,6,opt,name=ech_config,json=echConfig,proto3" json:"ech_config,omitempty"`
+	// Addresses in network byte order.
+	Ipv4Hints [][]byte `protobuf:"bytes,7,rep,name=ipv4_hints,json=ipv4Hints,proto3" json:"ipv4_hints,omitempty"`
+	Ipv6Hints [][]byte `protobuf:"bytes,8,rep,name=ipv6_hints,json=ipv6Hints,proto3" json:"ipv6_hints,omitempty"`
+}
+
+func (x *HTTPSEndpoint) Reset() {
+	*x = HTTPSEndpoint{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HTTPSEndpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HTTPSEndpoint) ProtoMessage() {}
+
+func (x *HTTPSEndpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HTTPSEndpoint.ProtoReflect.Descriptor instead.
+func (*HTTPSEndpoint) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *HTTPSEndpoint) GetPriority() uint32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *HTTPSEndpoint) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *HTTPSEndpoint) GetPort() uint32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *HTTPSEndpoint) GetAlpn() []string {
+	if x != nil {
+		return x.Alpn
+	}
+	return nil
+}
+
+func (x *HTTPSEndpoint) GetNoDefaultAlpn() bool {
+	if x != nil {
+		return x.NoDefaultAlpn
+	}
+	return false
+}
+
+func (x *HTTPSEndpoint) GetEchConfig() []byte {
+	if x != nil {
+		return x.EchConfig
+	}
+	return nil
+}
+
+func (x *HTTPSEndpoint) GetIpv4Hints() [][]byte {
+	if x != nil {
+		return x.Ipv4Hints
+	}
+	return nil
+}
+
+func (x *HTTPSEndpoint) GetIpv6Hints() [][]byte {
+	if x != nil {
+		return x.Ipv6Hints
+	}
+	return nil
+}
+
+type HTTPSResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Owner of the endpoints after following aliases. Connect
+	// to it as usual if there are no endpoints. It's "." if
+	// the service isn't available.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Ordered by priority.
+	Endpoints []*HTTPSEndpoint `protobuf:"bytes,2,rep,name=endpoints,proto3" json:"endpoints,omitempty"`
+	// Every answer was DNSSEC validated.
+	Secure bool `protobuf:"varint,3,opt,name=secure,proto3" json:"secure,omitempty"`
+	// UNKNOWN_ERROR if the lookup succeeded.
+	Code           ErrorCode `protobuf:"varint,4,opt,name=code,proto3,enum=dnssec_cert_verifier.ErrorCode" json:"code,omitempty"`
+	AdditionalInfo string    `protobuf:"bytes,5,opt,name=additional_info,json=additionalInfo,proto3" json:"additional_info,omitempty"`
+}
+
+func (x *HTTPSResponse) Reset() {
+	*x = HTTPSResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HTTPSResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HTTPSResponse) ProtoMessage() {}
+
+func (x *HTTPSResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HTTPSResponse.ProtoReflect.Descriptor instead.
+func (*HTTPSResponse) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *HTTPSResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HTTPSResponse) GetEndpoints() []*HTTPSEndpoint {
+	if x != nil {
+		return x.Endpoints
+	}
+	return nil
+}
+
+func (x *HTTPSResponse) GetSecure() bool {
+	if x != nil {
+		return x.Secure
+	}
+	return false
+}
+
+func (x *HTTPSResponse) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_UNKNOWN_ERROR
+}
+
+func (x *HTTPSResponse) GetAdditionalInfo() string {
+	if x != nil {
+		return x.AdditionalInfo
+	}
+	return ""
+}
+
+type Certificate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DerCerts [][]byte `protobuf:"bytes,1,rep,name=der_certs,json=derCerts,proto3" json:"der_certs,omitempty"`
+}
+
+func (x *Certificate) Reset() {
+	*x = Certificate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dnssec_cert_verifier_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Certificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Certificate) ProtoMessage() {}
+
+func (x *Certificate) ProtoReflect() protoreflect.Message {
+	mi := &file_dnssec_cert_verifier_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Certificate.ProtoReflect.Descriptor instead.
+func (*Certificate) Descriptor() ([]byte, []int) {
+	return file_dnssec_cert_verifier_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Certificate) GetDerCerts() [][]byte {
+	if x != nil {
+		return x.DerCerts
+	}
+	return nil
+}
+
+var File_dnssec_cert_verifier_proto protoreflect.FileDescriptor
+
+var file_dnssec_cert_verifier_proto_rawDesc = []byte{
+	0x0a, 0x1a, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65,
+	0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x14, 0x64, 0x6e,
+	0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69,
+	0x65, 0x72, 0x22, 0xfd, 0x01, 0x0a, 0x11, 0x43, 0x65, 0x72, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66,
+	0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74,
+	0x12, 0x35, 0x0a, 0x04, 0x63, 0x65, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21,
+	0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72,
+	0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
+	0x65, 0x52, 0x04, 0x63, 0x65, 0x72, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x77, 0x65, 0x62, 0x70, 0x6b,
+	0x69, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0e, 0x77, 0x65, 0x62, 0x70, 0x6b, 0x69, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64,
+	0x12, 0x44, 0x0a, 0x0c, 0x77, 0x65, 0x62, 0x70, 0x6b, 0x69, 0x5f, 0x63, 0x68, 0x61, 0x69, 0x6e,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f,
+	0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x0b, 0x77, 0x65, 0x62, 0x70, 0x6b,
+	0x69, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x6f, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x6f, 0x6c, 0x22, 0xd1, 0x03, 0x0a, 0x12, 0x43, 0x65, 0x72, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66,
+	0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x0d, 0x76, 0x65, 0x72,
+	0x69, 0x66, 0x69, 0x65, 0x64, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x21, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x52, 0x0c, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x43, 0x65, 0x72,
+	0x74, 0x12, 0x39, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x23, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x53, 0x65, 0x63, 0x75, 0x72, 0x69, 0x74, 0x79,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x33, 0x0a, 0x04,
+	0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x64, 0x6e, 0x73,
+	0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65,
+	0x72, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f, 0x64,
+	0x65, 0x12, 0x27, 0x0a, 0x0f, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x5f,
+	0x69, 0x6e, 0x66, 0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x61, 0x64, 0x64, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x49, 0x0a, 0x0c, 0x74, 0x6c,
+	0x73, 0x61, 0x5f, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x26, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x54, 0x4c, 0x53, 0x41, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x0b, 0x74, 0x6c, 0x73, 0x61, 0x52, 0x65,
+	0x63, 0x6f, 0x72, 0x64, 0x73, 0x12, 0x3e, 0x0a, 0x0a, 0x70, 0x69, 0x6e, 0x5f, 0x63, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x64, 0x6e, 0x73, 0x73,
+	0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72,
+	0x2e, 0x50, 0x69, 0x6e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x09, 0x70, 0x69, 0x6e, 0x43,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x61, 0x6e, 0x63, 0x68, 0x6f, 0x72, 0x5f,
+	0x73, 0x74, 0x61, 0x6c, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x61, 0x6e, 0x63,
+	0x68, 0x6f, 0x72, 0x53, 0x74, 0x61, 0x6c, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x61, 0x6e, 0x63, 0x68,
+	0x6f, 0x72, 0x5f, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x61, 0x6e, 0x63, 0x68, 0x6f, 0x72, 0x41, 0x67, 0x65, 0x53,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x22, 0x8f, 0x02, 0x0a, 0x10, 0x54, 0x4c, 0x53, 0x41, 0x52,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x75,
+	0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x75, 0x73, 0x61, 0x67,
+	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x08, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x23, 0x0a,
+	0x0d, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x54, 0x79,
+	0x70, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x3c,
+	0x0a, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x64, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x20, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x54, 0x4c, 0x53, 0x41, 0x44, 0x69, 0x67, 0x65,
+	0x73, 0x74, 0x52, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07,
+	0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x6d,
+	0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x6b, 0x69, 0x70, 0x70, 0x65,
+	0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x6b, 0x69, 0x70, 0x70, 0x65, 0x64,
+	0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22, 0x3e, 0x0a, 0x0a, 0x54, 0x4c, 0x53, 0x41,
+	0x44, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x22, 0x4e, 0x0a, 0x08, 0x48, 0x6f, 0x73, 0x74,
+	0x50, 0x6f, 0x72, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x1a, 0x0a, 0x08,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x22, 0x47, 0x0a, 0x0f, 0x50, 0x72, 0x65, 0x66,
+	0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x34, 0x0a, 0x05, 0x68,
+	0x6f, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x64, 0x6e, 0x73,
+	0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65,
+	0x72, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x52, 0x05, 0x68, 0x6f, 0x73, 0x74,
+	0x73, 0x22, 0x79, 0x0a, 0x0e, 0x50, 0x72, 0x65, 0x66, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x32, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1e, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f,
+	0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x48, 0x6f, 0x73, 0x74, 0x50, 0x6f, 0x72,
+	0x74, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x33, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63,
+	0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x45, 0x72, 0x72,
+	0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x22, 0x52, 0x0a, 0x10,
+	0x50, 0x72, 0x65, 0x66, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x3e, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x24, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f,
+	0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x50, 0x72, 0x65, 0x66, 0x65, 0x74, 0x63,
+	0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73,
+	0x22, 0x22, 0x0a, 0x0c, 0x48, 0x54, 0x54, 0x50, 0x53, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x68, 0x6f, 0x73, 0x74, 0x22, 0xf0, 0x01, 0x0a, 0x0d, 0x48, 0x54, 0x54, 0x50, 0x53, 0x45, 0x6e,
+	0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69,
+	0x74, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69,
+	0x74, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f,
+	0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x12,
+	0x0a, 0x04, 0x61, 0x6c, 0x70, 0x6e, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x61, 0x6c,
+	0x70, 0x6e, 0x12, 0x26, 0x0a, 0x0f, 0x6e, 0x6f, 0x5f, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74,
+	0x5f, 0x61, 0x6c, 0x70, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x6e, 0x6f, 0x44,
+	0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x41, 0x6c, 0x70, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x63,
+	0x68, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09,
+	0x65, 0x63, 0x68, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x1d, 0x0a, 0x0a, 0x69, 0x70, 0x76,
+	0x34, 0x5f, 0x68, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x09, 0x69,
+	0x70, 0x76, 0x34, 0x48, 0x69, 0x6e, 0x74, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x69, 0x70, 0x76, 0x36,
+	0x5f, 0x68, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x09, 0x69, 0x70,
+	0x76, 0x36, 0x48, 0x69, 0x6e, 0x74, 0x73, 0x22, 0xdc, 0x01, 0x0a, 0x0d, 0x48, 0x54, 0x54, 0x50,
+	0x53, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x41, 0x0a,
+	0x09, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x23, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76,
+	0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x48, 0x54, 0x54, 0x50, 0x53, 0x45, 0x6e, 0x64,
+	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x52, 0x09, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73,
+	0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x63, 0x75, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x06, 0x73, 0x65, 0x63, 0x75, 0x72, 0x65, 0x12, 0x33, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f,
+	0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x27, 0x0a,
+	0x0f, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x5f, 0x69, 0x6e, 0x66, 0x6f,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e,
+	0x61, 0x6c, 0x49, 0x6e, 0x66, 0x6f, 0x22, 0x2a, 0x0a, 0x0b, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x72, 0x5f, 0x63, 0x65, 0x72,
+	0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x08, 0x64, 0x65, 0x72, 0x43, 0x65, 0x72,
+	0x74, 0x73, 0x2a, 0x5f, 0x0a, 0x09, 0x50, 0x69, 0x6e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12,
+	0x0f, 0x0a, 0x0b, 0x50, 0x49, 0x4e, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00,
+	0x12, 0x0b, 0x0a, 0x07, 0x50, 0x49, 0x4e, 0x5f, 0x4e, 0x45, 0x57, 0x10, 0x01, 0x12, 0x11, 0x0a,
+	0x0d, 0x50, 0x49, 0x4e, 0x5f, 0x55, 0x4e, 0x43, 0x48, 0x41, 0x4e, 0x47, 0x45, 0x44, 0x10, 0x02,
+	0x12, 0x0f, 0x0a, 0x0b, 0x50, 0x49, 0x4e, 0x5f, 0x52, 0x4f, 0x54, 0x41, 0x54, 0x45, 0x44, 0x10,
+	0x03, 0x12, 0x10, 0x0a, 0x0c, 0x50, 0x49, 0x4e, 0x5f, 0x52, 0x45, 0x50, 0x4c, 0x41, 0x43, 0x45,
+	0x44, 0x10, 0x04, 0x2a, 0x34, 0x0a, 0x0d, 0x53, 0x65, 0x63, 0x75, 0x72, 0x69, 0x74, 0x79, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x42, 0x4f, 0x47, 0x55, 0x53, 0x10, 0x00, 0x12,
+	0x0a, 0x0a, 0x06, 0x53, 0x45, 0x43, 0x55, 0x52, 0x45, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x49,
+	0x4e, 0x53, 0x45, 0x43, 0x55, 0x52, 0x45, 0x10, 0x02, 0x2a, 0x9b, 0x09, 0x0a, 0x09, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x11, 0x0a, 0x0d, 0x55, 0x4e, 0x4b, 0x4e, 0x4f,
+	0x57, 0x4e, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52,
+	0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x42, 0x4f, 0x47, 0x55, 0x53, 0x10, 0x01,
+	0x12, 0x20, 0x0a, 0x1c, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x53,
+	0x49, 0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45, 0x5f, 0x45, 0x58, 0x50, 0x49, 0x52, 0x45, 0x44,
+	0x10, 0x02, 0x12, 0x20, 0x0a, 0x1c, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43,
+	0x5f, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49,
+	0x4e, 0x47, 0x10, 0x03, 0x12, 0x1d, 0x0a, 0x19, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53,
+	0x45, 0x43, 0x5f, 0x44, 0x4e, 0x53, 0x4b, 0x45, 0x59, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e,
+	0x47, 0x10, 0x04, 0x12, 0x1b, 0x0a, 0x17, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45,
+	0x43, 0x5f, 0x4e, 0x53, 0x45, 0x43, 0x5f, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x05,
+	0x12, 0x2b, 0x0a, 0x27, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x50,
+	0x49, 0x4e, 0x4e, 0x45, 0x44, 0x5f, 0x4b, 0x45, 0x59, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x49, 0x4e,
+	0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x43, 0x48, 0x41, 0x49, 0x4e, 0x10, 0x06, 0x12, 0x1b, 0x0a,
+	0x17, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x46, 0x45, 0x54, 0x43,
+	0x48, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x07, 0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52,
+	0x52, 0x5f, 0x44, 0x4e, 0x53, 0x53, 0x45, 0x43, 0x5f, 0x46, 0x45, 0x54, 0x43, 0x48, 0x5f, 0x54,
+	0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54, 0x10, 0x08, 0x12, 0x16, 0x0a, 0x12, 0x45, 0x52,
+	0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x49, 0x53, 0x5f, 0x53, 0x59, 0x4e, 0x43, 0x49, 0x4e, 0x47,
+	0x10, 0x09, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x4e, 0x4f,
+	0x5f, 0x50, 0x45, 0x45, 0x52, 0x53, 0x10, 0x0a, 0x12, 0x1a, 0x0a, 0x16, 0x45, 0x52, 0x52, 0x5f,
+	0x48, 0x4e, 0x53, 0x5f, 0x50, 0x45, 0x45, 0x52, 0x5f, 0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f,
+	0x55, 0x54, 0x10, 0x0b, 0x12, 0x1a, 0x0a, 0x16, 0x45, 0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f,
+	0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x0c,
+	0x12, 0x22, 0x0a, 0x1e, 0x45, 0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f, 0x48, 0x49, 0x50, 0x35,
+	0x5f, 0x48, 0x41, 0x4e, 0x44, 0x4c, 0x45, 0x52, 0x5f, 0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f,
+	0x55, 0x54, 0x10, 0x0d, 0x12, 0x1f, 0x0a, 0x1b, 0x45, 0x52, 0x52, 0x5f, 0x48, 0x4e, 0x53, 0x5f,
+	0x48, 0x49, 0x50, 0x35, 0x5f, 0x48, 0x41, 0x4e, 0x44, 0x4c, 0x45, 0x52, 0x5f, 0x46, 0x41, 0x49,
+	0x4c, 0x45, 0x44, 0x10, 0x0e, 0x12, 0x24, 0x0a, 0x20, 0x45, 0x52, 0x52, 0x5f, 0x54, 0x52, 0x55,
+	0x53, 0x54, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45,
+	0x53, 0x54, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x0f, 0x12, 0x27, 0x0a, 0x23, 0x45,
+	0x52, 0x52, 0x5f, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45,
+	0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x5f, 0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f,
+	0x55, 0x54, 0x10, 0x10, 0x12, 0x25, 0x0a, 0x21, 0x45, 0x52, 0x52, 0x5f, 0x54, 0x52, 0x55, 0x53,
+	0x54, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53,
+	0x54, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x11, 0x12, 0x26, 0x0a, 0x22, 0x45,
+	0x52, 0x52, 0x5f, 0x54, 0x52, 0x55, 0x53, 0x54, 0x5f, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45,
+	0x5f, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49,
+	0x44, 0x10, 0x12, 0x12, 0x36, 0x0a, 0x32, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x53,
+	0x45, 0x43, 0x55, 0x52, 0x45, 0x5f, 0x52, 0x45, 0x53, 0x4f, 0x4c, 0x56, 0x45, 0x52, 0x5f, 0x48,
+	0x4f, 0x53, 0x54, 0x4e, 0x41, 0x4d, 0x45, 0x5f, 0x52, 0x45, 0x53, 0x4f, 0x4c, 0x55, 0x54, 0x49,
+	0x4f, 0x4e, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x13, 0x12, 0x15, 0x0a, 0x11, 0x45,
+	0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x54, 0x49, 0x4d, 0x45, 0x44, 0x5f, 0x4f, 0x55, 0x54,
+	0x10, 0x14, 0x12, 0x19, 0x0a, 0x15, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x53, 0x45,
+	0x52, 0x56, 0x45, 0x52, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x15, 0x12, 0x1e, 0x0a,
+	0x1a, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x4d, 0x41, 0x4c, 0x46, 0x4f, 0x52, 0x4d,
+	0x45, 0x44, 0x5f, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45, 0x10, 0x16, 0x12, 0x1d, 0x0a,
+	0x19, 0x45, 0x52, 0x52, 0x5f, 0x44, 0x4e, 0x53, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54,
+	0x5f, 0x43, 0x41, 0x4e, 0x43, 0x45, 0x4c, 0x4c, 0x45, 0x44, 0x10, 0x17, 0x12, 0x20, 0x0a, 0x1c,
+	0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x43, 0x4f, 0x4d, 0x4d, 0x4f, 0x4e, 0x5f,
+	0x4e, 0x41, 0x4d, 0x45, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x18, 0x12, 0x19,
+	0x0a, 0x15, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x44, 0x41, 0x54, 0x45, 0x5f,
+	0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x19, 0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52, 0x52,
+	0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x41, 0x55, 0x54, 0x48, 0x4f, 0x52, 0x49, 0x54, 0x59, 0x5f,
+	0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x10, 0x1a, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x52, 0x52,
+	0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x52, 0x45, 0x56, 0x4f, 0x4b, 0x45, 0x44, 0x10, 0x1b, 0x12,
+	0x14, 0x0a, 0x10, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x49, 0x4e, 0x56, 0x41,
+	0x4c, 0x49, 0x44, 0x10, 0x1c, 0x12, 0x25, 0x0a, 0x21, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52,
+	0x54, 0x5f, 0x57, 0x45, 0x41, 0x4b, 0x5f, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x54, 0x55, 0x52, 0x45,
+	0x5f, 0x41, 0x4c, 0x47, 0x4f, 0x52, 0x49, 0x54, 0x48, 0x4d, 0x10, 0x1d, 0x12, 0x1c, 0x0a, 0x18,
+	0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x4e, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x49,
+	0x51, 0x55, 0x45, 0x5f, 0x4e, 0x41, 0x4d, 0x45, 0x10, 0x1e, 0x12, 0x15, 0x0a, 0x11, 0x45, 0x52,
+	0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x57, 0x45, 0x41, 0x4b, 0x5f, 0x4b, 0x45, 0x59, 0x10,
+	0x1f, 0x12, 0x26, 0x0a, 0x22, 0x45, 0x52, 0x52, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x4e, 0x41,
+	0x4d, 0x45, 0x5f, 0x43, 0x4f, 0x4e, 0x53, 0x54, 0x52, 0x41, 0x49, 0x4e, 0x54, 0x5f, 0x56, 0x49,
+	0x4f, 0x4c, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x20, 0x12, 0x1e, 0x0a, 0x1a, 0x45, 0x52, 0x52,
+	0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x49, 0x54, 0x59, 0x5f, 0x54,
+	0x4f, 0x4f, 0x5f, 0x4c, 0x4f, 0x4e, 0x47, 0x10, 0x21, 0x12, 0x27, 0x0a, 0x23, 0x45, 0x52, 0x52,
+	0x5f, 0x43, 0x45, 0x52, 0x54, 0x5f, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x49, 0x4e, 0x54, 0x45,
+	0x52, 0x43, 0x45, 0x50, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x42, 0x4c, 0x4f, 0x43, 0x4b, 0x45, 0x44,
+	0x10, 0x22, 0x12, 0x0e, 0x0a, 0x0a, 0x45, 0x52, 0x52, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44,
+	0x10, 0x23, 0x12, 0x0f, 0x0a, 0x0b, 0x45, 0x52, 0x52, 0x5f, 0x41, 0x42, 0x4f, 0x52, 0x54, 0x45,
+	0x44, 0x10, 0x24, 0x12, 0x12, 0x0a, 0x0e, 0x45, 0x52, 0x52, 0x5f, 0x55, 0x4e, 0x45, 0x58, 0x50,
+	0x45, 0x43, 0x54, 0x45, 0x44, 0x10, 0x25, 0x32, 0xa8, 0x02, 0x0a, 0x0c, 0x43, 0x65, 0x72, 0x74,
+	0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x12, 0x61, 0x0a, 0x0a, 0x56, 0x65, 0x72, 0x69,
+	0x66, 0x79, 0x43, 0x65, 0x72, 0x74, 0x12, 0x27, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f,
+	0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65,
+	0x72, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x28, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65,
+	0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x56, 0x65, 0x72, 0x69, 0x66,
+	0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x5b, 0x0a, 0x08, 0x50,
+	0x72, 0x65, 0x66, 0x65, 0x74, 0x63, 0x68, 0x12, 0x25, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63,
+	0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x50,
+	0x72, 0x65, 0x66, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x26,
+	0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72,
+	0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x50, 0x72, 0x65, 0x66, 0x65, 0x74, 0x63, 0x68, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x58, 0x0a, 0x0b, 0x4c, 0x6f, 0x6f, 0x6b,
+	0x75, 0x70, 0x48, 0x54, 0x54, 0x50, 0x53, 0x12, 0x22, 0x2e, 0x64, 0x6e, 0x73, 0x73, 0x65, 0x63,
+	0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x72, 0x2e, 0x48,
+	0x54, 0x54, 0x50, 0x53, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x64, 0x6e,
+	0x73, 0x73, 0x65, 0x63, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x69, 0x66, 0x69,
+	0x65, 0x72, 0x2e, 0x48, 0x54, 0x54, 0x50, 0x53, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x00, 0x42, 0x35, 0x48, 0x03, 0x5a, 0x31, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
+	0x6f, 0x6d, 0x2f, 0x69, 0x6d, 0x70, 0x65, 0x72, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x69, 0x6e, 0x63,
+	0x2f, 0x62, 0x65, 0x61, 0x63, 0x6f, 0x6e, 0x2f, 0x63, 0x6f, 0x72, 0x65, 0x2f, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_dnssec_cert_verifier_proto_rawDescOnce sync.Once
+	file_dnssec_cert_verifier_proto_rawDescData = file_dnssec_cert_verifier_proto_rawDesc
+)
+
+func file_dnssec_cert_verifier_proto_rawDescGZIP() []byte {
+	file_dnssec_cert_verifier_proto_rawDescOnce.Do(func() {
+		file_dnssec_cert_verifier_proto_rawDescData = protoimpl.X.CompressGZIP(file_dnssec_cert_verifier_proto_rawDescData)
+	})
+	return file_dnssec_cert_verifier_proto_rawDescData
+}
+
+var file_dnssec_cert_verifier_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_dnssec_cert_verifier_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_dnssec_cert_verifier_proto_goTypes = []interface{}{
+	(PinChange)(0),             // 0: dnssec_cert_verifier.PinChange
+	(SecurityState)(0),         // 1: dnssec_cert_verifier.SecurityState
+	(ErrorCode)(0),             // 2: dnssec_cert_verifier.ErrorCode
+	(*CertVerifyRequest)(nil),  // 3: dnssec_cert_verifier.CertVerifyRequest
+	(*CertVerifyResponse)(nil), // 4: dnssec_cert_verifier.CertVerifyResponse
+	(*TLSARecordResult)(nil),   // 5: dnssec_cert_verifier.TLSARecordResult
+	(*TLSADigest)(nil),         // 6: dnssec_cert_verifier.TLSADigest
+	(*HostPort)(nil),           // 7: dnssec_cert_verifier.HostPort
+	(*PrefetchRequest)(nil),    // 8: dnssec_cert_verifier.PrefetchRequest
+	(*PrefetchResult)(nil),     // 9: dnssec_cert_verifier.PrefetchResult
+	(*PrefetchResponse)(nil),   // 10: dnssec_cert_verifier.PrefetchResponse
+	(*HTTPSRequest)(nil),       // 11: dnssec_cert_verifier.HTTPSRequest
+	(*HTTPSEndpoint)(nil),      // 12: dnssec_cert_verifier.HTTPSEndpoint
+	(*HTTPSResponse)(nil),      // 13: dnssec_cert_verifier.HTTPSResponse
+	(*Certificate)(nil),        // 14: dnssec_cert_verifier.Certificate
+}
+var file_dnssec_cert_verifier_proto_depIdxs = []int32{
+	14, // 0: dnssec_cert_verifier.CertVerifyRequest.cert:type_name -> dnssec_cert_verifier.Certificate
+	14, // 1: dnssec_cert_verifier.CertVerifyRequest.webpki_chain:type_name -> dnssec_cert_verifier.Certificate
+	14, // 2: dnssec_cert_verifier.CertVerifyResponse.verified_cert:type_name -> dnssec_cert_verifier.Certificate
+	1,  // 3: dnssec_cert_verifier.CertVerifyResponse.state:type_name -> dnssec_cert_verifier.SecurityState
+	2,  // 4: dnssec_cert_verifier.CertVerifyResponse.code:type_name -> dnssec_cert_verifier.ErrorCode
+	5,  // 5: dnssec_cert_verifier.CertVerifyResponse.tlsa_records:type_name -> dnssec_cert_verifier.TLSARecordResult
+	0,  // 6: dnssec_cert_verifier.CertVerifyResponse.pin_change:type_name -> dnssec_cert_verifier.PinChange
+	6,  // 7: dnssec_cert_verifier.TLSARecordResult.computed:type_name -> dnssec_cert_verifier.TLSADigest
+	7,  // 8: dnssec_cert_verifier.PrefetchRequest.hosts:type_name -> dnssec_cert_verifier.HostPort
+	7,  // 9: dnssec_cert_verifier.PrefetchResult.host:type_name -> dnssec_cert_verifier.HostPort
+	2,  // 10: dnssec_cert_verifier.PrefetchResult.code:type_name -> dnssec_cert_verifier.ErrorCode
+	9,  // 11: dnssec_cert_verifier.PrefetchResponse.results:type_name -> dnssec_cert_verifier.PrefetchResult
+	12, // 12: dnssec_cert_verifier.HTTPSResponse.endpoints:type_name -> dnssec_cert_verifier.HTTPSEndpoint
+	2,  // 13: dnssec_cert_verifier.HTTPSResponse.code:type_name -> dnssec_cert_verifier.ErrorCode
+	3,  // 14: dnssec_cert_verifier.CertVerifier.VerifyCert:input_type -> dnssec_cert_verifier.CertVerifyRequest
+	8,  // 15: dnssec_cert_verifier.CertVerifier.Prefetch:input_type -> dnssec_cert_verifier.PrefetchRequest
+	11, // 16: dnssec_cert_verifier.CertVerifier.LookupHTTPS:input_type -> dnssec_cert_verifier.HTTPSRequest
+	4,  // 17: dnssec_cert_verifier.CertVerifier.VerifyCert:output_type -> dnssec_cert_verifier.CertVerifyResponse
+	10, // 18: dnssec_cert_verifier.CertVerifier.Prefetch:output_type -> dnssec_cert_verifier.PrefetchResponse
+	13, // 19: dnssec_cert_verifier.CertVerifier.LookupHTTPS:output_type -> dnssec_cert_verifier.HTTPSResponse
+	17, // [17:20] is the sub-list for method output_type
+	14, // [14:17] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_dnssec_cert_verifier_proto_init() }
+func file_dnssec_cert_verifier_proto_init() {
+	if File_dnssec_cert_verifier_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_dnssec_cert_verifier_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CertVerifyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CertVerifyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TLSARecordResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TLSADigest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HostPort); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrefetchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrefetchResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrefetchResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HTTPSRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HTTPSEndpoint); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HTTPSResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dnssec_cert_verifier_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Certificate); i {
 			case 0:
 				return &v.state
@@ -618,8 +1608,8 @@ func file_dnssec_cert_verifier_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_dnssec_cert_verifier_proto_rawDesc,
-			NumEnums:      2,
-			NumMessages:   3,
+			NumEnums:      3,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,6 +19,12 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CertVerifierClient interface {
 	VerifyCert(ctx context.Context, in *CertVerifyRequest, opts ...grpc.CallOption) (*CertVerifyResponse, error)
+	// Warms the caches for hosts a page is about to connect to.
+	// Returns once all lookups finished.
+	Prefetch(ctx context.Context, in *PrefetchRequest, opts ...grpc.CallOption) (*PrefetchResponse, error)
+	// Resolves the HTTPS records of a handshake host for ALPN,
+	// Encrypted ClientHello and alternative endpoints.
+	LookupHTTPS(ctx context.Context, in *HTTPSRequest, opts ...grpc.CallOption) (*HTTPSResponse, error)
 }
 
 type certVerifierClient struct {
@@ -38,11 +44,35 @@ func (c *certVerifierClient) VerifyCert(ctx context.Context, in *CertVerifyReque
 	return out, nil
 }
 
+func (c *certVerifierClient) Prefetch(ctx context.Context, in *PrefetchRequest, opts ...grpc.CallOption) (*PrefetchResponse, error) {
+	out := new(PrefetchResponse)
+	err := c.cc.Invoke(ctx, "/dnssec_cert_verifier.CertVerifier/Prefetch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *certVerifierClient) LookupHTTPS(ctx context.Context, in *HTTPSRequest, opts ...grpc.CallOption) (*HTTPSResponse, error) {
+	out := new(HTTPSResponse)
+	err := c.cc.Invoke(ctx, "/dnssec_cert_verifier.CertVerifier/LookupHTTPS", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CertVerifierServer is the server API for CertVerifier service.
 // All implementations must embed UnimplementedCertVerifierServer
 // for forward compatibility
 type CertVerifierServer interface {
 	VerifyCert(context.Context, *CertVerifyRequest) (*CertVerifyResponse, error)
+	// Warms the caches for hosts a page is about to connect to.
+	// Returns once all lookups finished.
+	Prefetch(context.Context, *PrefetchRequest) (*PrefetchResponse, error)
+	// Resolves the HTTPS records of a handshake host for ALPN,
+	// Encrypted ClientHello and alternative endpoints.
+	LookupHTTPS(context.Context, *HTTPSRequest) (*HTTPSResponse, error)
 	mustEmbedUnimplementedCertVerifierServer()
 }
 
@@ -53,6 +83,12 @@ type UnimplementedCertVerifierServer struct {
 func (UnimplementedCertVerifierServer) VerifyCert(context.Context, *CertVerifyRequest) (*CertVerifyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VerifyCert not implemented")
 }
+func (UnimplementedCertVerifierServer) Prefetch(context.Context, *PrefetchRequest) (*PrefetchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Prefetch not implemented")
+}
+func (UnimplementedCertVerifierServer) LookupHTTPS(context.Context, *HTTPSRequest) (*HTTPSResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupHTTPS not implemented")
+}
 func (UnimplementedCertVerifierServer) mustEmbedUnimplementedCertVerifierServer() {}
 
 // UnsafeCertVerifierServer may be embedded to opt out of forward compatibility for this service.
@@ -84,6 +120,42 @@ func _CertVerifier_VerifyCert_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CertVerifier_Prefetch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrefetchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertVerifierServer).Prefetch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dnssec_cert_verifier.CertVerifier/Prefetch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertVerifierServer).Prefetch(ctx, req.(*PrefetchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CertVerifier_LookupHTTPS_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HTTPSRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertVerifierServer).LookupHTTPS(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dnssec_cert_verifier.CertVerifier/LookupHTTPS",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertVerifierServer).LookupHTTPS(ctx, req.(*HTTPSRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CertVerifier_ServiceDesc is the grpc.ServiceDesc for CertVerifier service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -95,6 +167,14 @@ var CertVerifier_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "VerifyCert",
 			Handler:    _CertVerifier_VerifyCert_Handler,
 		},
+		{
+			MethodName: "Prefetch",
+			Handler:    _CertVerifier_Prefetch_Handler,
+		},
+		{
+			MethodName: "LookupHTTPS",
+			Handler:    _CertVerifier_LookupHTTPS_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "dnssec_cert_verifier.proto",
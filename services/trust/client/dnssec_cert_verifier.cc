@@ -52,6 +52,19 @@ void DNSSECCertVerifier::Request::OnRemoteResponse(
     int net_error = beacon::core::MapClientErrorToNetError(response.code());
     CHECK(net_error != net::OK);
 
+    // Which TLSA records were compared and the data computed
+    // for the certificate chain, in debug builds with --v=1.
+    DVLOG(1) << "DNSSECCertVerifier " << params.hostname() << ": "
+             << response.additional_info();
+    for (const auto& record : response.tlsa_records()) {
+      DVLOG(1) << "  TLSA " << record.usage() << " " << record.selector() << " "
+               << record.matching_type() << " " << record.expected()
+               << (record.matched() ? " matched" : " " + record.reason());
+      for (const auto& computed : record.computed()) {
+        DVLOG(1) << "    " << computed.subject() << ": " << computed.digest();
+      }
+    }
+
     std::move(callback).Run(net_error);
     return;
   }
@@ -59,6 +72,28 @@ void DNSSECCertVerifier::Request::OnRemoteResponse(
   // Response must be secure
   CHECK(state == dnssec_cert_verifier::SECURE);
 
+  // DANE has no revocation so a TLSA set replaced
+  // at once may mean the previous key was compromised.
+  if (response.pin_change() == dnssec_cert_verifier::PIN_REPLACED) {
+    LOG(WARNING) << "DNSSECCertVerifier " << params.hostname()
+                 << ": every TLSA record was replaced since the last visit";
+  }
+
+  // The node hasn't caught up yet so the TLD's trust
+  // anchor came from the cache.
+  if (response.anchor_stale()) {
+    LOG(WARNING) << "DNSSECCertVerifier " << params.hostname()
+                 << ": verified with a trust anchor fetched "
+                 << response.anchor_age_seconds() << "s ago";
+  }
+
+  // ICANN domains pinned with TLSA usages 0 or 1 keep
+  // the WebPKI result.
+  if (error_from_upstream == net::OK && beacon::IsHostnameICANN(params.hostname())) {
+    std::move(callback).Run(net::OK);
+    return;
+  }
+
   scoped_refptr<net::X509Certificate> verified_cert = net::X509Certificate::CreateFromBuffer(
       bssl::UpRef(cert->cert_buffer()), {});
 
@@ -89,12 +124,12 @@ bool IsHostnameNonDNS(const std::string& hostname) {
     return true;
 
   // IP addresses aren't valid DNSSEC hostnames.  
-  if (host_info.IsIPAddress())
-    return true;   
+  return host_info.IsIPAddress();
+}
 
-  // Ignore ICANN TLDs
+bool IsHostnameICANN(const std::string& hostname) {
   return net::registry_controlled_domains::HostHasRegistryControlledDomain(
-       canonical_name, net::registry_controlled_domains::EXCLUDE_UNKNOWN_REGISTRIES,
+       hostname, net::registry_controlled_domains::EXCLUDE_UNKNOWN_REGISTRIES,
        net::registry_controlled_domains::EXCLUDE_PRIVATE_REGISTRIES);
 }
 
@@ -133,6 +168,13 @@ void DNSSECCertVerifier::OnRequestFinished(const net::CertVerifier::RequestParam
                          net::CertVerifyResult* verify_result,
                          std::unique_ptr<net::CertVerifier::Request>* out_req,
                          int error) {
+    // ICANN domains are only checked for DANE
+    // pinning once WebPKI verification succeeded.
+    if (error != net::OK && beacon::IsHostnameICANN(params.hostname())) {
+        std::move(callback).Run(error);
+        return;
+    }
+
     // If the cert error was fatal we call
     // the original callback skipping 
     // DNSSEC verification.         
@@ -162,6 +204,10 @@ void DNSSECCertVerifier::OnRequestFinished(const net::CertVerifier::RequestParam
     // should allow the same cert/name to work for other ports.
     grpcRequest.set_port("443");
 
+    // RequestParams doesn't say whether the cert came
+    // over TCP or QUIC so the TCP records are used.
+    grpcRequest.set_protocol("tcp");
+
     // Packaging the cert for the verify request.
     auto cert = params.certificate();
     dnssec_cert_verifier::Certificate* grpcCert = grpcRequest.mutable_cert();
@@ -174,6 +220,22 @@ void DNSSECCertVerifier::OnRequestFinished(const net::CertVerifier::RequestParam
        grpcCert->add_der_certs(inter.data(), inter.size());
     }
 
+    // The chain built by the upstream verifier is needed
+    // to enforce TLSA usages 0 (PKIX-TA) and 1 (PKIX-EE).
+    grpcRequest.set_webpki_verified(error == net::OK);
+    if (error == net::OK && verify_result->verified_cert) {
+      auto verified = verify_result->verified_cert;
+      dnssec_cert_verifier::Certificate* webpkiChain = grpcRequest.mutable_webpki_chain();
+
+      auto verifiedLeaf = net::x509_util::CryptoBufferAsStringPiece(verified->cert_buffer());
+      webpkiChain->add_der_certs(verifiedLeaf.data(), verifiedLeaf.size());
+
+      for (const auto&buffer : verified->intermediate_buffers()) {
+        auto inter = net::x509_util::CryptoBufferAsStringPiece(buffer.get());
+        webpkiChain->add_der_certs(inter.data(), inter.size());
+      }
+    }
+
     // Take a weak pointer to the request because deletion of the request
     // is what signals cancellation. If the request is cancelled, the
     // callback won't be called, thus avoiding UAF, because |verify_result|
@@ -185,8 +185,8 @@ hsk_chain_get_orphan(const hsk_chain_t *chain, const uint8_t *hash) {
   return hsk_map_get(&chain->orphans, hash);
 }
 
-const uint8_t *
-hsk_chain_safe_root(const hsk_chain_t *chain) {
+uint32_t
+hsk_chain_safe_height(const hsk_chain_t *chain) {
   // The tree is committed on an interval.
   // Mainnet is 72 blocks, meaning at height 72,
   // the name set of the past 72 blocks are
@@ -208,13 +208,20 @@ hsk_chain_safe_root(const hsk_chain_t *chain) {
 
   uint32_t height = (uint32_t)chain->height - mod;
 
-  hsk_header_t *prev = hsk_chain_get_by_height(chain, height);
-  assert(prev);
-
   hsk_chain_log(chain,
     "using safe height of %u for resolution\n",
     height);
 
+  return height;
+}
+
+const uint8_t *
+hsk_chain_safe_root(const hsk_chain_t *chain) {
+  uint32_t height = hsk_chain_safe_height(chain);
+
+  hsk_header_t *prev = hsk_chain_get_by_height(chain, height);
+  assert(prev);
+
   return prev->name_root;
 }
 
@@ -301,9 +301,14 @@ hsk_proof_msg_read(uint8_t **data, size_t *data_len, hsk_proof_msg_t *msg) {
   if (!read_bytes(data, data_len, msg->key, 32))
     return false;
 
+  const uint8_t *raw_proof = *data;
+
   if (!hsk_proof_read(data, data_len, &msg->proof))
     return false;
 
+  msg->raw_proof = raw_proof;
+  msg->raw_proof_len = *data - raw_proof;
+
   return true;
 }
 
@@ -482,6 +487,8 @@ hsk_msg_init(hsk_msg_t *msg) {
       memset(m->root, 0, 32);
       memset(m->key, 0, 32);
       hsk_proof_init(&m->proof);
+      m->raw_proof = NULL;
+      m->raw_proof_len = 0;
       break;
     }
   }
@@ -91,6 +91,10 @@ typedef struct {
   uint8_t root[32];
   uint8_t key[32];
   hsk_proof_t proof;
+  // Encoded proof. Points into the message
+  // buffer and is only valid while handled.
+  const uint8_t *raw_proof;
+  size_t raw_proof_len;
 } hsk_proof_msg_t;
 
 uint8_t
@@ -499,11 +499,44 @@ hsk_pool_pick_prover(hsk_pool_t *pool, const uint8_t *name_hash) {
   return deterministic;
 }
 
-int
-hsk_pool_resolve(
+static void
+hsk_name_req_done(
+  hsk_name_req_t *req,
+  int status,
+  bool exists,
+  const uint8_t *data,
+  size_t data_len,
+  const hsk_name_proof_t *proof
+) {
+  if (req->proof_callback) {
+    req->proof_callback(
+      req->name,
+      status,
+      exists,
+      data,
+      data_len,
+      proof,
+      req->arg
+    );
+    return;
+  }
+
+  req->callback(
+    req->name,
+    status,
+    exists,
+    data,
+    data_len,
+    req->arg
+  );
+}
+
+static int
+hsk_pool_request(
   hsk_pool_t *pool,
   const char *name,
   hsk_resolve_cb callback,
+  hsk_resolve_proof_cb proof_callback,
   const void *arg
 ) {
   hsk_pool_log(pool, "sending proof request for: %s.\n", name);
@@ -513,7 +546,11 @@ hsk_pool_resolve(
     return HSK_ETIMEOUT;
   }
 
-  const uint8_t *root = hsk_chain_safe_root(&pool->chain);
+  uint32_t height = hsk_chain_safe_height(&pool->chain);
+  hsk_header_t *safe = hsk_chain_get_by_height(&pool->chain, height);
+  assert(safe);
+
+  const uint8_t *root = safe->name_root;
   hsk_name_req_t *req = malloc(sizeof(hsk_name_req_t));
 
   if (!req)
@@ -525,7 +562,9 @@ hsk_pool_resolve(
 
   memcpy(req->root, root, 32);
 
+  req->height = height;
   req->callback = callback;
+  req->proof_callback = proof_callback;
   req->arg = (void *)arg;
   req->time = hsk_now();
   req->next = NULL;
@@ -560,6 +599,26 @@ hsk_pool_resolve(
   return hsk_peer_send_getproof(peer, req->hash, root);
 }
 
+int
+hsk_pool_resolve(
+  hsk_pool_t *pool,
+  const char *name,
+  hsk_resolve_cb callback,
+  const void *arg
+) {
+  return hsk_pool_request(pool, name, callback, NULL, arg);
+}
+
+int
+hsk_pool_resolve_proof(
+  hsk_pool_t *pool,
+  const char *name,
+  hsk_resolve_proof_cb callback,
+  const void *arg
+) {
+  return hsk_pool_request(pool, name, NULL, callback, arg);
+}
+
 static void
 hsk_pool_resend(hsk_pool_t *pool) {
   if (!hsk_chain_synced(&pool->chain))
@@ -657,14 +716,7 @@ hsk_pool_merge_reqs(hsk_pool_t *pool, hsk_map_t *map) {
     for (req = pool->pending; req; req = next) {
       next = req->next;
 
-      req->callback(
-        req->name,
-        HSK_ETIMEOUT,
-        false,
-        NULL,
-        0,
-        req->arg
-      );
+      hsk_name_req_done(req, HSK_ETIMEOUT, false, NULL, 0, NULL);
 
       free(req);
     }
@@ -692,14 +744,7 @@ hsk_peer_timeout_reqs(hsk_peer_t *peer) {
     for (; req; req = next) {
       next = req->next;
 
-      req->callback(
-        req->name,
-        HSK_ETIMEOUT,
-        false,
-        NULL,
-        0,
-        req->arg
-      );
+      hsk_name_req_done(req, HSK_ETIMEOUT, false, NULL, 0, NULL);
 
       free(req);
     }
@@ -1559,17 +1604,17 @@ hsk_peer_handle_proof(hsk_peer_t *peer, const hsk_proof_msg_t *msg) {
 
   hsk_name_req_t *req, *next;
 
+  hsk_name_proof_t proof = {
+    .root = msg->root,
+    .height = reqs->height,
+    .data = msg->raw_proof,
+    .data_len = msg->raw_proof_len
+  };
+
   for (req = reqs; req; req = next) {
     next = req->next;
 
-    req->callback(
-      req->name,
-      HSK_SUCCESS,
-      exists,
-      data,
-      data_len,
-      req->arg
-    );
+    hsk_name_req_done(req, HSK_SUCCESS, exists, data, data_len, &proof);
 
     free(req);
   }
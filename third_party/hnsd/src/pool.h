@@ -41,11 +41,32 @@ typedef void (*hsk_resolve_cb)(
   const void *arg
 );
 
+// Urkel proof a name was resolved with.
+typedef struct hsk_name_proof_s {
+  const uint8_t *root;
+  uint32_t height;
+  const uint8_t *data;
+  size_t data_len;
+} hsk_name_proof_t;
+
+// Like hsk_resolve_cb, proof is NULL on failure.
+typedef void (*hsk_resolve_proof_cb)(
+  const char *name,
+  int status,
+  bool exists,
+  const uint8_t *data,
+  size_t data_len,
+  const hsk_name_proof_t *proof,
+  const void *arg
+);
+
 typedef struct hsk_name_req_s {
   char name[256];
   uint8_t hash[32];
   uint8_t root[32];
+  uint32_t height;
   hsk_resolve_cb callback;
+  hsk_resolve_proof_cb proof_callback;
   void *arg;
   int64_t time;
   struct hsk_name_req_s *next;
@@ -151,4 +172,12 @@ hsk_pool_resolve(
   hsk_resolve_cb callback,
   const void *arg
 );
+
+int
+hsk_pool_resolve_proof(
+  hsk_pool_t *pool,
+  const char *name,
+  hsk_resolve_proof_cb callback,
+  const void *arg
+);
 #endif
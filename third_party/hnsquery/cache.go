@@ -0,0 +1,263 @@
+package hnsquery
+
+import (
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"github.com/miekg/dns"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheSize     = 1000
+	defaultCacheMaxBytes = 4 << 20
+	defaultCacheMaxTTL   = time.Hour
+)
+
+// CacheStats counters for the resolver message cache
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Bytes   int
+}
+
+type cacheKey struct {
+	name  string
+	qtype uint16
+	cd    bool
+}
+
+type cacheEntry struct {
+	msg    *dns.Msg
+	size   int
+	stored time.Time
+	expire time.Time
+}
+
+// messageCache caches validated positive and negative
+// responses. A nil cache is valid and caches nothing.
+type messageCache struct {
+	sync.Mutex
+	entries  *simplelru.LRU
+	bytes    int
+	maxBytes int
+	maxTTL   time.Duration
+	hits     uint64
+	misses   uint64
+
+	// for testing
+	now func() time.Time
+}
+
+func newMessageCache(size, maxBytes int, maxTTL time.Duration) (*messageCache, error) {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultCacheMaxBytes
+	}
+	if maxTTL <= 0 {
+		maxTTL = defaultCacheMaxTTL
+	}
+
+	c := &messageCache{
+		maxBytes: maxBytes,
+		maxTTL:   maxTTL,
+		now:      time.Now,
+	}
+
+	// called with c locked
+	onEvict := func(key interface{}, value interface{}) {
+		c.bytes -= value.(*cacheEntry).size
+	}
+
+	var err error
+	if c.entries, err = simplelru.NewLRU(size, onEvict); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func newCacheKey(qname string, qtype uint16, cd bool) cacheKey {
+	return cacheKey{name: strings.ToLower(dns.Fqdn(qname)), qtype: qtype, cd: cd}
+}
+
+// get returns a copy of a cached response with
+// TTLs decremented by the time spent in cache
+func (c *messageCache) get(key cacheKey) (*dns.Msg, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.now()
+	if v, ok := c.entries.Get(key); ok {
+		entry := v.(*cacheEntry)
+		if now.Before(entry.expire) {
+			c.hits++
+			msg := entry.msg.Copy()
+			ageRecords(msg, now.Sub(entry.stored))
+			return msg, true
+		}
+
+		c.entries.Remove(key)
+	}
+
+	c.misses++
+	return nil, false
+}
+
+// add caches a validated response. sigExpire is the earliest
+// RRSIG expiration seen while validating and may be zero.
+func (c *messageCache) add(key cacheKey, msg *dns.Msg, sigExpire time.Time) {
+	if c == nil || msg.Truncated ||
+		(msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError) {
+		return
+	}
+
+	now := c.now()
+	ttl := cacheTTL(msg)
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	if !sigExpire.IsZero() && sigExpire.Sub(now) < ttl {
+		ttl = sigExpire.Sub(now)
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	entry := &cacheEntry{
+		msg:    msg.Copy(),
+		size:   msg.Len(),
+		stored: now,
+		expire: now.Add(ttl),
+	}
+	entry.msg.Id = 0
+
+	if entry.size > c.maxBytes {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries.Remove(key)
+	c.entries.Add(key, entry)
+	c.bytes += entry.size
+
+	for c.bytes > c.maxBytes {
+		c.entries.RemoveOldest()
+	}
+}
+
+// removeBelow removes responses for names at or below zone
+func (c *messageCache) removeBelow(zone string) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, key := range c.entries.Keys() {
+		if dns.IsSubDomain(zone, key.(cacheKey).name) {
+			c.entries.Remove(key)
+		}
+	}
+}
+
+func (c *messageCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	return CacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: c.entries.Len(),
+		Bytes:   c.bytes,
+	}
+}
+
+// cacheTTL returns the lowest TTL in the answer and authority sections
+// including the original TTL of their signatures (RFC 4035 5.3.3).
+// Negative answers are capped by the SOA minimum (RFC 2308) and aren't
+// cached without a SOA.
+func cacheTTL(msg *dns.Msg) time.Duration {
+	negative := len(msg.Answer) == 0
+	hasSOA := false
+	var ttl uint32
+	first := true
+
+	min := func(v uint32) {
+		if first || v < ttl {
+			ttl = v
+			first = false
+		}
+	}
+
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns} {
+		for _, rr := range section {
+			min(rr.Header().Ttl)
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				min(sig.OrigTtl)
+			}
+			if soa, ok := rr.(*dns.SOA); ok && negative {
+				hasSOA = true
+				min(soa.Minttl)
+			}
+		}
+	}
+
+	if first || (negative && !hasSOA) {
+		return 0
+	}
+
+	return time.Duration(ttl) * time.Second
+}
+
+// earliestSigExpiration returns the earliest RRSIG expiration
+// in msg or the zero time if it has no signatures
+func earliestSigExpiration(msg *dns.Msg, now time.Time) time.Time {
+	var expire time.Time
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns} {
+		for _, rr := range section {
+			sig, ok := rr.(*dns.RRSIG)
+			if !ok {
+				continue
+			}
+
+			t := dnssec.SigExpiration(sig, now)
+			if expire.IsZero() || t.Before(expire) {
+				expire = t
+			}
+		}
+	}
+
+	return expire
+}
+
+func ageRecords(msg *dns.Msg, age time.Duration) {
+	elapsed := uint32(age / time.Second)
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			if rr.Header().Ttl > elapsed {
+				rr.Header().Ttl -= elapsed
+			} else {
+				rr.Header().Ttl = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package hnsquery
+
+import (
+	"context"
+	"github.com/hashicorp/golang-lru"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"github.com/miekg/dns"
+	"testing"
+	"time"
+)
+
+func testCacheMsg(t *testing.T, qname string, qtype uint16, rcode int, answer, ns []string) *dns.Msg {
+	t.Helper()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(qname, qtype)
+	msg.Response = true
+	msg.Rcode = rcode
+
+	for _, s := range answer {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Answer = append(msg.Answer, rr)
+	}
+	for _, s := range ns {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Ns = append(msg.Ns, rr)
+	}
+
+	return msg
+}
+
+func TestMessageCache_TTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	soa := "example. 300 IN SOA ns.example. admin.example. 1 3600 600 86400 30"
+
+	tests := []struct {
+		name      string
+		msg       *dns.Msg
+		maxTTL    time.Duration
+		sigExpire time.Time
+		ttl       time.Duration
+	}{
+		{
+			name:   "positive",
+			msg:    testCacheMsg(t, "a.example.", dns.TypeA, dns.RcodeSuccess, []string{"a.example. 300 IN A 192.0.2.1", "a.example. 120 IN A 192.0.2.2"}, nil),
+			maxTTL: time.Hour,
+			ttl:    120 * time.Second,
+		},
+		{
+			name:   "max ttl",
+			msg:    testCacheMsg(t, "a.example.", dns.TypeA, dns.RcodeSuccess, []string{"a.example. 300 IN A 192.0.2.1"}, nil),
+			maxTTL: time.Minute,
+			ttl:    time.Minute,
+		},
+		{
+			name:      "signature expiration",
+			msg:       testCacheMsg(t, "a.example.", dns.TypeA, dns.RcodeSuccess, []string{"a.example. 300 IN A 192.0.2.1"}, nil),
+			maxTTL:    time.Hour,
+			sigExpire: now.Add(10 * time.Second),
+			ttl:       10 * time.Second,
+		},
+		{
+			name:   "original ttl",
+			msg:    testCacheMsg(t, "a.example.", dns.TypeA, dns.RcodeSuccess, []string{"a.example. 300 IN A 192.0.2.1", "a.example. 300 IN RRSIG A 13 2 60 20300101000000 20200101000000 12345 example. AAAA"}, nil),
+			maxTTL: time.Hour,
+			ttl:    time.Minute,
+		},
+		{
+			name:   "nxdomain soa minimum",
+			msg:    testCacheMsg(t, "b.example.", dns.TypeA, dns.RcodeNameError, nil, []string{soa}),
+			maxTTL: time.Hour,
+			ttl:    30 * time.Second,
+		},
+		{
+			name:   "nodata without soa",
+			msg:    testCacheMsg(t, "b.example.", dns.TypeA, dns.RcodeSuccess, nil, nil),
+			maxTTL: time.Hour,
+		},
+		{
+			name:   "servfail",
+			msg:    testCacheMsg(t, "b.example.", dns.TypeA, dns.RcodeServerFailure, nil, []string{soa}),
+			maxTTL: time.Hour,
+		},
+		{
+			name:      "expired signature",
+			msg:       testCacheMsg(t, "a.example.", dns.TypeA, dns.RcodeSuccess, []string{"a.example. 300 IN A 192.0.2.1"}, nil),
+			maxTTL:    time.Hour,
+			sigExpire: now.Add(-time.Second),
+		},
+	}
+
+	for _, test := range tests {
+		c, err := newMessageCache(10, 0, test.maxTTL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.now = func() time.Time { return now }
+
+		key := newCacheKey(test.msg.Question[0].Name, test.msg.Question[0].Qtype, false)
+		c.add(key, test.msg, test.sigExpire)
+
+		v, ok := c.entries.Get(key)
+		if test.ttl == 0 {
+			if ok {
+				t.Fatalf("%s: should not be cached", test.name)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("%s: should be cached", test.name)
+		}
+		if got := v.(*cacheEntry).expire.Sub(now); got != test.ttl {
+			t.Fatalf("%s: got ttl %v, want %v", test.name, got, test.ttl)
+		}
+	}
+}
+
+func TestMessageCache_Get(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c, err := newMessageCache(10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return now }
+
+	msg := testCacheMsg(t, "a.example.", dns.TypeA, dns.RcodeSuccess, []string{"a.example. 300 IN A 192.0.2.1"}, nil)
+	msg.AuthenticatedData = true
+	c.add(newCacheKey("A.example", dns.TypeA, false), msg, time.Time{})
+
+	if _, ok := c.get(newCacheKey("a.example.", dns.TypeA, true)); ok {
+		t.Fatal("checking disabled is part of the key")
+	}
+
+	now = now.Add(100 * time.Second)
+	re, ok := c.get(newCacheKey("a.example.", dns.TypeA, false))
+	if !ok {
+		t.Fatal("want cache hit")
+	}
+	if !re.AuthenticatedData || re.Answer[0].Header().Ttl != 200 {
+		t.Fatalf("got ad = %v, ttl = %d, want aged secure answer", re.AuthenticatedData, re.Answer[0].Header().Ttl)
+	}
+
+	// cached message must not be modified by callers
+	re.Answer = nil
+
+	now = now.Add(199 * time.Second)
+	if re, ok = c.get(newCacheKey("a.example.", dns.TypeA, false)); !ok || len(re.Answer) != 1 {
+		t.Fatal("want cache hit")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := c.get(newCacheKey("a.example.", dns.TypeA, false)); ok {
+		t.Fatal("entry should expire")
+	}
+
+	stats := c.stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("got stats %+v", stats)
+	}
+}
+
+func TestMessageCache_MaxBytes(t *testing.T) {
+	msg := func(name string) *dns.Msg {
+		return testCacheMsg(t, name, dns.TypeA, dns.RcodeSuccess, []string{name + " 300 IN A 192.0.2.1"}, nil)
+	}
+
+	size := msg("a.example.").Len()
+	c, err := newMessageCache(10, 2*size, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.example.", "b.example.", "c.example."} {
+		c.add(newCacheKey(name, dns.TypeA, false), msg(name), time.Time{})
+	}
+
+	if _, ok := c.get(newCacheKey("a.example.", dns.TypeA, false)); ok {
+		t.Fatal("oldest entry should be evicted")
+	}
+	if stats := c.stats(); stats.Entries != 2 || stats.Bytes != 2*size {
+		t.Fatalf("got stats %+v, want 2 entries", stats)
+	}
+}
+
+func TestResolver_Cache(t *testing.T) {
+	zoneCuts, _ := lru.New(10)
+	cache, _ := newMessageCache(10, 0, 0)
+
+	queries := 0
+	r := &Resolver{
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+			return dnssec.NewZone(cut, nil)
+		},
+		zoneCuts: zoneCuts,
+		cache:    cache,
+		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			q := msg.Question[0]
+			if q.Qtype == dns.TypeSOA {
+				return testCacheMsg(t, q.Name, q.Qtype, dns.RcodeSuccess,
+					[]string{"example. 300 IN SOA ns.example. admin.example. 1 3600 600 86400 300"}, nil), nil
+			}
+
+			queries++
+			return testCacheMsg(t, q.Name, q.Qtype, dns.RcodeSuccess, []string{q.Name + " 300 IN A 192.0.2.1"}, nil), nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		msg, err := r.Query(context.Background(), "www.example.", dns.TypeA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msg.Answer) != 1 {
+			t.Fatalf("got answer %v", msg.Answer)
+		}
+	}
+
+	if queries != 1 {
+		t.Fatalf("got %d queries, want 1", queries)
+	}
+	if stats := r.CacheStats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Fatalf("got stats %+v", stats)
+	}
+}
@@ -1,6 +1,7 @@
 package hnsquery
 
 /*
+   #include <stdint.h>
    #include <stdio.h>
    #include <stdlib.h>
 */
@@ -26,11 +27,14 @@ type Callback struct {
 	Func func(rrs []dns.RR, err error)
 }
 
-type CallbackFunc func(rrs []dns.RR, err error)
+type CallbackFunc func(rrs []dns.RR, proof *ZoneProof, err error)
 
 type cgoHSKAccess struct {
 	callbacks map[string][]*CallbackFunc
 	sync.RWMutex
+
+	// events of the pool state
+	events EventFeed
 }
 
 type ctxTable struct {
@@ -107,7 +111,8 @@ func (c *cgoHSKAccess) removeCallback(name string, cb *CallbackFunc) {
 }
 
 //export cgoAfterResolve
-func cgoAfterResolve(name *C.char, status C.int, exists C.int, data unsafe.Pointer, dataLen C.size_t, v unsafe.Pointer) {
+func cgoAfterResolve(name *C.char, status C.int, exists C.int, data unsafe.Pointer, dataLen C.size_t,
+	root unsafe.Pointer, height C.uint32_t, proofData unsafe.Pointer, proofLen C.size_t, v unsafe.Pointer) {
 	// hns_ctx is passed to v
 	// we need ctx->id to fetch callbacks for this ctx
 	ctxId := getContextId(v)
@@ -136,18 +141,28 @@ func cgoAfterResolve(name *C.char, status C.int, exists C.int, data unsafe.Point
 				continue
 			}
 
-			(*cb)(nil, fmt.Errorf("after resolve %s: %w", goName, hskCodeToError(status)))
+			(*cb)(nil, nil, fmt.Errorf("after resolve %s: %w", goName, hskCodeToError(status)))
 		}
 		return
 	}
 
+	var proof *ZoneProof
+	if proofLen > 0 {
+		proof = &ZoneProof{
+			Name:   goName,
+			Proof:  C.GoBytes(proofData, C.int(proofLen)),
+			Height: uint32(height),
+		}
+		copy(proof.Root[:], C.GoBytes(root, 32))
+	}
+
 	if exists != 1 || size == 0 {
 		for _, cb := range callbacks {
 			if cb == nil {
 				continue
 			}
 
-			(*cb)(nil, nil)
+			(*cb)(nil, proof, nil)
 		}
 		return
 	}
@@ -159,12 +174,16 @@ func cgoAfterResolve(name *C.char, status C.int, exists C.int, data unsafe.Point
 				continue
 			}
 
-			(*cb)(nil, err)
+			(*cb)(nil, nil, err)
 		}
 		return
 	}
 
 	buf := C.GoBytes(data, size)
+	if proof != nil {
+		proof.Resource = buf
+	}
+
 	r := &resource.Resource{}
 	err := r.Decode(bytes.NewReader(buf))
 
@@ -175,7 +194,7 @@ func cgoAfterResolve(name *C.char, status C.int, exists C.int, data unsafe.Point
 				continue
 			}
 
-			(*cb)(nil, err)
+			(*cb)(nil, nil, err)
 		}
 		return
 	}
@@ -187,8 +206,34 @@ func cgoAfterResolve(name *C.char, status C.int, exists C.int, data unsafe.Point
 		// for now allocate new set of rrs
 		// for every callback
 		rrs := resourceToDNS(goName, r)
-		(*cb)(rrs, nil)
+		(*cb)(rrs, proof, nil)
+	}
+}
+
+//export cgoAfterPoolUpdate
+func cgoAfterPoolUpdate(ready C.int, height C.uint32_t, progress C.float, totalPeers C.int, activePeers C.int,
+	root unsafe.Pointer, v unsafe.Pointer) {
+	ctxId := getContextId(v)
+
+	ctxMap.RLock()
+	hnsCgo, ok := ctxMap.contexts[ctxId]
+	ctxMap.RUnlock()
+
+	if !ok || hnsCgo == nil {
+		return
 	}
+
+	state := NodeState{
+		Ready:       ready != 0,
+		Progress:    float32(progress),
+		Height:      uint64(height),
+		TotalPeers:  int(totalPeers),
+		ActivePeers: int(activePeers),
+	}
+	copy(state.NameRoot[:], C.GoBytes(root, 32))
+
+	// called from the event loop so this must not block
+	hnsCgo.events.Update(state)
 }
 
 func ipToSynth(ip net.IP) string {
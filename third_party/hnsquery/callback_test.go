@@ -8,9 +8,9 @@ import (
 func TestCallback(t *testing.T) {
 	c := newCGOHSK()
 
-	cb1 := CallbackFunc(func(rrs []dns.RR, err error) {})
-	cb2 := CallbackFunc(func(rrs []dns.RR, err error) {})
-	cb3 := CallbackFunc(func(rrs []dns.RR, err error) {})
+	cb1 := CallbackFunc(func(rrs []dns.RR, proof *ZoneProof, err error) {})
+	cb2 := CallbackFunc(func(rrs []dns.RR, proof *ZoneProof, err error) {})
+	cb3 := CallbackFunc(func(rrs []dns.RR, proof *ZoneProof, err error) {})
 
 	// addCallback should return true
 	// for initial
@@ -5,11 +5,11 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
-	lru "github.com/hashicorp/golang-lru"
+	"github.com/imperviousinc/hnsquery/dnssec"
 	"github.com/miekg/dns"
-	"log"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
 )
 
 type CertVerifyInfo struct {
@@ -18,35 +18,125 @@ type CertVerifyInfo struct {
 	Protocol         string
 	RawCerts         [][]byte
 	DisableNameCheck bool
+
+	// WebPKIVerified the platform verifier accepted RawCerts.
+	// TLSA usages 0 and 1 only constrain a verified chain
+	// and are ignored otherwise.
+	WebPKIVerified bool
+
+	// WebPKIChain the chain built by the platform verifier leaf
+	// first. If empty RawCerts is used.
+	WebPKIChain [][]byte
 }
 
 var ErrCertVerifyFailed = errors.New("verify failed")
 var ErrDNSAuthFailed = errors.New("dns authentication failed")
 
+// ErrCertNameMismatch the certificate isn't valid for the host
+var ErrCertNameMismatch = fmt.Errorf("certificate name mismatch: %w", ErrCertVerifyFailed)
+
+// ErrUnsupportedService the port or protocol can't be used for a TLSA lookup
+var ErrUnsupportedService = fmt.Errorf("unsupported service: %w", ErrCertVerifyFailed)
+
 type CertVerifier interface {
 	Verify(ctx context.Context, verifyInfo *CertVerifyInfo) (bool, error)
 }
 
+// DNSCertVerifier verifies certificates using TLSA records. Lookups
+// are cached by the resolver.
 type DNSCertVerifier struct {
-	Resolver  *Resolver
-	tlsaCache *lru.Cache
+	Resolver *Resolver
+
+	// Policy rules checked after a TLSA record matched.
+	// If nil only the host name is checked.
+	Policy *CertPolicy
+
+	// Pins if set keeps the history of TLSA sets seen
+	// to report sets that were replaced at once
+	Pins *PinHistory
+
+	// concurrent lookups of the same TLSA name
+	tlsaFlights flightGroup
 }
 
+// HostPort a service whose TLSA records can be prefetched.
+// Protocol defaults to tcp.
+type HostPort struct {
+	Host     string
+	Port     string
+	Protocol string
+}
+
+// maxPrefetch max concurrent lookups of a Prefetch call
+const maxPrefetch = 8
+
 func NewDNSCertVerifier(resolver *Resolver) (*DNSCertVerifier, error) {
-	c, err := lru.New(100)
+	d := &DNSCertVerifier{
+		Resolver: resolver,
+	}
+
+	return d, nil
+}
+
+// Prefetch looks up the TLSA records of hosts concurrently to warm the
+// trust anchor, zone cut and message caches so that verifying them later
+// doesn't wait on the network. It returns once all lookups finished with
+// the error of each host or nil.
+func (d *DNSCertVerifier) Prefetch(ctx context.Context, hosts []HostPort) []error {
+	errs := make([]error, len(hosts))
+	sem := make(chan struct{}, maxPrefetch)
+
+	var wg sync.WaitGroup
+	for i, hp := range hosts {
+		wg.Add(1)
+		go func(i int, hp HostPort) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			errs[i] = d.prefetch(ctx, hp)
+		}(i, hp)
+	}
+
+	wg.Wait()
+	return errs
+}
+
+func (d *DNSCertVerifier) prefetch(ctx context.Context, hp HostPort) error {
+	if hp.Host == "" {
+		return fmt.Errorf("missing host: %w", ErrCertVerifyFailed)
+	}
+
+	protocol := hp.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	port, protocol, err := checkService(hp.Port, protocol)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	d := &DNSCertVerifier{
-		Resolver:  resolver,
-		tlsaCache: c,
+	_, err = d.lookupTLSAWithRetry(ctx, port, protocol, dns.Fqdn(strings.ToLower(hp.Host)))
+	return err
+}
+
+// lookupTLSAWithRetry concurrent callers asking for the
+// same name share a single lookup
+func (d *DNSCertVerifier) lookupTLSAWithRetry(ctx context.Context, port, proto, name string) ([]*dns.TLSA, error) {
+	key := port + "/" + proto + "/" + strings.ToLower(name)
+	rrs, err := d.tlsaFlights.do(ctx, key, func() (interface{}, error) {
+		return d.lookupTLSA(ctx, port, proto, name)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return d, nil
+	return rrs.([]*dns.TLSA), nil
 }
 
-func (d *DNSCertVerifier) lookupTLSAWithRetry(ctx context.Context, port, proto, name string) (rrs []*dns.TLSA, err error) {
+func (d *DNSCertVerifier) lookupTLSA(ctx context.Context, port, proto, name string) (rrs []*dns.TLSA, err error) {
 	for i := 0; i < 3; i++ {
 		rrs, err = d.lookupTLSAWithDowngrade(ctx, port, proto, name)
 		if err == nil {
@@ -66,15 +156,6 @@ func (d *DNSCertVerifier) lookupTLSAWithDowngrade(ctx context.Context, port, pro
 	}
 	qname = strings.ToLower(qname)
 
-	if rrs, ok := d.tlsaCache.Get(qname); ok {
-		rc := rrs.(*recordCache)
-		if time.Now().Before(rc.expire) {
-			log.Printf("tlsa cache hit for name: %s, port: %s, protocol: %s", name, port, proto)
-			return rc.rrs, nil
-		}
-		d.tlsaCache.Remove(qname)
-	}
-
 	// if zone is insecure, we ignore TLSA lookup result
 	// some nameservers don't behave well
 	// when asked about unfamiliar record types
@@ -101,16 +182,6 @@ func (d *DNSCertVerifier) lookupTLSAWithDowngrade(ctx context.Context, port, pro
 		return nil, nil
 	}
 
-	if tlsaErr == nil {
-		ttl := time.Second * 60
-		if len(tlsaRecords) > 0 {
-			ttl = time.Second * time.Duration(tlsaRecords[0].Hdr.Ttl)
-		}
-		d.tlsaCache.Add(qname, &recordCache{
-			rrs:    tlsaRecords,
-			expire: time.Now().Add(ttl),
-		})
-	}
 	return tlsaRecords, tlsaErr
 }
 
@@ -120,6 +191,11 @@ func (d *DNSCertVerifier) lookupTLSAInternal(ctx context.Context, qname string)
 		return nil, err
 	}
 
+	return tlsaFromMsg(msg)
+}
+
+// tlsaFromMsg the TLSA records of a validated answer
+func tlsaFromMsg(msg *dns.Msg) ([]*dns.TLSA, error) {
 	// ignore if insecure
 	if !msg.AuthenticatedData {
 		return nil, nil
@@ -127,7 +203,7 @@ func (d *DNSCertVerifier) lookupTLSAInternal(ctx context.Context, qname string)
 
 	// hard fail if lookup isn't successful
 	if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
-		return nil, fmt.Errorf("received non-success rcode: %d", msg.Rcode)
+		return nil, fmt.Errorf("received non-success rcode: %d: %w", msg.Rcode, ErrServerFailure)
 	}
 
 	var tlsas []*dns.TLSA
@@ -140,74 +216,238 @@ func (d *DNSCertVerifier) lookupTLSAInternal(ctx context.Context, qname string)
 	if len(tlsas) == 0 && len(msg.Answer) > 0 {
 		// could be a partial CNAME response
 		// but we expect resolver to give a full answer
-		return nil, fmt.Errorf("got a response with no tlsa records")
+		return nil, fmt.Errorf("got a response with no tlsa records: %w", ErrMalformedResponse)
 	}
 
 	return tlsas, nil
 }
 
 func (d *DNSCertVerifier) Verify(ctx context.Context, verifyInfo *CertVerifyInfo) (bool, error) {
+	report, err := d.VerifyWithReport(ctx, verifyInfo)
+	return report.Secure, err
+}
+
+// VerifyWithReport verifies the certificate like Verify and reports
+// how every TLSA record compared to it. The report is never nil.
+func (d *DNSCertVerifier) VerifyWithReport(ctx context.Context, verifyInfo *CertVerifyInfo) (*VerifyReport, error) {
+	return d.verify(ctx, verifyInfo, d.lookupTLSAWithRetry)
+}
+
+// VerifyWithChain verifies the certificate using the TLSA records from
+// the dnssec_chain TLS extension data RFC 9102 sent by the server. The
+// chain is validated offline so no lookups are made.
+func (d *DNSCertVerifier) VerifyWithChain(ctx context.Context, verifyInfo *CertVerifyInfo, extension []byte) (*VerifyReport, error) {
+	return d.verify(ctx, verifyInfo, func(ctx context.Context, port, proto, name string) ([]*dns.TLSA, error) {
+		ext, err := dnssec.ParseChainExtension(extension)
+		if err != nil {
+			return nil, validationError(name, err)
+		}
+
+		qname, err := dns.TLSAName(name, port, proto)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format: %w", ErrDNSFatal)
+		}
+
+		msg, err := d.Resolver.VerifyAuthChain(ctx, qname, dns.TypeTLSA, ext.Records)
+		if err != nil {
+			return nil, err
+		}
+
+		return tlsaFromMsg(msg)
+	})
+}
+
+// tlsaLookupFunc fetches the TLSA records of a service
+type tlsaLookupFunc func(ctx context.Context, port, proto, name string) ([]*dns.TLSA, error)
+
+func (d *DNSCertVerifier) verify(ctx context.Context, verifyInfo *CertVerifyInfo, lookup tlsaLookupFunc) (*VerifyReport, error) {
 	host := strings.ToLower(verifyInfo.Host)
+	report := &VerifyReport{
+		Host:     host,
+		Port:     verifyInfo.Port,
+		Protocol: verifyInfo.Protocol,
+	}
 
 	if len(verifyInfo.RawCerts) == 0 {
-		return false, fmt.Errorf("no certificates specified: %w", ErrCertVerifyFailed)
+		return report, fmt.Errorf("no certificates specified: %w", ErrCertVerifyFailed)
 	}
 
-	// currently, we only verify DANE-EE so we need
-	// the leaf certificate
+	// DANE-EE only needs the leaf certificate
+	// DANE-TA also uses the rest of the chain
 	leaf := verifyInfo.RawCerts[0]
 	if leaf == nil {
-		return false, fmt.Errorf("no leaf certificate: %w", ErrCertVerifyFailed)
+		return report, fmt.Errorf("no leaf certificate: %w", ErrCertVerifyFailed)
 	}
 
 	if verifyInfo.Host == "" || verifyInfo.Port == "" || verifyInfo.Protocol == "" {
-		return false, fmt.Errorf("missing host, port or protocol: %w", ErrCertVerifyFailed)
+		return report, fmt.Errorf("missing host, port or protocol: %w", ErrCertVerifyFailed)
 	}
 
+	port, protocol, err := checkService(verifyInfo.Port, verifyInfo.Protocol)
+	if err != nil {
+		return report, err
+	}
+	report.Protocol = protocol
+
 	cert, err := x509.ParseCertificate(leaf)
 	if err != nil {
-		return false, fmt.Errorf("cert parse error: %w", ErrCertVerifyFailed)
+		return report, fmt.Errorf("cert parse error: %w", ErrCertVerifyFailed)
 	}
 
 	// name checks
 	if !verifyInfo.DisableNameCheck {
 		if err := cert.VerifyHostname(host); err != nil {
-			return false, fmt.Errorf("%v: %w", err, ErrCertVerifyFailed)
+			return report, fmt.Errorf("%v: %w", err, ErrCertNameMismatch)
 		}
 	}
 
 	// fetch TLSA records
-	rrs, err := d.lookupTLSAWithRetry(ctx, verifyInfo.Port, verifyInfo.Protocol, dns.Fqdn(host))
+	rrs, err := lookup(ctx, port, protocol, dns.Fqdn(host))
+	if zone := d.Resolver.CachedTrustAnchor(host); zone != nil {
+		report.AnchorFetched, report.AnchorStale = zone.Fetched, zone.Stale
+	}
 	if err != nil {
-		return false, err
+		return report, err
 	}
 
 	// no records we can't
 	// verify this certificate
 	if len(rrs) == 0 {
-		return false, nil
+		return report, nil
 	}
 
-	supportedUsage := false
+	c := &tlsaCheck{info: verifyInfo, host: host, leaf: cert}
+	usable := false
+
+	// multiple TLSA records may exist
+	// all of them are compared for the report
 	for _, rr := range rrs {
-		// multiple TLSA records may exist
-		// only usage 3 is supported
-		// unsupported usages are ignored
-		if rr.Usage == 3 {
-			supportedUsage = true
-
-			if rr.Verify(cert) == nil {
-				// success
-				return true, nil
-			}
+		result, err := c.check(rr)
+		if err != nil {
+			return report, err
 		}
+
+		report.Records = append(report.Records, result)
+		usable = usable || !result.Skipped
+		report.Secure = report.Secure || result.Matched
 	}
 
-	// no TLSA records with usage 3
+	if report.Secure {
+		daneEE := false
+		for _, result := range report.Records {
+			daneEE = daneEE || (result.Matched && result.Usage == 3)
+		}
+
+		if err := d.Policy.Check(cert, host, daneEE); err != nil {
+			report.Secure = false
+			return report, err
+		}
+
+		// DANE has no revocation so a set replaced at once is
+		// reported in case the old key was compromised. Only
+		// sets that authenticated a connection are recorded.
+		qname, _ := dns.TLSAName(dns.Fqdn(host), port, protocol)
+		report.PinChange = d.Pins.Observe(qname, rrs)
+
+		return report, nil
+	}
+
+	// no usable TLSA records
 	// it's safe to downgrade
-	if !supportedUsage {
-		return false, nil
+	if !usable {
+		return report, nil
+	}
+
+	return report, fmt.Errorf("no matching tlsa record: %w", ErrDNSAuthFailed)
+}
+
+// VerifyService verifies chain for any service on host using the TLSA
+// records at _port._proto.host e.g. _25._tcp for SMTP or _443._udp
+// for QUIC. The chain is leaf first.
+func (d *DNSCertVerifier) VerifyService(ctx context.Context, host, port, proto string, chain [][]byte) (bool, error) {
+	return d.Verify(ctx, &CertVerifyInfo{
+		Host:     host,
+		Port:     port,
+		Protocol: proto,
+		RawCerts: chain,
+	})
+}
+
+// checkService RFC 6698 3 the port must be a decimal port
+// number and the protocol tcp, udp or sctp
+func checkService(port, protocol string) (string, string, error) {
+	protocol = strings.ToLower(protocol)
+	switch protocol {
+	case "tcp", "udp", "sctp":
+	default:
+		return "", "", fmt.Errorf("protocol %q: %w", protocol, ErrUnsupportedService)
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("port %q: %w", port, ErrUnsupportedService)
+	}
+
+	return strconv.Itoa(n), protocol, nil
+}
+
+// parseChain parses the certificates presented after the leaf
+func parseChain(leaf *x509.Certificate, rawCerts [][]byte) ([]*x509.Certificate, error) {
+	chain := []*x509.Certificate{leaf}
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, fmt.Errorf("chain cert parse error: %w", ErrCertVerifyFailed)
+		}
+		chain = append(chain, cert)
+	}
+
+	return chain, nil
+}
+
+// webPKIChain the chain validated by the platform verifier
+func webPKIChain(leaf *x509.Certificate, verifyInfo *CertVerifyInfo) ([]*x509.Certificate, error) {
+	if len(verifyInfo.WebPKIChain) == 0 {
+		return parseChain(leaf, verifyInfo.RawCerts[1:])
+	}
+
+	chain, err := parseChain(leaf, verifyInfo.WebPKIChain)
+	if err != nil {
+		return nil, err
+	}
+
+	// the verified chain must be for the same leaf
+	if !chain[1].Equal(leaf) {
+		return nil, fmt.Errorf("webpki chain doesn't start with the leaf: %w", ErrCertVerifyFailed)
+	}
+
+	return chain[1:], nil
+}
+
+// verifyTA RFC 7671 5.2 DANE-TA the leaf must chain up to ta
+// which was found in the presented chain. The leaf name is checked
+// against host since the trust anchor may issue for other names.
+func verifyTA(ta *x509.Certificate, chain []*x509.Certificate, host string, checkName bool) error {
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if checkName {
+		opts.DNSName = host
+	}
+
+	opts.Roots.AddCert(ta)
+	for _, cert := range chain[1:] {
+		if cert.Equal(ta) {
+			break
+		}
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err := chain[0].Verify(opts); err != nil {
+		return fmt.Errorf("dane-ta %s: %v: %w", ta.Subject, err, ErrDNSAuthFailed)
 	}
 
-	return false, fmt.Errorf("no matching tlsa record: %w", ErrDNSAuthFailed)
+	return nil
 }
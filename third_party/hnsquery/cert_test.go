@@ -2,13 +2,23 @@ package hnsquery
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"github.com/imperviousinc/hnsquery/dnssec"
 	"github.com/miekg/dns"
+	"io/ioutil"
+	"math/big"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -52,21 +62,21 @@ func TestNewDNSCertVerifier(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r.TrustAnchorFunc = func(ctx context.Context, cut string) (*dnssec.Zone, bool, error) {
+	r.TrustAnchorPointHandler = func(ctx context.Context, cut string) (*dnssec.Zone, error) {
 		if cut == "proofofconcept." {
 			fmt.Println("hot lookup")
 			time.Sleep(500 * time.Millisecond)
 			ds, _ := dns.NewRR("proofofconcept.         21600   IN      DS      60767 15 2 FAF50B8DC0DED5B28E5388F5047805C7417678BE7CAC3AB5DF93823E 9220D87B")
 			zone, _ := dnssec.NewZone(cut, []dns.RR{ds})
 			zone.Expire.Add(10 * time.Second)
-			return zone, true, nil
+			return zone, nil
 		}
 
 		if cut == "." {
-			return nil, false, errors.New("not supported")
+			return nil, errors.New("not supported")
 		}
 
-		return nil, false, nil
+		return nil, nil
 	}
 
 	verify, err := NewDNSCertVerifier(r)
@@ -121,12 +131,12 @@ func TestIntegrationFullVerify(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	r.TrustAnchorFunc = func(ctx context.Context, cut string) (*dnssec.Zone, bool, error) {
+	r.TrustAnchorPointHandler = func(ctx context.Context, cut string) (*dnssec.Zone, error) {
 		if cut == "proofofconcept." {
 			fmt.Println("hot lookup")
 			rrs, err := client.GetZone(ctx, strings.TrimSuffix(cut, "."))
 			if err != nil {
-				return nil, false, err
+				return nil, err
 			}
 			var dsSet []dns.RR
 			for _, rr := range rrs {
@@ -137,14 +147,14 @@ func TestIntegrationFullVerify(t *testing.T) {
 
 			zone, _ := dnssec.NewZone(cut, dsSet)
 			zone.Expire.Add(10 * time.Second)
-			return zone, true, nil
+			return zone, nil
 		}
 
 		if cut == "." {
-			return nil, false, errors.New("not supported")
+			return nil, errors.New("not supported")
 		}
 
-		return nil, false, nil
+		return nil, nil
 	}
 
 	verify, err := NewDNSCertVerifier(r)
@@ -178,3 +188,568 @@ func TestIntegrationFullVerify(t *testing.T) {
 
 	fmt.Println("done")
 }
+
+// testIssue creates a certificate signed by parent or
+// a self signed CA if parent is nil
+func testIssue(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	} else {
+		tmpl.DNSNames = []string{name}
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func TestDNSCertVerifier_DANETA(t *testing.T) {
+	ca, caKey := testIssue(t, "Test CA", nil, nil)
+	other, otherKey := testIssue(t, "Other CA", nil, nil)
+	leaf, _ := testIssue(t, "www.example", ca, caKey)
+	otherLeaf, _ := testIssue(t, "www.example", other, otherKey)
+	mailLeaf, _ := testIssue(t, "mail.example", ca, caKey)
+
+	tlsa := &dns.TLSA{Hdr: dns.RR_Header{Name: "_443._tcp.www.example.", Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+	if err := tlsa.Sign(2, 1, 1, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestZoneSigner(t)
+	r := s.resolver(map[string][]dns.RR{
+		"_443._tcp.www.example.":  s.sign(tlsa.String()),
+		"_443._tcp.mail.example.": s.sign(strings.Replace(tlsa.String(), "www", "mail", 1)),
+	})
+	v, _ := NewDNSCertVerifier(r)
+
+	tests := []struct {
+		name  string
+		host  string
+		chain []*x509.Certificate
+		want  error
+	}{
+		{"leaf issued by the trust anchor", "www.example", []*x509.Certificate{leaf, ca}, nil},
+		{"trust anchor not presented", "www.example", []*x509.Certificate{leaf}, ErrDNSAuthFailed},
+		{"issued by another ca", "www.example", []*x509.Certificate{otherLeaf, other}, ErrDNSAuthFailed},
+		{"leaf not issued by the trust anchor", "www.example", []*x509.Certificate{otherLeaf, ca}, ErrDNSAuthFailed},
+		{"another name issued by the trust anchor", "mail.example", []*x509.Certificate{mailLeaf, ca}, nil},
+		{"wrong host", "mail.example", []*x509.Certificate{leaf, ca}, ErrCertNameMismatch},
+	}
+
+	for _, test := range tests {
+		var raw [][]byte
+		for _, cert := range test.chain {
+			raw = append(raw, cert.Raw)
+		}
+
+		secure, err := v.Verify(context.Background(), &CertVerifyInfo{
+			Host:     test.host,
+			Port:     "443",
+			Protocol: "tcp",
+			RawCerts: raw,
+		})
+		if test.want == nil {
+			if err != nil || !secure {
+				t.Fatalf("%s: got secure = %v, err = %v, want secure", test.name, secure, err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.want) {
+			t.Fatalf("%s: got err %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestDNSCertVerifier_PKIX(t *testing.T) {
+	ca, caKey := testIssue(t, "Test CA", nil, nil)
+	other, _ := testIssue(t, "Other CA", nil, nil)
+	leaf, _ := testIssue(t, "www.example", ca, caKey)
+
+	newTLSA := func(name string, usage uint8, cert *x509.Certificate) string {
+		tlsa := &dns.TLSA{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+		if err := tlsa.Sign(int(usage), 1, 1, cert); err != nil {
+			t.Fatal(err)
+		}
+		return tlsa.String()
+	}
+
+	s := newTestZoneSigner(t)
+	r := s.resolver(map[string][]dns.RR{
+		"_443._tcp.ee.example.":       s.sign(newTLSA("_443._tcp.ee.example.", 1, leaf)),
+		"_443._tcp.ta.example.":       s.sign(newTLSA("_443._tcp.ta.example.", 0, ca)),
+		"_443._tcp.mismatch.example.": s.sign(newTLSA("_443._tcp.mismatch.example.", 0, other)),
+	})
+	v, _ := NewDNSCertVerifier(r)
+
+	tests := []struct {
+		name     string
+		host     string
+		verified bool
+		secure   bool
+		want     error
+	}{
+		{"pkix-ee matches the leaf", "ee.example", true, true, nil},
+		{"pkix-ta matches the ca", "ta.example", true, true, nil},
+		{"pkix-ta pins another ca", "mismatch.example", true, false, ErrDNSAuthFailed},
+		{"chain not verified by webpki", "ta.example", false, false, nil},
+	}
+
+	for _, test := range tests {
+		secure, err := v.Verify(context.Background(), &CertVerifyInfo{
+			Host:             test.host,
+			Port:             "443",
+			Protocol:         "tcp",
+			RawCerts:         [][]byte{leaf.Raw},
+			DisableNameCheck: true,
+			WebPKIVerified:   test.verified,
+			WebPKIChain:      [][]byte{leaf.Raw, ca.Raw},
+		})
+		if !errors.Is(err, test.want) || secure != test.secure {
+			t.Fatalf("%s: got secure = %v, err = %v, want secure = %v, err = %v", test.name, secure, err, test.secure, test.want)
+		}
+	}
+}
+
+func TestDNSCertVerifier_VerifyWithReport(t *testing.T) {
+	ca, caKey := testIssue(t, "Test CA", nil, nil)
+	leaf, _ := testIssue(t, "www.example", ca, caKey)
+
+	leafDigest, err := dns.CertificateToDANE(1, 1, leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := strings.Repeat("ab", 32)
+	s := newTestZoneSigner(t)
+	r := s.resolver(map[string][]dns.RR{
+		"_443._tcp.www.example.": s.sign(
+			"_443._tcp.www.example. 300 IN TLSA 3 1 1 "+wrong,
+			"_443._tcp.www.example. 300 IN TLSA 3 1 9 "+wrong,
+			"_443._tcp.www.example. 300 IN TLSA 1 1 1 "+leafDigest,
+			"_443._tcp.www.example. 300 IN TLSA 2 1 1 "+wrong,
+		),
+	})
+	v, _ := NewDNSCertVerifier(r)
+
+	report, err := v.VerifyWithReport(context.Background(), &CertVerifyInfo{
+		Host:     "www.example",
+		Port:     "443",
+		Protocol: "tcp",
+		RawCerts: [][]byte{leaf.Raw, ca.Raw},
+	})
+	if !errors.Is(err, ErrDNSAuthFailed) || report.Secure {
+		t.Fatalf("got secure = %v, err = %v, want %v", report.Secure, err, ErrDNSAuthFailed)
+	}
+	if len(report.Records) != 4 {
+		t.Fatalf("got %d records, want all 4 evaluated", len(report.Records))
+	}
+
+	for _, result := range report.Records {
+		switch {
+		case result.Usage == 3 && result.MatchingType == 1:
+			if result.Skipped || result.Matched || len(result.Computed) != 1 || result.Computed[0].Digest != leafDigest {
+				t.Fatalf("got %+v, want mismatch with the computed leaf digest", result)
+			}
+		case result.Usage == 3:
+			if !result.Skipped || result.Reason == "" {
+				t.Fatalf("got %+v, want unknown matching type skipped", result)
+			}
+		case result.Usage == 1:
+			if !result.Skipped || result.Reason == "" {
+				t.Fatalf("got %+v, want pkix usage skipped without webpki", result)
+			}
+		case result.Usage == 2:
+			if result.Matched || len(result.Computed) != 1 || result.Computed[0].Subject != ca.Subject.String() {
+				t.Fatalf("got %+v, want mismatch computed for the ca", result)
+			}
+		}
+	}
+
+	// only unusable records
+	v.Resolver = s.resolver(map[string][]dns.RR{
+		"_443._tcp.www.example.": s.sign("_443._tcp.www.example. 300 IN TLSA 3 1 9 " + wrong),
+	})
+	report, err = v.VerifyWithReport(context.Background(), &CertVerifyInfo{
+		Host:     "www.example",
+		Port:     "443",
+		Protocol: "tcp",
+		RawCerts: [][]byte{leaf.Raw},
+	})
+	if err != nil || report.Secure || len(report.Records) != 1 {
+		t.Fatalf("got report %+v, err = %v, want insecure", report, err)
+	}
+	if report.AnchorStale || !report.AnchorFetched.IsZero() {
+		t.Fatalf("got anchor fetched %v, stale = %v, want unknown", report.AnchorFetched, report.AnchorStale)
+	}
+
+	// a stale anchor is reported with the time it was fetched
+	fetched := time.Now().Add(-time.Hour)
+	v.Resolver = s.resolver(map[string][]dns.RR{
+		"_443._tcp.www.example.": s.sign("_443._tcp.www.example. 300 IN TLSA 3 1 1 " + wrong),
+	})
+	handler := v.Resolver.TrustAnchorPointHandler
+	v.Resolver.TrustAnchorPointHandler = func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+		zone, err := handler(ctx, cut)
+		if zone != nil {
+			zone.Fetched, zone.Stale = fetched, true
+		}
+		return zone, err
+	}
+	report, err = v.VerifyWithReport(context.Background(), &CertVerifyInfo{
+		Host:     "www.example",
+		Port:     "443",
+		Protocol: "tcp",
+		RawCerts: [][]byte{leaf.Raw},
+	})
+	if !errors.Is(err, ErrDNSAuthFailed) || !report.AnchorStale || !report.AnchorFetched.Equal(fetched) {
+		t.Fatalf("got anchor fetched %v, stale = %v, err = %v, want stale from %v", report.AnchorFetched, report.AnchorStale, err, fetched)
+	}
+}
+
+func TestDNSCertVerifier_VerifyService(t *testing.T) {
+	ca, caKey := testIssue(t, "Test CA", nil, nil)
+	leaf, _ := testIssue(t, "www.example", ca, caKey)
+	mail, _ := testIssue(t, "mail.example", ca, caKey)
+
+	newTLSA := func(name string, cert *x509.Certificate) string {
+		tlsa := &dns.TLSA{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+		if err := tlsa.Sign(3, 1, 1, cert); err != nil {
+			t.Fatal(err)
+		}
+		return tlsa.String()
+	}
+
+	s := newTestZoneSigner(t)
+	r := s.resolver(map[string][]dns.RR{
+		"_443._udp.www.example.": s.sign(newTLSA("_443._udp.www.example.", leaf)),
+		"_25._tcp.mail.example.": s.sign(newTLSA("_25._tcp.mail.example.", mail)),
+	})
+	v, _ := NewDNSCertVerifier(r)
+
+	tests := []struct {
+		host  string
+		port  string
+		proto string
+		cert  *x509.Certificate
+		want  error
+	}{
+		{"www.example", "443", "udp", leaf, nil},
+		{"www.example", "443", "UDP", leaf, nil},
+		{"mail.example", "25", "tcp", mail, nil},
+		{"mail.example", "25", "tcp", leaf, ErrCertNameMismatch},
+		{"www.example", "443", "quic", leaf, ErrUnsupportedService},
+		{"www.example", "https", "tcp", leaf, ErrUnsupportedService},
+		{"www.example", "70000", "tcp", leaf, ErrUnsupportedService},
+	}
+
+	for _, test := range tests {
+		secure, err := v.VerifyService(context.Background(), test.host, test.port, test.proto, [][]byte{test.cert.Raw})
+		if test.want == nil {
+			if err != nil || !secure {
+				t.Fatalf("_%s._%s.%s: got secure = %v, err = %v, want secure", test.port, test.proto, test.host, secure, err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.want) {
+			t.Fatalf("_%s._%s.%s: got err %v, want %v", test.port, test.proto, test.host, err, test.want)
+		}
+	}
+}
+
+func TestDNSCertVerifier_Policy(t *testing.T) {
+	selfSigned := func(host string, key crypto.Signer, validity time.Duration, names ...string) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(time.Now().UnixNano()),
+			Subject:      pkix.Name{CommonName: host},
+			DNSNames:     append([]string{host}, names...),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(validity),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cert *x509.Certificate
+		want error
+	}{
+		{selfSigned("good.example", p256, time.Hour, "*.good.example"), nil},
+		{selfSigned("names.example", p256, time.Hour, "names.example.com"), ErrCertNameOutsideTLD},
+		{selfSigned("long.example", p256, 10*365*24*time.Hour), ErrCertValidityTooLong},
+		{selfSigned("rsa.example", rsa1024, time.Hour), ErrCertWeakKey},
+		{selfSigned("p224.example", p224, time.Hour), ErrCertWeakKey},
+	}
+
+	s := newTestZoneSigner(t)
+	answers := map[string][]dns.RR{}
+	for _, test := range tests {
+		name := "_443._tcp." + test.cert.Subject.CommonName + "."
+		tlsa := &dns.TLSA{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+		if err := tlsa.Sign(3, 1, 1, test.cert); err != nil {
+			t.Fatal(err)
+		}
+		answers[name] = s.sign(tlsa.String())
+	}
+
+	v, _ := NewDNSCertVerifier(s.resolver(answers))
+	v.Policy = &CertPolicy{
+		RestrictNames:   true,
+		MaxValidity:     398 * 24 * time.Hour,
+		MinRSAKeySize:   dnssec.DefaultMinRSAKeySize,
+		MinECDSAKeySize: 256,
+	}
+
+	for _, test := range tests {
+		host := test.cert.Subject.CommonName
+		secure, err := v.VerifyService(context.Background(), host, "443", "tcp", [][]byte{test.cert.Raw})
+		if test.want == nil {
+			if err != nil || !secure {
+				t.Fatalf("%s: got secure = %v, err = %v, want secure", host, secure, err)
+			}
+			continue
+		}
+		if secure || !errors.Is(err, test.want) || !errors.Is(err, ErrCertPolicy) {
+			t.Fatalf("%s: got secure = %v, err = %v, want %v", host, secure, err, test.want)
+		}
+	}
+
+	// hosts outside the scope aren't checked
+	v.Policy.AppliesTo = func(host string) bool {
+		return host != "names.example"
+	}
+	if secure, err := v.VerifyService(context.Background(), "names.example", "443", "tcp", [][]byte{tests[1].cert.Raw}); err != nil || !secure {
+		t.Fatalf("got secure = %v, err = %v, want secure", secure, err)
+	}
+}
+
+func TestDNSCertVerifier_Prefetch(t *testing.T) {
+	ca, caKey := testIssue(t, "Test CA", nil, nil)
+	leaf, _ := testIssue(t, "www.example", ca, caKey)
+
+	tlsa := &dns.TLSA{Hdr: dns.RR_Header{Name: "_443._tcp.www.example.", Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+	if err := tlsa.Sign(3, 1, 1, leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestZoneSigner(t)
+	answers := map[string][]dns.RR{
+		"_443._tcp.www.example.":  s.sign(tlsa.String()),
+		"_443._udp.www.example.":  s.sign(strings.Replace(tlsa.String(), "_tcp", "_udp", 1)),
+		"_443._tcp.mail.example.": s.sign(strings.Replace(tlsa.String(), "www", "mail", 1)),
+	}
+
+	var mu sync.Mutex
+	queries := map[string]int{}
+	r := s.resolver(answers)
+	r.exchangeTest = func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		q := msg.Question[0]
+		if q.Qtype == dns.TypeTLSA {
+			mu.Lock()
+			queries[q.Name]++
+			mu.Unlock()
+		}
+
+		// keep lookups in flight long enough to overlap
+		time.Sleep(20 * time.Millisecond)
+
+		re := new(dns.Msg)
+		re.SetReply(msg)
+		re.Answer = answers[q.Name]
+		return re, nil
+	}
+	v, _ := NewDNSCertVerifier(r)
+
+	hosts := []HostPort{
+		{Host: "www.example", Port: "443"},
+		{Host: "WWW.example", Port: "443", Protocol: "tcp"},
+		{Host: "www.example", Port: "443"},
+		{Host: "www.example", Port: "443", Protocol: "udp"},
+		{Host: "mail.example", Port: "443"},
+		{Host: "www.example", Port: "https"},
+	}
+
+	errs := v.Prefetch(context.Background(), hosts)
+	for i, err := range errs[:5] {
+		if err != nil {
+			t.Fatalf("%+v: %v", hosts[i], err)
+		}
+	}
+	if !errors.Is(errs[5], ErrUnsupportedService) {
+		t.Fatalf("got err %v, want %v", errs[5], ErrUnsupportedService)
+	}
+
+	for name, n := range queries {
+		if n != 1 {
+			t.Fatalf("%s queried %d times, want duplicates coalesced", name, n)
+		}
+	}
+	if len(queries) != 3 {
+		t.Fatalf("got queries %v, want 3 names", queries)
+	}
+
+	// answered from the cache now
+	secure, err := v.VerifyService(context.Background(), "www.example", "443", "tcp", [][]byte{leaf.Raw})
+	if err != nil || !secure {
+		t.Fatalf("got secure = %v, err = %v, want secure", secure, err)
+	}
+	if queries["_443._tcp.www.example."] != 1 {
+		t.Fatal("verify after prefetch should use the cache")
+	}
+}
+
+func TestPinHistory(t *testing.T) {
+	tlsa := func(data ...string) []*dns.TLSA {
+		var rrs []*dns.TLSA
+		for _, d := range data {
+			rrs = append(rrs, &dns.TLSA{Usage: 3, Selector: 1, MatchingType: 1, Certificate: d})
+		}
+		return rrs
+	}
+
+	path := t.TempDir() + "/pins.json"
+	h := OpenPinHistory(path)
+
+	var replaced []PinEvent
+	h.OnReplaced = func(event PinEvent) {
+		replaced = append(replaced, event)
+	}
+
+	name := "_443._tcp.example."
+	steps := []struct {
+		rrs  []*dns.TLSA
+		want PinChange
+	}{
+		{tlsa("aa"), PinNew},
+		{tlsa("AA"), PinUnchanged},
+		{tlsa("aa", "bb"), PinRotated},
+		{tlsa("bb"), PinRotated},
+		{tlsa("cc"), PinReplaced},
+	}
+	for i, step := range steps {
+		if change := h.Observe(name, step.rrs); change != step.want {
+			t.Fatalf("step %d: got %v, want %v", i, change, step.want)
+		}
+	}
+
+	events := h.Events()
+	if len(events) != 1 || len(replaced) != 1 || events[0].Name != name ||
+		events[0].Previous[0] != "3 1 1 bb" || events[0].Current[0] != "3 1 1 cc" {
+		t.Fatalf("got events %v, callback %v", events, replaced)
+	}
+
+	// the history survives a restart
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	h = OpenPinHistory(path)
+	if change := h.Observe("_443._TCP.Example.", tlsa("cc")); change != PinUnchanged {
+		t.Fatalf("got %v after reopening, want %v", change, PinUnchanged)
+	}
+
+	// a nil history keeps no pins
+	var none *PinHistory
+	if change := none.Observe(name, tlsa("aa")); change != PinUnknown {
+		t.Fatalf("got %v from nil history", change)
+	}
+}
+
+func TestPinHistory_Corrupt(t *testing.T) {
+	path := t.TempDir() + "/pins.json"
+	if err := ioutil.WriteFile(path, []byte(`{"_443._tcp.example.": {"rec`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// an advisory file that can't be read doesn't stop verification
+	h := OpenPinHistory(path)
+	tlsa := []*dns.TLSA{{Usage: 3, Selector: 1, MatchingType: 1, Certificate: "aa"}}
+	if change := h.Observe("_443._tcp.example.", tlsa); change != PinNew {
+		t.Fatalf("got %v, want %v", change, PinNew)
+	}
+
+	// changes are written in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(OpenPinHistory(path).pins) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("history should be written again")
+}
+
+func TestDNSCertVerifier_Pins(t *testing.T) {
+	ca, caKey := testIssue(t, "ca", nil, nil)
+	cert, _ := testIssue(t, "pins.example", ca, caKey)
+
+	name := "_443._tcp.pins.example."
+	rr := &dns.TLSA{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+	if err := rr.Sign(3, 1, 1, cert); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestZoneSigner(t)
+	v, _ := NewDNSCertVerifier(s.resolver(map[string][]dns.RR{name: s.sign(rr.String())}))
+
+	info := &CertVerifyInfo{Host: "pins.example", Port: "443", Protocol: "tcp", RawCerts: [][]byte{cert.Raw}}
+	if report, err := v.VerifyWithReport(context.Background(), info); err != nil || report.PinChange != PinUnknown {
+		t.Fatalf("got %v, %v without history", report.PinChange, err)
+	}
+
+	v.Pins = OpenPinHistory(t.TempDir() + "/pins.json")
+	defer v.Pins.Flush()
+
+	// a set that doesn't match the certificate isn't recorded
+	other, _ := testIssue(t, "pins.example", ca, caKey)
+	mismatch := &CertVerifyInfo{Host: "pins.example", Port: "443", Protocol: "tcp", RawCerts: [][]byte{other.Raw}}
+	if report, err := v.VerifyWithReport(context.Background(), mismatch); err == nil || report.PinChange != PinUnknown {
+		t.Fatalf("got %v, %v for a certificate that doesn't match", report.PinChange, err)
+	}
+
+	for _, want := range []PinChange{PinNew, PinUnchanged} {
+		report, err := v.VerifyWithReport(context.Background(), info)
+		if err != nil || !report.Secure || report.PinChange != want {
+			t.Fatalf("got secure = %v, pin %v, err = %v, want %v", report.Secure, report.PinChange, err, want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package hnsquery
+
+import (
+	"context"
+	"fmt"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"github.com/miekg/dns"
+	"strings"
+	"time"
+)
+
+// VerifyAuthChain validates the answer for qname from a stapled
+// authentication chain RFC 9102 without any lookups. The chain is
+// validated from the Handshake trust anchor of the TLD down to the
+// zone that signed the answer. Records above the TLD are ignored since
+// Handshake replaces the root zone. Only positive answers are supported.
+func (r *Resolver) VerifyAuthChain(ctx context.Context, qname string, qtype uint16, records []dns.RR) (*dns.Msg, error) {
+	qname = dns.CanonicalName(qname)
+	labels := dns.SplitDomainName(qname)
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("no tld for %s: %w", qname, dnssec.ErrBadChain)
+	}
+
+	zone, err := r.chainTrustAnchor(ctx, labels[len(labels)-1]+".", records)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(qname, qtype)
+	msg.Answer = dnssec.ChainRRSet(records, qname, qtype)
+	if len(msg.Answer) == 0 {
+		return nil, validationError(qname, fmt.Errorf("no %s records in chain: %w", dns.TypeToString[qtype], dnssec.ErrBadChain))
+	}
+
+	signer := chainSigner(msg.Answer)
+	trace := dnssec.TraceFromContext(ctx)
+
+	// follow the DS records down to the signer
+	for zone.Secure() && signer != "" && !strings.EqualFold(zone.Name, signer) {
+		if !dnssec.IsSubDomainStrict(zone.Name, signer) {
+			return nil, validationError(qname, fmt.Errorf("signer %s isn't below %s: %w", signer, zone.Name, dnssec.ErrBadChain))
+		}
+
+		cut := nextChainCut(records, zone.Name, signer)
+		if cut == "" {
+			return nil, validationError(qname, fmt.Errorf("no DS records from %s to %s: %w", zone.Name, signer, dnssec.ErrBadChain))
+		}
+
+		dsSet, secure, err := zone.VerifyRRSet(cut, dns.TypeDS, dnssec.ChainRRSet(records, cut, dns.TypeDS))
+		if err != nil {
+			return nil, validationError(qname, fmt.Errorf("DS for %s: %w", cut, err))
+		}
+		if !secure {
+			trace.Add(dnssec.TraceStep{Kind: dnssec.TraceInsecure, Zone: cut, Reason: "DS records use unsupported algorithms"})
+			return msg, nil
+		}
+		trace.Add(dnssec.TraceStep{Kind: dnssec.TraceDS, Zone: cut, Records: dnssec.TraceRecords(dsSet), Secure: true})
+
+		if zone, err = dnssec.NewZone(cut, dsSet); err != nil {
+			return nil, validationError(qname, err)
+		}
+		if err = chainKeys(zone, records, trace); err != nil {
+			return nil, validationError(qname, err)
+		}
+	}
+
+	secure, err := zone.Verify(ctx, msg, qname, qtype)
+	if err != nil {
+		return nil, validationError(qname, err)
+	}
+
+	msg.AuthenticatedData = secure
+	return msg, nil
+}
+
+// chainTrustAnchor the zone of a TLD with keys validated
+// from the chain unless they're cached already
+func (r *Resolver) chainTrustAnchor(ctx context.Context, tld string, records []dns.RR) (*dnssec.Zone, error) {
+	if r.zoneCuts != nil {
+		if zone, ok := r.zoneCuts.Get(tld); ok {
+			zone := zone.(*dnssec.Zone)
+			if time.Now().Before(zone.Expire) && (zone.Keys != nil || len(zone.TrustAnchors) == 0) && !r.anchorStale(tld) {
+				traceZone(ctx, zone, "zone from cache")
+				return zone, nil
+			}
+		}
+	}
+
+	if r.TrustAnchorPointHandler == nil {
+		return nil, fmt.Errorf("no trust anchor callback set")
+	}
+	// the zone isn't cached so a stale anchor
+	// is still loaded again by the next query
+	zone, err := r.TrustAnchorPointHandler(ctx, tld)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting trust anchor: %w", err)
+	}
+	if zone == nil {
+		return nil, fmt.Errorf("no trust anchor for %s: %w", tld, dnssec.ErrBadChain)
+	}
+	traceZone(ctx, zone, "trust anchor")
+
+	if len(zone.TrustAnchors) > 0 && len(zone.Keys) == 0 {
+		if err = chainKeys(zone, records, dnssec.TraceFromContext(ctx)); err != nil {
+			return nil, validationError(tld, err)
+		}
+	}
+
+	return zone, nil
+}
+
+// chainKeys validates the DNSKEY RRSet of zone from the chain
+func chainKeys(zone *dnssec.Zone, records []dns.RR, trace *dnssec.Trace) error {
+	msg := new(dns.Msg)
+	msg.SetQuestion(zone.Name, dns.TypeDNSKEY)
+	msg.Answer = dnssec.ChainRRSet(records, zone.Name, dns.TypeDNSKEY)
+
+	keys, err := zone.VerifyDNSKeys(msg)
+	if err != nil {
+		trace.Add(dnssec.TraceStep{Kind: dnssec.TraceDNSKEY, Zone: zone.Name, Reason: err.Error()})
+		return fmt.Errorf("dnskey for %s: %w", zone.Name, err)
+	}
+
+	var rrs []dns.RR
+	for _, key := range keys {
+		rrs = append(rrs, key)
+	}
+	trace.Add(dnssec.TraceStep{Kind: dnssec.TraceDNSKEY, Zone: zone.Name, Records: dnssec.TraceRecords(rrs), Secure: keys != nil})
+
+	zone.Keys = keys
+	return nil
+}
+
+// chainSigner the signer name of the answer
+func chainSigner(answer []dns.RR) string {
+	for _, rr := range answer {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			return dns.CanonicalName(sig.SignerName)
+		}
+	}
+
+	return ""
+}
+
+// nextChainCut the closest DS owner below parent
+// on the way down to signer
+func nextChainCut(records []dns.RR, parent, signer string) string {
+	cut := ""
+	for _, rr := range records {
+		if rr.Header().Rrtype != dns.TypeDS {
+			continue
+		}
+
+		owner := dns.CanonicalName(rr.Header().Name)
+		if !dnssec.IsSubDomainStrict(parent, owner) || !dns.IsSubDomain(owner, signer) {
+			continue
+		}
+		if cut == "" || dns.CountLabel(owner) < dns.CountLabel(cut) {
+			cut = owner
+		}
+	}
+
+	return cut
+}
@@ -0,0 +1,112 @@
+package hnsquery
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"github.com/hashicorp/golang-lru"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"github.com/miekg/dns"
+	"testing"
+	"time"
+)
+
+func TestDNSCertVerifier_VerifyWithChain(t *testing.T) {
+	ca, caKey := testIssue(t, "Test CA", nil, nil)
+	leaf, _ := testIssue(t, "www.sub.example", ca, caKey)
+	other, _ := testIssue(t, "www.sub.example", ca, caKey)
+
+	tld := newTestZone(t, "example.")
+	sub := newTestZone(t, "sub.example.")
+	forged := newTestZone(t, "example.")
+
+	tlsa := &dns.TLSA{Hdr: dns.RR_Header{Name: "_443._tcp.www.sub.example.", Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+	if err := tlsa.Sign(3, 1, 1, leaf); err != nil {
+		t.Fatal(err)
+	}
+	ds := sub.key.ToDS(dns.SHA256)
+	ds.Hdr.Ttl = 300
+
+	tldKeys := tld.sign(tld.key.String())
+	subKeys := sub.sign(sub.key.String())
+	dsSet := tld.sign(ds.String())
+	answer := sub.sign(tlsa.String())
+
+	r := &Resolver{
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+			zone, err := dnssec.NewZone(cut, []dns.RR{tld.key.ToDS(dns.SHA256)})
+			if err != nil {
+				return nil, err
+			}
+			zone.Expire = time.Now().Add(time.Hour)
+			zone.Tag = "root-2"
+			return zone, nil
+		},
+		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			t.Fatalf("stapled chain shouldn't need lookups: %s", msg.Question[0].Name)
+			return nil, nil
+		},
+	}
+	v, _ := NewDNSCertVerifier(r)
+
+	// the cached zone of the tld was loaded at an older name root
+	stale, err := dnssec.NewZone("example.", []dns.RR{forged.key.ToDS(dns.SHA256)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Expire = time.Now().Add(time.Hour)
+	stale.Tag = "root-1"
+	r.zoneCuts, _ = lru.New(10)
+	r.zoneCuts.Add("example.", stale)
+	r.SetAnchorTag("root-2")
+
+	chain := func(sets ...[]dns.RR) []byte {
+		ext := &dnssec.ChainExtension{Lifetime: 24}
+		for _, set := range sets {
+			ext.Records = append(ext.Records, set...)
+		}
+		data, err := ext.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name  string
+		cert  *x509.Certificate
+		chain []byte
+		want  error
+	}{
+		{"valid chain", leaf, chain(answer, subKeys, dsSet, tldKeys), nil},
+		{"tlsa doesn't match", other, chain(answer, subKeys, dsSet, tldKeys), ErrDNSAuthFailed},
+		{"missing DS", leaf, chain(answer, subKeys, tldKeys), dnssec.ErrBadChain},
+		{"DS signed by another key", leaf, chain(answer, subKeys, forged.sign(ds.String()), tldKeys), ErrDNSSECFailed},
+		{"missing tld keys", leaf, chain(answer, subKeys, dsSet), ErrDNSSECFailed},
+		{"truncated", leaf, chain(answer, subKeys, dsSet, tldKeys)[:40], dnssec.ErrBadChain},
+	}
+
+	for _, test := range tests {
+		report, err := v.VerifyWithChain(context.Background(), &CertVerifyInfo{
+			Host:     "www.sub.example",
+			Port:     "443",
+			Protocol: "tcp",
+			RawCerts: [][]byte{test.cert.Raw},
+		}, test.chain)
+		if test.want == nil {
+			if err != nil || !report.Secure {
+				t.Fatalf("%s: got secure = %v, err = %v, want secure", test.name, report.Secure, err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.want) {
+			t.Fatalf("%s: got err %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	// the chain doesn't replace the cached zone
+	// so it's still loaded again before use
+	if !r.anchorStale("example.") {
+		t.Fatal("cached tld zone should still be stale")
+	}
+}
@@ -0,0 +1,306 @@
+package dnssec
+
+import (
+	"github.com/miekg/dns"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxNegativeCacheSize max validated NSEC records kept per zone
+const MaxNegativeCacheSize = 1000
+
+// negativeCache holds validated NSEC records that can be used
+// to synthesize negative answers for the zone RFC 8198.
+// The zero value is ready to use.
+type negativeCache struct {
+	sync.Mutex
+	nsecs map[string]*negativeEntry
+	soa   *negativeEntry
+}
+
+type negativeEntry struct {
+	rrs    []dns.RR
+	expire time.Time
+}
+
+func (z *Zone) now() time.Time {
+	if z.CurrentTime.IsZero() {
+		return time.Now()
+	}
+
+	return z.CurrentTime
+}
+
+// FlushNegativeCache removes all cached NSEC records
+func (z *Zone) FlushNegativeCache() {
+	z.negative.Lock()
+	defer z.negative.Unlock()
+
+	z.negative.nsecs = nil
+	z.negative.soa = nil
+}
+
+// cacheNegative stores the NSEC and SOA records of a validated
+// negative answer. msg must only contain verified records.
+func (z *Zone) cacheNegative(msg *dns.Msg) {
+	now := z.now()
+	soa := z.soaFromMsg(msg, now)
+
+	z.negative.Lock()
+	defer z.negative.Unlock()
+
+	if soa != nil {
+		z.negative.soa = soa
+	}
+
+	var soaMin uint32
+	if z.negative.soa != nil {
+		soaMin = z.negative.soa.rrs[0].(*dns.SOA).Minttl
+	}
+
+	for _, rr := range msg.Ns {
+		nsec, ok := rr.(*dns.NSEC)
+		if !ok || !dns.IsSubDomain(z.Name, nsec.Header().Name) || !dns.IsSubDomain(z.Name, nsec.NextDomain) {
+			continue
+		}
+
+		sig := findSig(msg.Ns, nsec.Header().Name, dns.TypeNSEC)
+		if sig == nil || !strings.EqualFold(sig.SignerName, z.Name) {
+			continue
+		}
+
+		// NSEC records from wildcard expansion
+		// must not be cached
+		if int(sig.Labels) != dns.CountLabel(nsec.Header().Name) {
+			continue
+		}
+
+		// RFC 8198 5.4 TTL is the min of the NSEC TTL and SOA minimum
+		ttl := nsec.Header().Ttl
+		if soaMin > 0 && soaMin < ttl {
+			ttl = soaMin
+		}
+
+		expire := now.Add(time.Duration(ttl) * time.Second)
+		if sigExpire := SigExpiration(sig, now); sigExpire.Before(expire) {
+			expire = sigExpire
+		}
+		if !expire.After(now) {
+			continue
+		}
+
+		if z.negative.nsecs == nil {
+			z.negative.nsecs = make(map[string]*negativeEntry)
+		}
+
+		z.negative.nsecs[dns.CanonicalName(nsec.Header().Name)] = &negativeEntry{
+			rrs:    []dns.RR{dns.Copy(nsec), dns.Copy(sig)},
+			expire: expire,
+		}
+	}
+
+	z.evictNegative(now)
+}
+
+// evictNegative removes expired entries and the ones expiring
+// first if the cache is full. Must be called with the lock held.
+func (z *Zone) evictNegative(now time.Time) {
+	for name, entry := range z.negative.nsecs {
+		if !entry.expire.After(now) {
+			delete(z.negative.nsecs, name)
+		}
+	}
+
+	for len(z.negative.nsecs) > MaxNegativeCacheSize {
+		oldest := ""
+		for name, entry := range z.negative.nsecs {
+			if oldest == "" || entry.expire.Before(z.negative.nsecs[oldest].expire) {
+				oldest = name
+			}
+		}
+		delete(z.negative.nsecs, oldest)
+	}
+}
+
+func (z *Zone) soaFromMsg(msg *dns.Msg, now time.Time) *negativeEntry {
+	for _, rr := range msg.Ns {
+		soa, ok := rr.(*dns.SOA)
+		if !ok || !strings.EqualFold(soa.Header().Name, z.Name) {
+			continue
+		}
+
+		sig := findSig(msg.Ns, soa.Header().Name, dns.TypeSOA)
+		if sig == nil {
+			return nil
+		}
+
+		ttl := soa.Header().Ttl
+		if soa.Minttl < ttl {
+			ttl = soa.Minttl
+		}
+
+		expire := now.Add(time.Duration(ttl) * time.Second)
+		if sigExpire := SigExpiration(sig, now); sigExpire.Before(expire) {
+			expire = sigExpire
+		}
+
+		return &negativeEntry{
+			rrs:    []dns.RR{dns.Copy(soa), dns.Copy(sig)},
+			expire: expire,
+		}
+	}
+
+	return nil
+}
+
+// SynthesizeNegative answers a query from cached NSEC records. It returns
+// a NXDOMAIN or NODATA response or nil if the cache can't prove
+// the name or type doesn't exist.
+func (z *Zone) SynthesizeNegative(qname string, qtype uint16) *dns.Msg {
+	if !z.Secure() || z.VerifyCallback != nil {
+		return nil
+	}
+
+	qname = dns.CanonicalName(qname)
+	if !dns.IsSubDomain(z.Name, qname) {
+		return nil
+	}
+
+	// the DS set of the apex is only proven by the parent
+	if qtype == dns.TypeDS && strings.EqualFold(qname, z.Name) {
+		return nil
+	}
+
+	now := z.now()
+
+	z.negative.Lock()
+	defer z.negative.Unlock()
+
+	if len(z.negative.nsecs) == 0 {
+		return nil
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(qname, qtype)
+	msg.Response = true
+
+	if entry, ok := z.negative.nsecs[qname]; ok {
+		if !entry.expire.After(now) || !nsecProvesNoData(entry.rrs[0].(*dns.NSEC), qtype) {
+			return nil
+		}
+
+		msg.Ns = z.withSOA(now, entry.rrs)
+		return msg
+	}
+
+	// name proof
+	name := z.coveringNSEC(qname, now)
+	if name == nil {
+		return nil
+	}
+
+	msg.Rcode = dns.RcodeNameError
+	msg.Ns = append(msg.Ns, name.rrs...)
+
+	// wildcard proof verifyNameError checks
+	// which wildcard is needed
+	labels := dns.SplitDomainName(qname)
+	for i := 1; i < len(labels); i++ {
+		wildcard := dns.Fqdn("*." + strings.Join(labels[i:], "."))
+		if !dns.IsSubDomain(z.Name, wildcard) {
+			break
+		}
+		if entry := z.coveringNSEC(wildcard, now); entry != nil {
+			if entry != name {
+				msg.Ns = append(msg.Ns, entry.rrs...)
+			}
+			break
+		}
+	}
+
+	if ok, err := z.verifyNameError(msg, qname); !ok || err != nil {
+		return nil
+	}
+
+	msg.Ns = z.withSOA(now, msg.Ns)
+	return msg
+}
+
+// coveringNSEC finds an unexpired NSEC record covering name
+func (z *Zone) coveringNSEC(name string, now time.Time) *negativeEntry {
+	for _, entry := range z.negative.nsecs {
+		if !entry.expire.After(now) {
+			continue
+		}
+
+		nsec := entry.rrs[0].(*dns.NSEC)
+		if !covers(nsec.Header().Name, nsec.NextDomain, name) {
+			continue
+		}
+
+		// RFC 8198 5.1 names below a delegation point
+		// or a DNAME are not proven by this NSEC
+		if IsSubDomainStrict(nsec.Header().Name, name) &&
+			(nsecIsDelegation(nsec) || hasType(nsec.TypeBitMap, dns.TypeDNAME)) {
+			continue
+		}
+
+		return entry
+	}
+
+	return nil
+}
+
+func (z *Zone) withSOA(now time.Time, rrs []dns.RR) []dns.RR {
+	if z.negative.soa == nil || !z.negative.soa.expire.After(now) {
+		return rrs
+	}
+
+	return append(append([]dns.RR{}, z.negative.soa.rrs...), rrs...)
+}
+
+// nsecProvesNoData checks if a matching NSEC proves qtype doesn't exist
+func nsecProvesNoData(nsec *dns.NSEC, qtype uint16) bool {
+	if hasType(nsec.TypeBitMap, qtype) || hasType(nsec.TypeBitMap, dns.TypeCNAME) {
+		return false
+	}
+
+	// parent side of a delegation is only
+	// authoritative for the DS record
+	if nsecIsDelegation(nsec) && qtype != dns.TypeDS {
+		return false
+	}
+
+	return true
+}
+
+func nsecIsDelegation(nsec *dns.NSEC) bool {
+	return hasType(nsec.TypeBitMap, dns.TypeNS) && !hasType(nsec.TypeBitMap, dns.TypeSOA)
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	for _, bt := range bitmap {
+		if bt == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findSig(rrs []dns.RR, name string, covered uint16) *dns.RRSIG {
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == covered &&
+			strings.EqualFold(sig.Header().Name, name) {
+			return sig
+		}
+	}
+
+	return nil
+}
+
+// SigExpiration the expiration of sig closest to now
+func SigExpiration(sig *dns.RRSIG, now time.Time) time.Time {
+	return sigTime(sig.Expiration, now)
+}
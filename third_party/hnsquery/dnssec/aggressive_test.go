@@ -0,0 +1,229 @@
+package dnssec
+
+import (
+	"context"
+	"crypto"
+	"github.com/miekg/dns"
+	"testing"
+	"time"
+)
+
+var testNow = time.Unix(1640000000, 0)
+
+// testSigner signs records for a test zone
+type testSigner struct {
+	name       string
+	key        *dns.DNSKEY
+	priv       crypto.Signer
+	expiration time.Time
+}
+
+func newTestSigner(t *testing.T, name string) *testSigner {
+	t.Helper()
+
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testSigner{
+		name:       name,
+		key:        key,
+		priv:       priv.(crypto.Signer),
+		expiration: testNow.Add(24 * time.Hour),
+	}
+}
+
+func (s *testSigner) zone() *Zone {
+	return &Zone{
+		Name:        s.name,
+		Keys:        map[uint16]*dns.DNSKEY{s.key.KeyTag(): s.key},
+		CurrentTime: testNow,
+		MinRSA:      DefaultMinRSAKeySize,
+	}
+}
+
+// sign parses an RRSet and returns it with its signature
+func (s *testSigner) sign(t *testing.T, records ...string) []dns.RR {
+	t.Helper()
+
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	return append(rrs, s.signRRs(t, rrs, 0))
+}
+
+func (s *testSigner) signRRs(t *testing.T, rrs []dns.RR, labels uint8) *dns.RRSIG {
+	t.Helper()
+
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrs[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rrs[0].Header().Ttl},
+		Algorithm:  s.key.Algorithm,
+		SignerName: s.name,
+		KeyTag:     s.key.KeyTag(),
+		Inception:  uint32(testNow.Add(-time.Hour).Unix()),
+		Expiration: uint32(s.expiration.Unix()),
+	}
+	if err := sig.Sign(s.priv, rrs); err != nil {
+		t.Fatal(err)
+	}
+
+	// wildcard expansion
+	if labels > 0 {
+		sig.Labels = labels
+	}
+
+	return sig
+}
+
+func testNegativeMsg(qname string, qtype uint16, rcode int, ns ...[]dns.RR) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(qname, qtype)
+	msg.Response = true
+	msg.Rcode = rcode
+	for _, rrs := range ns {
+		msg.Ns = append(msg.Ns, rrs...)
+	}
+
+	return msg
+}
+
+func verifyTestMsg(t *testing.T, z *Zone, msg *dns.Msg) {
+	t.Helper()
+
+	secure, err := z.Verify(context.Background(), msg, msg.Question[0].Name, msg.Question[0].Qtype)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !secure {
+		t.Fatal("want secure")
+	}
+}
+
+func TestZone_SynthesizeNegative(t *testing.T) {
+	s := newTestSigner(t, "example.")
+	z := s.zone()
+
+	soa := s.sign(t, "example. 3600 IN SOA ns.example. admin.example. 1 3600 600 86400 300")
+	apex := s.sign(t, "example. 3600 IN NSEC a.example. NS SOA RRSIG NSEC DNSKEY")
+	a := s.sign(t, "a.example. 3600 IN NSEC d.example. A RRSIG NSEC")
+	d := s.sign(t, "d.example. 3600 IN NSEC sub.example. TXT RRSIG NSEC")
+	sub := s.sign(t, "sub.example. 3600 IN NSEC example. NS RRSIG NSEC")
+
+	if msg := z.SynthesizeNegative("b.example.", dns.TypeA); msg != nil {
+		t.Fatal("empty cache should not synthesize")
+	}
+
+	// b.example. is covered by a.example. and *.example. by the apex NSEC
+	verifyTestMsg(t, z, testNegativeMsg("b.example.", dns.TypeA, dns.RcodeNameError, soa, a, apex))
+	verifyTestMsg(t, z, testNegativeMsg("d.example.", dns.TypeA, dns.RcodeSuccess, soa, d))
+	verifyTestMsg(t, z, testNegativeMsg("sub.example.", dns.TypeDS, dns.RcodeSuccess, soa, sub))
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		rcode int
+		ok    bool
+	}{
+		{"b.example.", dns.TypeA, dns.RcodeNameError, true},
+		{"c.example.", dns.TypeTXT, dns.RcodeNameError, true},
+		{"x.b.example.", dns.TypeA, dns.RcodeNameError, true},
+		{"d.example.", dns.TypeA, dns.RcodeSuccess, true},
+		{"d.example.", dns.TypeTXT, 0, false},
+		// a.example. has A
+		{"a.example.", dns.TypeA, 0, false},
+		{"e.example.", dns.TypeA, dns.RcodeNameError, true},
+		// below a delegation point
+		{"www.sub.example.", dns.TypeA, 0, false},
+		// parent side of the delegation only proves DS
+		{"sub.example.", dns.TypeDS, dns.RcodeSuccess, true},
+		{"sub.example.", dns.TypeA, 0, false},
+		// the apex DS is proven by the parent
+		{"example.", dns.TypeDS, 0, false},
+		// out of zone
+		{"b.other.", dns.TypeA, 0, false},
+	}
+
+	for _, test := range tests {
+		msg := z.SynthesizeNegative(test.qname, test.qtype)
+		if !test.ok {
+			if msg != nil {
+				t.Fatalf("%s %s: should not synthesize", test.qname, dns.TypeToString[test.qtype])
+			}
+			continue
+		}
+		if msg == nil {
+			t.Fatalf("%s %s: want synthesized answer", test.qname, dns.TypeToString[test.qtype])
+		}
+		if msg.Rcode != test.rcode {
+			t.Fatalf("%s: got rcode %s, want %s", test.qname, dns.RcodeToString[msg.Rcode], dns.RcodeToString[test.rcode])
+		}
+
+		// synthesized answers must carry a valid proof
+		verifyTestMsg(t, z, msg)
+	}
+
+	z.FlushNegativeCache()
+	if msg := z.SynthesizeNegative("b.example.", dns.TypeA); msg != nil {
+		t.Fatal("flushed cache should not synthesize")
+	}
+}
+
+func TestZone_SynthesizeNegativeExpire(t *testing.T) {
+	s := newTestSigner(t, "example.")
+	z := s.zone()
+
+	// SOA minimum caps the NSEC TTL
+	soa := s.sign(t, "example. 3600 IN SOA ns.example. admin.example. 1 3600 600 86400 300")
+	d := s.sign(t, "d.example. 3600 IN NSEC e.example. TXT RRSIG NSEC")
+	verifyTestMsg(t, z, testNegativeMsg("d.example.", dns.TypeA, dns.RcodeSuccess, soa, d))
+
+	z.CurrentTime = testNow.Add(299 * time.Second)
+	if z.SynthesizeNegative("d.example.", dns.TypeA) == nil {
+		t.Fatal("want synthesized answer")
+	}
+
+	z.CurrentTime = testNow.Add(300 * time.Second)
+	if z.SynthesizeNegative("d.example.", dns.TypeA) != nil {
+		t.Fatal("entry should expire after the SOA minimum")
+	}
+
+	// signature expiration caps the TTL
+	s.expiration = testNow.Add(10 * time.Second)
+	z.CurrentTime = testNow
+	d = s.sign(t, "d.example. 3600 IN NSEC e.example. TXT RRSIG NSEC")
+	verifyTestMsg(t, z, testNegativeMsg("d.example.", dns.TypeA, dns.RcodeSuccess, d))
+
+	z.CurrentTime = testNow.Add(10 * time.Second)
+	if z.SynthesizeNegative("d.example.", dns.TypeA) != nil {
+		t.Fatal("entry should expire with the signature")
+	}
+}
+
+func TestZone_SynthesizeNegativeWildcard(t *testing.T) {
+	s := newTestSigner(t, "example.")
+	z := s.zone()
+
+	// NSEC returned with a wildcard answer is signed by
+	// the wildcard owner and must not be cached
+	rrs, _ := dns.NewRR("a.example. 3600 IN NSEC d.example. A RRSIG NSEC")
+	z.cacheNegative(testNegativeMsg("b.example.", dns.TypeA, dns.RcodeNameError,
+		[]dns.RR{rrs, s.signRRs(t, []dns.RR{rrs}, 1)}))
+
+	if len(z.negative.nsecs) != 0 {
+		t.Fatal("expanded NSEC should not be cached")
+	}
+}
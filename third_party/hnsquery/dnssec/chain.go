@@ -0,0 +1,94 @@
+package dnssec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"strings"
+)
+
+var ErrBadChain = errors.New("bad dnssec authentication chain")
+
+// ChainExtension the data of the TLS dnssec_chain extension RFC 9102 3
+type ChainExtension struct {
+	// Lifetime hours the server commits to keep
+	// sending the extension
+	Lifetime uint16
+
+	// Records the authentication chain in any order
+	Records []dns.RR
+}
+
+// ParseChainExtension parses the extension data sent by a server. The
+// authentication chain is a sequence of uncompressed wire format records.
+func ParseChainExtension(data []byte) (*ChainExtension, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("extension too short: %w", ErrBadChain)
+	}
+
+	ext := &ChainExtension{Lifetime: binary.BigEndian.Uint16(data)}
+	size := int(binary.BigEndian.Uint16(data[2:]))
+	chain := data[4:]
+	if size == 0 || size != len(chain) {
+		return nil, fmt.Errorf("chain length %d doesn't match %d bytes: %w", size, len(chain), ErrBadChain)
+	}
+
+	for off := 0; off < len(chain); {
+		rr, next, err := dns.UnpackRR(chain, off)
+		if err != nil {
+			return nil, fmt.Errorf("record at offset %d: %v: %w", off, err, ErrBadChain)
+		}
+		if rr == nil || next <= off {
+			return nil, fmt.Errorf("empty record at offset %d: %w", off, ErrBadChain)
+		}
+
+		ext.Records = append(ext.Records, rr)
+		off = next
+	}
+
+	return ext, nil
+}
+
+// Pack encodes the extension data
+func (c *ChainExtension) Pack() ([]byte, error) {
+	var chain []byte
+	for _, rr := range c.Records {
+		buf := make([]byte, dns.Len(rr))
+		n, err := dns.PackRR(rr, buf, 0, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, buf[:n]...)
+	}
+
+	if len(chain) == 0 || len(chain) > 0xffff {
+		return nil, fmt.Errorf("chain length %d: %w", len(chain), ErrBadChain)
+	}
+
+	data := make([]byte, 4, 4+len(chain))
+	binary.BigEndian.PutUint16(data, c.Lifetime)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(chain)))
+	return append(data, chain...), nil
+}
+
+// ChainRRSet returns the records of the given owner and type
+// from an authentication chain along with their signatures
+func ChainRRSet(records []dns.RR, name string, t uint16) []dns.RR {
+	var set []dns.RR
+	for _, rr := range records {
+		if !strings.EqualFold(rr.Header().Name, name) {
+			continue
+		}
+
+		rrType := rr.Header().Rrtype
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			rrType = sig.TypeCovered
+		}
+		if rrType == t {
+			set = append(set, rr)
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,49 @@
+package dnssec
+
+import (
+	"errors"
+	"github.com/miekg/dns"
+	"testing"
+)
+
+func TestChainExtension(t *testing.T) {
+	var records []dns.RR
+	for _, record := range []string{
+		"_443._tcp.www.example. 300 IN TLSA 3 1 1 0123456789abcdef",
+		"example. 300 IN DS 12345 13 2 0123456789abcdef",
+	} {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rr)
+	}
+
+	data, err := (&ChainExtension{Lifetime: 24, Records: records}).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext, err := ParseChainExtension(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.Lifetime != 24 || len(ext.Records) != len(records) {
+		t.Fatalf("got %+v, want lifetime 24 and %d records", ext, len(records))
+	}
+	for i, rr := range ext.Records {
+		if !dns.IsDuplicate(rr, records[i]) {
+			t.Fatalf("got %v, want %v", rr, records[i])
+		}
+	}
+
+	if set := ChainRRSet(ext.Records, "EXAMPLE.", dns.TypeDS); len(set) != 1 {
+		t.Fatalf("got %v, want the DS record", set)
+	}
+
+	for _, bad := range [][]byte{data[:3], data[:len(data)-1], append(data[:4:4], 0, 0)} {
+		if _, err := ParseChainExtension(bad); !errors.Is(err, ErrBadChain) {
+			t.Fatalf("got err %v, want %v", err, ErrBadChain)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package dnssec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"strings"
+)
+
+var ErrDNAMESubstitution = errors.New("dname substitution failed")
+
+// SynthesizeCNAME RFC 6672 2.2 derives the CNAME for qname from
+// a DNAME owned by one of its ancestors. The CNAME uses the TTL
+// of the DNAME.
+func SynthesizeCNAME(dname *dns.DNAME, qname string) (*dns.CNAME, error) {
+	owner := dname.Header().Name
+	if !IsSubDomainStrict(owner, qname) {
+		return nil, fmt.Errorf("%s isn't below dname %s: %w", qname, owner, ErrDNAMESubstitution)
+	}
+
+	prefix := qname
+	if ownerLabels := dns.CountLabel(owner); ownerLabels > 0 {
+		idx := dns.Split(qname)
+		prefix = qname[:idx[len(idx)-ownerLabels]]
+	}
+
+	target := prefix
+	if dname.Target != "." {
+		target += dns.Fqdn(dname.Target)
+	}
+
+	// RFC 6672 2.2 YXDOMAIN
+	if _, ok := dns.IsDomainName(target); !ok {
+		return nil, fmt.Errorf("%s substituted by %s is too long: %w", qname, owner, ErrDNAMESubstitution)
+	}
+
+	return &dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   qname,
+			Rrtype: dns.TypeCNAME,
+			Class:  dname.Header().Class,
+			Ttl:    dname.Header().Ttl,
+		},
+		Target: target,
+	}, nil
+}
+
+// findDNAME returns the DNAME closest to qname owned by one of its ancestors
+func findDNAME(rrs []dns.RR, qname string) *dns.DNAME {
+	var closest *dns.DNAME
+	for _, rr := range rrs {
+		dname, ok := rr.(*dns.DNAME)
+		if !ok || !IsSubDomainStrict(dname.Header().Name, qname) {
+			continue
+		}
+
+		if closest == nil || dns.CountLabel(dname.Header().Name) > dns.CountLabel(closest.Header().Name) {
+			closest = dname
+		}
+	}
+
+	return closest
+}
+
+func hasOwnedRRs(rrs []dns.RR, name string) bool {
+	for _, rr := range rrs {
+		if strings.EqualFold(rr.Header().Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
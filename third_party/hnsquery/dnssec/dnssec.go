@@ -25,6 +25,8 @@ var (
 	ErrSignatureBailiwick     = errors.New("rrsig record out of bailiwick")
 	ErrInvalidSignaturePeriod = errors.New("incorrect signature validity period")
 	ErrMissingSigned          = errors.New("signed records are missing")
+	ErrMissingNSEC            = errors.New("missing nsec or nsec3 proof")
+	ErrUnexpectedRcode        = errors.New("unexpected rcode")
 )
 
 // supported dnssec algorithms weaker/unsupported algorithms are treated as unsigned
@@ -52,11 +54,26 @@ type Zone struct {
 	// The zone expire time
 	Expire time.Time
 
+	// Tag the version of the trust anchor the zone was
+	// loaded with such as the Handshake name root
+	Tag string
+
+	// Fetched when the trust anchor was fetched or zero if unknown
+	Fetched time.Time
+
+	// Stale the trust anchor is served while a newer one is fetched
+	Stale bool
+
 	// MinRSA minimum accepted RSA key size
 	MinRSA int
 
 	// For custom zone verification
 	VerifyCallback func(ctx context.Context, msg *dns.Msg) (bool, error)
+
+	// validated NSEC records for aggressive negative caching.
+	// A new Zone is created when the trust anchor changes
+	// so it never outlives the keys that validated it.
+	negative negativeCache
 }
 
 func (z *Zone) Secure() bool {
@@ -294,7 +311,7 @@ func (z *Zone) VerifyDNSKeys(msg *dns.Msg) (map[uint16]*dns.DNSKEY, error) {
 	z.Keys = validKeys
 	// verifySignatures will clean up the answer
 	// section in the msg with only the valid rr sets
-	secure, err := z.verifySignatures(msg, z.Name)
+	secure, err := z.verifySignatures(msg, z.Name, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -336,13 +353,14 @@ func (z *Zone) VerifyRRSet(name string, t uint16, set []dns.RR) ([]dns.RR, bool,
 	msg := new(dns.Msg)
 	msg.SetQuestion(name, t)
 	msg.Answer = set
-	secure, err := z.verifySignatures(msg, name)
+	secure, err := z.verifySignatures(msg, name, nil)
 	return msg.Answer, secure, err
 }
 
 // verifySignatures verifies signatures in a message
-// and removes any invalid rr sets
-func (z *Zone) verifySignatures(msg *dns.Msg, qname string) (bool, error) {
+// and removes any invalid rr sets. Checked signatures
+// are recorded in trace if it's not nil.
+func (z *Zone) verifySignatures(msg *dns.Msg, qname string, trace *Trace) (bool, error) {
 	type rrsetId struct {
 		owner string
 		t     uint16
@@ -398,6 +416,7 @@ func (z *Zone) verifySignatures(msg *dns.Msg, qname string) (bool, error) {
 					// we don't care about signatures not in bailiwick
 					if !dns.IsSubDomain(z.Name, sigName) {
 						lastErr = ErrSignatureBailiwick
+						z.traceSig(trace, sig, lastErr)
 						continue
 					}
 
@@ -406,6 +425,7 @@ func (z *Zone) verifySignatures(msg *dns.Msg, qname string) (bool, error) {
 					// RFC4035 5.3.1 bullet 2 signer name must match the name of the zone
 					if !ok || !strings.EqualFold(key.Header().Name, sig.SignerName) {
 						lastErr = ErrMissingDNSKEY
+						z.traceSig(trace, sig, lastErr)
 						continue
 					}
 
@@ -415,6 +435,7 @@ func (z *Zone) verifySignatures(msg *dns.Msg, qname string) (bool, error) {
 					// signatures that can verify the set
 					if shouldDowngradeKey(key, z.MinRSA) {
 						downgrade = true
+						z.traceSig(trace, sig, fmt.Errorf("key %d is weaker than the minimum accepted", sig.KeyTag))
 						continue
 					}
 
@@ -422,19 +443,24 @@ func (z *Zone) verifySignatures(msg *dns.Msg, qname string) (bool, error) {
 					rrset := extractRRSet(section, sig)
 					if len(rrset) == 0 {
 						lastErr = ErrMissingSigned
+						z.traceSig(trace, sig, lastErr)
 						continue
 					}
 
 					if err := sig.Verify(key, rrset); err != nil {
 						lastErr = err
+						z.traceSig(trace, sig, lastErr)
 						continue
 					}
 
 					if !sig.ValidityPeriod(z.CurrentTime) {
 						lastErr = ErrInvalidSignaturePeriod
+						z.traceSig(trace, sig, lastErr)
 						continue
 					}
 
+					z.traceSig(trace, sig, nil)
+
 					// verified
 					verifiedSets[rrsetId{sigName, sig.TypeCovered}] = struct{}{}
 
@@ -470,6 +496,12 @@ func (z *Zone) verifySignatures(msg *dns.Msg, qname string) (bool, error) {
 	// we don't have any secure validation paths
 	// if its okay to downgrade mark zone as insecure
 	if downgrade {
+		trace.Add(TraceStep{
+			Kind:   TraceInsecure,
+			Zone:   z.Name,
+			Name:   qname,
+			Reason: "only signed with keys weaker than the minimum accepted",
+		})
 		msg.Answer = sections[0]
 		msg.Ns = sections[1]
 		msg.Extra = sections[2]
@@ -477,7 +509,7 @@ func (z *Zone) verifySignatures(msg *dns.Msg, qname string) (bool, error) {
 	}
 
 	if lastErr != nil {
-		return false, fmt.Errorf("error verifying signatures: %v", lastErr)
+		return false, fmt.Errorf("error verifying signatures: %w", lastErr)
 	}
 
 	return false, ErrNoSignatures
@@ -516,7 +548,22 @@ func cleanInsecureMsg(msg *dns.Msg) {
 	msg.Extra = extra
 }
 
-func (z *Zone) Verify(ctx context.Context, msg *dns.Msg, qname string, qtype uint16) (bool, error) {
+func (z *Zone) Verify(ctx context.Context, msg *dns.Msg, qname string, qtype uint16) (secure bool, err error) {
+	trace := TraceFromContext(ctx)
+	if trace != nil {
+		defer func() {
+			step := TraceStep{Kind: TraceResult, Zone: z.Name, Name: qname, Secure: secure}
+			if err != nil {
+				step.Reason = err.Error()
+			}
+			trace.Add(step)
+		}()
+	}
+
+	return z.verify(ctx, msg, qname, qtype, trace)
+}
+
+func (z *Zone) verify(ctx context.Context, msg *dns.Msg, qname string, qtype uint16, trace *Trace) (bool, error) {
 	if !dns.IsFqdn(z.Name) || !dns.IsFqdn(qname) {
 		return false, fmt.Errorf("zone and qname must be fqdn")
 	}
@@ -536,11 +583,17 @@ func (z *Zone) Verify(ctx context.Context, msg *dns.Msg, qname string, qtype uin
 
 	// insecure zone
 	if !z.Secure() {
+		trace.Add(TraceStep{
+			Kind:   TraceInsecure,
+			Zone:   z.Name,
+			Name:   qname,
+			Reason: "zone has no validated DNSKEYs",
+		})
 		cleanInsecureMsg(msg)
 		return false, nil
 	}
 
-	secure, err := z.verifySignatures(msg, qname)
+	secure, err := z.verifySignatures(msg, qname, trace)
 	if err != nil {
 		return false, err
 	}
@@ -549,30 +602,52 @@ func (z *Zone) Verify(ctx context.Context, msg *dns.Msg, qname string, qtype uin
 	}
 
 	// signatures are good verify answer
+	secure, err = z.verifyResponse(msg, qname, qtype)
+	if !secure && err == nil {
+		trace.Add(TraceStep{
+			Kind:   TraceInsecure,
+			Zone:   z.Name,
+			Name:   qname,
+			Reason: "proof allows an unsigned delegation or uses unsupported NSEC3 parameters",
+		})
+	}
+
+	return secure, err
+}
+
+func (z *Zone) verifyResponse(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
 	if msg.Rcode == dns.RcodeSuccess {
 		if len(msg.Answer) == 0 {
-			return z.verifyNoData(msg, qname, qtype)
+			secure, err := z.verifyNoData(msg, qname, qtype)
+			if secure && err == nil {
+				z.cacheNegative(msg)
+			}
+			return secure, err
 		}
 
-		return verifyAnswer(msg, qname, qtype)
+		return z.verifyAnswer(msg, qname, qtype)
 	}
 
 	if msg.Rcode == dns.RcodeNameError {
-		return z.verifyNameError(msg, qname)
+		secure, err := z.verifyNameError(msg, qname)
+		if secure && err == nil {
+			z.cacheNegative(msg)
+		}
+		return secure, err
 	}
 
-	return false, fmt.Errorf("unexpected rcode %v", msg.Rcode)
+	return false, fmt.Errorf("%s: %w", dns.RcodeToString[msg.Rcode], ErrUnexpectedRcode)
 }
 
 // verifyAnswer pass a verified msg with fqdn canonical qname
-func verifyAnswer(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
+func (z *Zone) verifyAnswer(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
 	if len(msg.Answer) == 0 {
 		return false, errors.New("empty answer")
 	}
 
 	wildcard := false
-	nx := false
 	labels := uint8(dns.CountLabel(qname))
+	sigLabels := labels
 
 	// sanitized answer section
 	var answer []dns.RR
@@ -581,6 +656,27 @@ func verifyAnswer(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
 		t := rr.Header().Rrtype
 		owner := rr.Header().Name
 
+		// DNAME owned by an ancestor of qname RFC 6672 5.3.3
+		if t == dns.TypeDNAME && IsSubDomainStrict(owner, qname) {
+			answer = append(answer, rr)
+			continue
+		}
+
+		if t == dns.TypeRRSIG && IsSubDomainStrict(owner, qname) {
+			sig := rr.(*dns.RRSIG)
+			if sig.TypeCovered != dns.TypeDNAME {
+				continue
+			}
+
+			// DNAME can't be expanded from a wildcard
+			if int(sig.Labels) != dns.CountLabel(owner) {
+				return false, fmt.Errorf("dname %s signed with %d labels: %w", owner, sig.Labels, ErrDNAMESubstitution)
+			}
+
+			answer = append(answer, rr)
+			continue
+		}
+
 		if t == qtype || t == dns.TypeCNAME {
 			// only include rrs that match owner name
 			// TODO: flatten CNAMEs if possible
@@ -597,9 +693,17 @@ func verifyAnswer(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
 				continue
 			}
 
+			// RFC4035 5.3.1 bullet 3
+			if sig.Labels > labels {
+				return false, fmt.Errorf("rrsig labels %d exceed owner %s", sig.Labels, qname)
+			}
+
 			answer = append(answer, rr)
-			if sig.Labels < labels {
+			if sig.Labels < labels && !isWildcardOwner(qname, sig.Labels) {
 				wildcard = true
+				if sig.Labels < sigLabels {
+					sigLabels = sig.Labels
+				}
 			}
 			continue
 		}
@@ -609,26 +713,23 @@ func verifyAnswer(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
 		return false, errors.New("empty answer")
 	}
 
-	msg.Answer = answer
+	// the CNAME synthesized from a DNAME is unsigned
+	// derive it locally instead of trusting the server
+	if dname := findDNAME(answer, qname); dname != nil && !hasOwnedRRs(answer, qname) {
+		cname, err := SynthesizeCNAME(dname, qname)
+		if err != nil {
+			return false, err
+		}
 
-	// if the rrsig is for a wildcard
-	// there must be an NSEC proving the original name
-	// doesn't exist
-	if wildcard {
-		for _, rr := range msg.Ns {
-			if rr.Header().Rrtype != dns.TypeNSEC {
-				continue
-			}
+		answer = append(answer, cname)
+	}
 
-			nsec := rr.(*dns.NSEC)
-			if nx = covers(nsec.Header().Name, nsec.NextDomain, qname); nx {
-				break
-			}
-		}
+	msg.Answer = answer
 
-		if !nx {
-			return false, fmt.Errorf("bad wildcard substitution")
-		}
+	// if the rrsig is for a wildcard there must be a proof
+	// that qname and any closer match don't exist
+	if wildcard {
+		return z.verifyWildcardAnswer(msg, qname, sigLabels)
 	}
 
 	return true, nil
@@ -636,20 +737,14 @@ func verifyAnswer(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
 
 func (z *Zone) verifyNoData(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
 	if len(msg.Ns) == 0 {
-		return false, fmt.Errorf("no nsec records found")
+		return false, fmt.Errorf("no nsec records found: %w", ErrMissingNSEC)
 	}
 
-	for _, rr := range msg.Ns {
-		// no authenticated denial of existence
-		// for NSEC3 for now it should be downgraded
-		if rr.Header().Rrtype == dns.TypeNSEC3 {
-			// must be in bailiwick already checked
-			// by verifySignatures
-			if dns.IsSubDomain(z.Name, rr.Header().Name) {
-				return false, nil
-			}
-		}
+	if hasRRType(msg.Ns, dns.TypeNSEC3) && !hasRRType(msg.Ns, dns.TypeNSEC) && !hasRRType(msg.Ns, dns.TypeDS) {
+		return z.verifyNSEC3NoData(msg, qname, qtype)
+	}
 
+	for _, rr := range msg.Ns {
 		if rr.Header().Rrtype == dns.TypeDS {
 			hasNs := false
 
@@ -684,6 +779,9 @@ func (z *Zone) verifyNoData(msg *dns.Msg, qname string, qtype uint16) (bool, err
 				if !strings.EqualFold(nsec.Header().Name, qname) {
 					// owner name doesn't match
 					// RFC4035 5.4 bullet 2
+					if ok, err := z.verifyWildcardNoData(msg, qname, qtype); ok || err != nil {
+						return ok, err
+					}
 					return z.verifyNameError(msg, qname)
 				}
 
@@ -738,10 +836,14 @@ func (z *Zone) verifyNoData(msg *dns.Msg, qname string, qtype uint16) (bool, err
 		}
 	}
 
-	return false, fmt.Errorf("no valid nsec records found")
+	return false, fmt.Errorf("no valid nsec records found: %w", ErrMissingNSEC)
 }
 
 func (z *Zone) verifyNameError(msg *dns.Msg, qname string) (bool, error) {
+	if hasRRType(msg.Ns, dns.TypeNSEC3) && !hasRRType(msg.Ns, dns.TypeNSEC) {
+		return z.verifyNSEC3NameError(msg, qname)
+	}
+
 	nameProof := false
 	wildcardProof := false
 	qnameParts := dns.SplitDomainName(qname)
@@ -790,11 +892,11 @@ func (z *Zone) verifyNameError(msg *dns.Msg, qname string) (bool, error) {
 	}
 
 	if !nameProof {
-		return false, fmt.Errorf("missing name proof for %s", qname)
+		return false, fmt.Errorf("missing name proof for %s: %w", qname, ErrMissingNSEC)
 	}
 
 	if !wildcardProof {
-		return false, fmt.Errorf("missing wildcard proof for %s", qname)
+		return false, fmt.Errorf("missing wildcard proof for %s: %w", qname, ErrMissingNSEC)
 	}
 
 	return true, nil
@@ -236,6 +236,490 @@ z.example.	300	IN	A	127.0.0.1
 	}
 }
 
+// testNSEC3Chain builds a signed NSEC3 chain for names with
+// their types. Names missing from the map are proven not to exist.
+type testNSEC3Chain struct {
+	signer  *testSigner
+	records []*dns.NSEC3
+	sigs    map[*dns.NSEC3]*dns.RRSIG
+}
+
+func newTestNSEC3Chain(t *testing.T, s *testSigner, iterations uint16, optOut bool, names map[string][]uint16) *testNSEC3Chain {
+	t.Helper()
+
+	const salt = "AABBCCDD"
+	var flags uint8
+	if optOut {
+		flags = 1
+	}
+
+	c := &testNSEC3Chain{signer: s, sigs: make(map[*dns.NSEC3]*dns.RRSIG)}
+	for name, types := range names {
+		c.records = append(c.records, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: dns.HashName(name, dns.SHA1, iterations, salt) + "." + s.name, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 3600},
+			Hash:       dns.SHA1,
+			Flags:      flags,
+			Iterations: iterations,
+			SaltLength: uint8(len(salt) / 2),
+			Salt:       salt,
+			HashLength: 20,
+			TypeBitMap: types,
+		})
+	}
+
+	sort.Slice(c.records, func(i, j int) bool {
+		return c.records[i].Hdr.Name < c.records[j].Hdr.Name
+	})
+
+	for i, nsec3 := range c.records {
+		next := c.records[(i+1)%len(c.records)]
+		nsec3.NextDomain = ownerHash(next)
+		c.sigs[nsec3] = s.signRRs(t, []dns.RR{nsec3}, 0)
+	}
+
+	return c
+}
+
+// proof returns the records matching or covering names
+func (c *testNSEC3Chain) proof(names ...string) []dns.RR {
+	var rrs []dns.RR
+	p := &nsec3Proof{records: c.records, hashes: make(map[string]string)}
+	seen := make(map[*dns.NSEC3]bool)
+
+	for _, name := range names {
+		nsec3 := p.match(name)
+		if nsec3 == nil {
+			nsec3 = p.cover(name)
+		}
+		if nsec3 == nil || seen[nsec3] {
+			continue
+		}
+
+		seen[nsec3] = true
+		rrs = append(rrs, nsec3, c.sigs[nsec3])
+	}
+
+	return rrs
+}
+
+func TestVerifyNSEC3(t *testing.T) {
+	s := newTestSigner(t, "example.")
+	soa := s.sign(t, "example. 3600 IN SOA ns.example. admin.example. 1 3600 600 86400 300")
+
+	names := map[string][]uint16{
+		"example.":       {dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM},
+		"a.example.":     {dns.TypeA, dns.TypeRRSIG},
+		"w.example.":     {},
+		"*.w.example.":   {dns.TypeTXT, dns.TypeRRSIG},
+		"sub.example.":   {dns.TypeNS},
+		"sec.example.":   {dns.TypeNS, dns.TypeDS, dns.TypeRRSIG},
+		"c.example.":     {dns.TypeCNAME, dns.TypeRRSIG},
+		"dname.example.": {dns.TypeDNAME, dns.TypeRRSIG},
+	}
+	chain := newTestNSEC3Chain(t, s, 0, false, names)
+
+	// unsigned delegations are left out of an opt-out chain
+	optOutNames := make(map[string][]uint16)
+	for name, types := range names {
+		if name != "sub.example." {
+			optOutNames[name] = types
+		}
+	}
+	optOut := newTestNSEC3Chain(t, s, 0, true, optOutNames)
+	expensive := newTestNSEC3Chain(t, s, MaxNSEC3Iterations+1, false, names)
+
+	wildcardAnswer := func(qname string) []dns.RR {
+		rr, _ := dns.NewRR("*.w.example. 3600 IN TXT \"wildcard\"")
+		sig := s.signRRs(t, []dns.RR{rr}, 0)
+		rr.Header().Name = qname
+		sig.Header().Name = qname
+		return []dns.RR{rr, sig}
+	}
+
+	delegation, _ := dns.NewRR("sub.example. 3600 IN NS ns.sub.example.")
+
+	tests := []struct {
+		name   string
+		qname  string
+		qtype  uint16
+		rcode  int
+		answer []dns.RR
+		ns     []dns.RR
+		secure bool
+		bogus  bool
+	}{
+		{
+			name:   "nxdomain",
+			qname:  "b.example.",
+			qtype:  dns.TypeA,
+			rcode:  dns.RcodeNameError,
+			ns:     chain.proof("example.", "b.example.", "*.example."),
+			secure: true,
+		},
+		{
+			name:   "nxdomain below existing name",
+			qname:  "x.y.a.example.",
+			qtype:  dns.TypeA,
+			rcode:  dns.RcodeNameError,
+			ns:     chain.proof("a.example.", "y.a.example.", "*.a.example."),
+			secure: true,
+		},
+		{
+			name:  "nxdomain missing wildcard proof",
+			qname: "b.example.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+			ns:    chain.proof("example.", "b.example."),
+			bogus: true,
+		},
+		{
+			name:  "nxdomain missing next closer",
+			qname: "b.example.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+			ns:    chain.proof("example.", "*.example."),
+			bogus: true,
+		},
+		{
+			name:  "nxdomain for existing name",
+			qname: "a.example.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+			ns:    chain.proof("a.example.", "*.example."),
+			bogus: true,
+		},
+		{
+			name:  "nxdomain below delegation",
+			qname: "www.sub.example.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+			ns:    chain.proof("sub.example.", "www.sub.example.", "*.sub.example."),
+			bogus: true,
+		},
+		{
+			name:  "nxdomain below dname",
+			qname: "www.dname.example.",
+			qtype: dns.TypeA,
+			rcode: dns.RcodeNameError,
+			ns:    chain.proof("dname.example.", "www.dname.example.", "*.dname.example."),
+			bogus: true,
+		},
+		{
+			name:   "nxdomain opt-out",
+			qname:  "b.example.",
+			qtype:  dns.TypeA,
+			rcode:  dns.RcodeNameError,
+			ns:     optOut.proof("example.", "b.example.", "*.example."),
+			secure: false,
+		},
+		{
+			name:   "nodata",
+			qname:  "a.example.",
+			qtype:  dns.TypeTXT,
+			ns:     chain.proof("a.example."),
+			secure: true,
+		},
+		{
+			name:  "nodata type exists",
+			qname: "a.example.",
+			qtype: dns.TypeA,
+			ns:    chain.proof("a.example."),
+			bogus: true,
+		},
+		{
+			name:  "nodata cname exists",
+			qname: "c.example.",
+			qtype: dns.TypeA,
+			ns:    chain.proof("c.example."),
+			bogus: true,
+		},
+		{
+			name:   "nodata empty non-terminal",
+			qname:  "w.example.",
+			qtype:  dns.TypeA,
+			ns:     chain.proof("w.example."),
+			secure: true,
+		},
+		{
+			name:   "nodata ds",
+			qname:  "sub.example.",
+			qtype:  dns.TypeDS,
+			ns:     chain.proof("sub.example."),
+			secure: true,
+		},
+		{
+			name:  "nodata from parent side of delegation",
+			qname: "sub.example.",
+			qtype: dns.TypeA,
+			ns:    chain.proof("sub.example."),
+			bogus: true,
+		},
+		{
+			name:   "nodata ds opt-out",
+			qname:  "sub.example.",
+			qtype:  dns.TypeDS,
+			ns:     optOut.proof("example.", "sub.example."),
+			secure: false,
+		},
+		{
+			name:  "nodata ds without opt-out",
+			qname: "b.example.",
+			qtype: dns.TypeDS,
+			ns:    chain.proof("example.", "b.example."),
+			bogus: true,
+		},
+		{
+			name:   "wildcard nodata",
+			qname:  "q.w.example.",
+			qtype:  dns.TypeA,
+			ns:     chain.proof("w.example.", "q.w.example.", "*.w.example."),
+			secure: true,
+		},
+		{
+			name:  "wildcard nodata type exists",
+			qname: "q.w.example.",
+			qtype: dns.TypeTXT,
+			ns:    chain.proof("w.example.", "q.w.example.", "*.w.example."),
+			bogus: true,
+		},
+		{
+			name:   "wildcard answer",
+			qname:  "q.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: wildcardAnswer("q.w.example."),
+			ns:     chain.proof("q.w.example."),
+			secure: true,
+		},
+		{
+			name:   "wildcard answer missing next closer proof",
+			qname:  "q.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: wildcardAnswer("q.w.example."),
+			ns:     chain.proof("w.example."),
+			bogus:  true,
+		},
+		{
+			name:   "wildcard answer opt-out",
+			qname:  "q.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: wildcardAnswer("q.w.example."),
+			ns:     optOut.proof("q.w.example."),
+			secure: false,
+		},
+		{
+			name:   "referral to unsigned subzone",
+			qname:  "www.sub.example.",
+			qtype:  dns.TypeA,
+			ns:     append(chain.proof("sub.example."), delegation),
+			secure: true,
+		},
+		{
+			name:   "referral to unsigned subzone opt-out",
+			qname:  "www.sub.example.",
+			qtype:  dns.TypeA,
+			ns:     append(optOut.proof("example.", "sub.example."), delegation),
+			secure: true,
+		},
+		{
+			name:   "excessive iterations",
+			qname:  "b.example.",
+			qtype:  dns.TypeA,
+			rcode:  dns.RcodeNameError,
+			ns:     expensive.proof("example.", "b.example.", "*.example."),
+			secure: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := new(dns.Msg)
+			msg.SetQuestion(test.qname, test.qtype)
+			msg.Rcode = test.rcode
+			msg.Answer = test.answer
+			msg.Ns = append(append([]dns.RR{}, soa...), test.ns...)
+
+			secure, err := s.zone().Verify(context.Background(), msg, test.qname, test.qtype)
+			if test.bogus {
+				if err == nil {
+					t.Fatal("got no error, want bogus")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secure != test.secure {
+				t.Fatalf("got secure = %v, want %v", secure, test.secure)
+			}
+		})
+	}
+}
+
+func TestVerifyWildcard(t *testing.T) {
+	s := newTestSigner(t, "example.")
+	soa := s.sign(t, "example. 3600 IN SOA ns.example. admin.example. 1 3600 600 86400 300")
+	nsecs := map[string][]dns.RR{
+		"example.":     s.sign(t, "example. 3600 IN NSEC w.example. NS SOA RRSIG NSEC DNSKEY"),
+		"w.example.":   s.sign(t, "w.example. 3600 IN NSEC *.w.example. A RRSIG NSEC"),
+		"*.w.example.": s.sign(t, "*.w.example. 3600 IN NSEC b.w.example. TXT RRSIG NSEC"),
+		"b.w.example.": s.sign(t, "b.w.example. 3600 IN NSEC example. A RRSIG NSEC"),
+	}
+
+	// expanded signs the wildcard RRSet and renames it to qname
+	expanded := func(qname string, labels uint8) []dns.RR {
+		rr, _ := dns.NewRR("*.w.example. 3600 IN TXT \"wildcard\"")
+		sig := s.signRRs(t, []dns.RR{rr}, 0)
+		rr.Header().Name = qname
+		sig.Header().Name = qname
+		if labels > 0 {
+			sig.Labels = labels
+		}
+		return []dns.RR{rr, sig}
+	}
+
+	proof := func(names ...string) []dns.RR {
+		rrs := append([]dns.RR{}, soa...)
+		for _, name := range names {
+			rrs = append(rrs, nsecs[name]...)
+		}
+		return rrs
+	}
+
+	tests := []struct {
+		name   string
+		qname  string
+		qtype  uint16
+		answer []dns.RR
+		ns     []dns.RR
+		secure bool
+		bogus  bool
+	}{
+		{
+			name:   "expanded answer",
+			qname:  "a.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: expanded("a.w.example.", 0),
+			ns:     proof("*.w.example."),
+			secure: true,
+		},
+		{
+			name:   "expanded answer two labels deep",
+			qname:  "x.a.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: expanded("x.a.w.example.", 0),
+			ns:     proof("*.w.example."),
+			secure: true,
+		},
+		{
+			name:   "literal wildcard owner",
+			qname:  "*.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: expanded("*.w.example.", 0),
+			secure: true,
+		},
+		{
+			name:   "missing proof",
+			qname:  "a.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: expanded("a.w.example.", 0),
+			bogus:  true,
+		},
+		{
+			// b.w.example. exists so *.w.example.
+			// can't be used for names below it
+			name:   "closer match exists",
+			qname:  "x.b.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: expanded("x.b.w.example.", 0),
+			ns:     proof("b.w.example."),
+			bogus:  true,
+		},
+		{
+			name:   "labels exceed owner",
+			qname:  "a.w.example.",
+			qtype:  dns.TypeTXT,
+			answer: expanded("a.w.example.", 4),
+			ns:     proof("*.w.example."),
+			bogus:  true,
+		},
+		{
+			name:   "wildcard no data",
+			qname:  "a.w.example.",
+			qtype:  dns.TypeA,
+			ns:     proof("*.w.example."),
+			secure: true,
+		},
+		{
+			name:  "wildcard no data type exists",
+			qname: "a.w.example.",
+			qtype: dns.TypeTXT,
+			ns:    proof("*.w.example."),
+			bogus: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := new(dns.Msg)
+			msg.SetQuestion(test.qname, test.qtype)
+			msg.Answer = test.answer
+			msg.Ns = test.ns
+
+			secure, err := s.zone().Verify(context.Background(), msg, test.qname, test.qtype)
+			if test.bogus {
+				if err == nil {
+					t.Fatal("got no error, want bogus")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secure != test.secure {
+				t.Fatalf("got secure = %v, want %v", secure, test.secure)
+			}
+		})
+	}
+}
+
+func TestSynthesizeCNAME(t *testing.T) {
+	long := strings.Repeat("a", 63)
+	tests := []struct {
+		dname  string
+		qname  string
+		target string
+	}{
+		{"sub.example. 300 IN DNAME other.example.", "www.sub.example.", "www.other.example."},
+		{"sub.example. 300 IN DNAME other.example.", "a.b.SUB.example.", "a.b.other.example."},
+		{"sub.example. 300 IN DNAME .", "www.sub.example.", "www."},
+		// not below the owner
+		{"sub.example. 300 IN DNAME other.example.", "sub.example.", ""},
+		// substitution exceeds the maximum name length
+		{"sub.example. 300 IN DNAME " + long + "." + long + "." + long + ".", long + "." + long + ".sub.example.", ""},
+	}
+
+	for _, test := range tests {
+		rr, err := dns.NewRR(test.dname)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cname, err := SynthesizeCNAME(rr.(*dns.DNAME), test.qname)
+		if test.target == "" {
+			if !errors.Is(err, ErrDNAMESubstitution) {
+				t.Fatalf("%s: got err %v, want %v", test.qname, err, ErrDNAMESubstitution)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.qname, err)
+		}
+		if cname.Hdr.Name != test.qname || cname.Target != test.target || cname.Hdr.Ttl != 300 {
+			t.Fatalf("%s: got %v, want target %s", test.qname, cname, test.target)
+		}
+	}
+}
+
 func sectionsMatch(t *testing.T, name string, a, b []dns.RR) {
 	secA := recordsToZoneSorted(a)
 	secB := recordsToZoneSorted(b)
@@ -0,0 +1,321 @@
+package dnssec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"strings"
+)
+
+// MaxNSEC3Iterations NSEC3 records with more iterations are treated
+// as insecure RFC 9276 3.2
+const MaxNSEC3Iterations = 150
+
+var (
+	ErrNSEC3NoClosestEncloser = errors.New("nsec3 closest encloser proof failed")
+	ErrNSEC3TypeExists        = errors.New("nsec3 proves type exists")
+)
+
+// nsec3Proof NSEC3 records of a zone sharing the same parameters
+type nsec3Proof struct {
+	zone    string
+	records []*dns.NSEC3
+	hashes  map[string]string
+}
+
+// newNSEC3Proof collects usable NSEC3 records from rrs. It returns nil
+// if there are none and insecure if the zone uses NSEC3 parameters
+// that can't be validated.
+func newNSEC3Proof(zone string, rrs []dns.RR) (p *nsec3Proof, insecure bool) {
+	var first *dns.NSEC3
+	for _, rr := range rrs {
+		nsec3, ok := rr.(*dns.NSEC3)
+		if !ok {
+			continue
+		}
+
+		// owner must be <hash>.<zone>
+		labels := dns.SplitDomainName(nsec3.Header().Name)
+		if len(labels) == 0 || !strings.EqualFold(dns.Fqdn(strings.Join(labels[1:], ".")), zone) {
+			continue
+		}
+
+		// RFC 5155 8.2 unknown hash or flags
+		if nsec3.Hash != dns.SHA1 || nsec3.Flags&^1 != 0 {
+			insecure = true
+			continue
+		}
+
+		if nsec3.Iterations > MaxNSEC3Iterations {
+			insecure = true
+			continue
+		}
+
+		if first == nil {
+			first = nsec3
+			p = &nsec3Proof{zone: zone, hashes: make(map[string]string)}
+		}
+
+		// all records in a proof must use the same parameters
+		if nsec3.Iterations != first.Iterations || !strings.EqualFold(nsec3.Salt, first.Salt) {
+			continue
+		}
+
+		p.records = append(p.records, nsec3)
+	}
+
+	if p != nil {
+		insecure = false
+	}
+
+	return
+}
+
+func (p *nsec3Proof) hash(name string) string {
+	name = dns.CanonicalName(name)
+	if h, ok := p.hashes[name]; ok {
+		return h
+	}
+
+	first := p.records[0]
+	h := dns.HashName(name, first.Hash, first.Iterations, first.Salt)
+	p.hashes[name] = h
+	return h
+}
+
+func ownerHash(nsec3 *dns.NSEC3) string {
+	owner := nsec3.Header().Name
+	return strings.ToUpper(owner[:strings.IndexByte(owner, '.')])
+}
+
+// match finds the NSEC3 record matching name
+func (p *nsec3Proof) match(name string) *dns.NSEC3 {
+	h := p.hash(name)
+	for _, nsec3 := range p.records {
+		if ownerHash(nsec3) == h {
+			return nsec3
+		}
+	}
+
+	return nil
+}
+
+// cover finds the NSEC3 record covering the hash of name
+func (p *nsec3Proof) cover(name string) *dns.NSEC3 {
+	h := p.hash(name)
+	for _, nsec3 := range p.records {
+		owner, next := ownerHash(nsec3), strings.ToUpper(nsec3.NextDomain)
+
+		// the last record wraps around
+		if owner < next {
+			if h > owner && h < next {
+				return nsec3
+			}
+			continue
+		}
+		if h > owner || h < next {
+			return nsec3
+		}
+	}
+
+	return nil
+}
+
+// closestEncloser RFC 5155 8.3 returns the closest encloser of qname
+// and the NSEC3 record covering the next closer name
+func (p *nsec3Proof) closestEncloser(qname string) (string, *dns.NSEC3, error) {
+	labels := dns.SplitDomainName(qname)
+	nextCloser := ""
+
+	for i := 0; i <= len(labels); i++ {
+		sname := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !dns.IsSubDomain(p.zone, sname) {
+			break
+		}
+
+		if nsec3 := p.match(sname); nsec3 != nil {
+			if nextCloser == "" {
+				return "", nil, fmt.Errorf("%s exists: %w", qname, ErrNSEC3NoClosestEncloser)
+			}
+
+			// an encloser at a delegation point or a DNAME
+			// belongs to another zone
+			if (hasType(nsec3.TypeBitMap, dns.TypeNS) && !hasType(nsec3.TypeBitMap, dns.TypeSOA)) ||
+				hasType(nsec3.TypeBitMap, dns.TypeDNAME) {
+				return "", nil, fmt.Errorf("closest encloser %s is a delegation: %w", sname, ErrNSEC3NoClosestEncloser)
+			}
+
+			covering := p.cover(nextCloser)
+			if covering == nil {
+				return "", nil, fmt.Errorf("next closer %s not covered: %w", nextCloser, ErrNSEC3NoClosestEncloser)
+			}
+
+			return sname, covering, nil
+		}
+
+		nextCloser = sname
+	}
+
+	return "", nil, fmt.Errorf("no closest encloser for %s: %w", qname, ErrNSEC3NoClosestEncloser)
+}
+
+func isOptOut(nsec3 *dns.NSEC3) bool {
+	return nsec3.Flags&1 == 1
+}
+
+func hasRRType(rrs []dns.RR, t uint16) bool {
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyNSEC3NameError RFC 5155 8.4
+func (z *Zone) verifyNSEC3NameError(msg *dns.Msg, qname string) (bool, error) {
+	p, insecure := newNSEC3Proof(z.Name, msg.Ns)
+	if p == nil {
+		if insecure {
+			return false, nil
+		}
+		return false, fmt.Errorf("no usable nsec3 records for %s: %w", qname, ErrMissingNSEC)
+	}
+
+	ce, nextCloser, err := p.closestEncloser(qname)
+	if err != nil {
+		return false, err
+	}
+
+	if p.cover("*."+ce) == nil {
+		return false, fmt.Errorf("missing nsec3 wildcard proof for %s: %w", qname, ErrMissingNSEC)
+	}
+
+	// an unsigned delegation may exist
+	if isOptOut(nextCloser) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// verifyNSEC3NoData RFC 5155 8.5 - 8.7 and 8.9 for referrals
+// to unsigned subzones
+func (z *Zone) verifyNSEC3NoData(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
+	p, insecure := newNSEC3Proof(z.Name, msg.Ns)
+	if p == nil {
+		if insecure {
+			return false, nil
+		}
+		return false, fmt.Errorf("no usable nsec3 records for %s: %w", qname, ErrMissingNSEC)
+	}
+
+	// referral to an unsigned subzone
+	for _, rr := range msg.Ns {
+		if rr.Header().Rrtype != dns.TypeNS {
+			continue
+		}
+
+		cut := dns.CanonicalName(rr.Header().Name)
+		if !IsSubDomainStrict(z.Name, cut) {
+			return false, fmt.Errorf("bad referral")
+		}
+
+		return p.verifyInsecureDelegation(cut)
+	}
+
+	if nsec3 := p.match(qname); nsec3 != nil {
+		if hasType(nsec3.TypeBitMap, qtype) {
+			return false, ErrNSEC3TypeExists
+		}
+		if hasType(nsec3.TypeBitMap, dns.TypeCNAME) {
+			return false, fmt.Errorf("nsec3 proves cname exists")
+		}
+
+		// parent side of a delegation can
+		// only deny the DS record
+		if qtype != dns.TypeDS && hasType(nsec3.TypeBitMap, dns.TypeNS) &&
+			!hasType(nsec3.TypeBitMap, dns.TypeSOA) {
+			return false, fmt.Errorf("nsec3 from the parent side of a delegation")
+		}
+
+		return true, nil
+	}
+
+	ce, nextCloser, err := p.closestEncloser(qname)
+	if err != nil {
+		return false, err
+	}
+
+	// no DS for a name covered by an opt-out span
+	// means an unsigned delegation may exist RFC 5155 8.6
+	if qtype == dns.TypeDS {
+		if isOptOut(nextCloser) {
+			return false, nil
+		}
+		return false, fmt.Errorf("missing nsec3 opt-out proof for DS %s: %w", qname, ErrMissingNSEC)
+	}
+
+	// wildcard no data RFC 5155 8.7
+	wildcard := p.match("*." + ce)
+	if wildcard == nil {
+		return false, fmt.Errorf("missing nsec3 wildcard no data proof for %s: %w", qname, ErrMissingNSEC)
+	}
+	if hasType(wildcard.TypeBitMap, qtype) || hasType(wildcard.TypeBitMap, dns.TypeCNAME) {
+		return false, ErrNSEC3TypeExists
+	}
+
+	return true, nil
+}
+
+// verifyInsecureDelegation RFC 5155 8.9 proves cut has no DS
+func (p *nsec3Proof) verifyInsecureDelegation(cut string) (bool, error) {
+	if nsec3 := p.match(cut); nsec3 != nil {
+		if !hasType(nsec3.TypeBitMap, dns.TypeNS) {
+			return false, fmt.Errorf("NS isn't set in NSEC3 bitmap")
+		}
+		if hasType(nsec3.TypeBitMap, dns.TypeDS) || hasType(nsec3.TypeBitMap, dns.TypeSOA) {
+			return false, fmt.Errorf("bad insecure delegation proof")
+		}
+
+		return true, nil
+	}
+
+	_, nextCloser, err := p.closestEncloser(cut)
+	if err != nil {
+		return false, err
+	}
+	if !isOptOut(nextCloser) {
+		return false, fmt.Errorf("missing nsec3 opt-out proof for delegation %s: %w", cut, ErrMissingNSEC)
+	}
+
+	return true, nil
+}
+
+// verifyNSEC3Wildcard proves no closer match than the wildcard
+// exists for a wildcard expanded answer RFC 5155 8.8
+func (z *Zone) verifyNSEC3Wildcard(msg *dns.Msg, qname string, labels uint8) (bool, error) {
+	p, insecure := newNSEC3Proof(z.Name, msg.Ns)
+	if p == nil {
+		if insecure {
+			return false, nil
+		}
+		return false, fmt.Errorf("missing nsec3 wildcard proof for %s: %w", qname, ErrMissingNSEC)
+	}
+
+	// the next closer name is one label
+	// longer than the wildcard's parent
+	parts := dns.SplitDomainName(qname)
+	if int(labels) >= len(parts) {
+		return false, fmt.Errorf("bad wildcard labels")
+	}
+
+	nextCloser := dns.Fqdn(strings.Join(parts[len(parts)-int(labels)-1:], "."))
+	covering := p.cover(nextCloser)
+	if covering == nil {
+		return false, fmt.Errorf("next closer %s not covered: %w", nextCloser, ErrNSEC3NoClosestEncloser)
+	}
+
+	return !isOptOut(covering), nil
+}
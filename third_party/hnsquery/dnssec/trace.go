@@ -0,0 +1,143 @@
+package dnssec
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"sync"
+	"time"
+)
+
+// TraceStepKind the kind of validation step recorded in a Trace
+type TraceStepKind string
+
+const (
+	// TraceCut a zone cut was found for a name
+	TraceCut TraceStepKind = "cut"
+	// TraceDS a DS RRSet was used to authenticate a zone
+	TraceDS TraceStepKind = "ds"
+	// TraceDNSKEY a DNSKEY RRSet was validated for a zone
+	TraceDNSKEY TraceStepKind = "dnskey"
+	// TraceRRSIG a signature was checked
+	TraceRRSIG TraceStepKind = "rrsig"
+	// TraceInsecure validation was downgraded to insecure
+	TraceInsecure TraceStepKind = "insecure"
+	// TraceCache the answer came from a cache
+	TraceCache TraceStepKind = "cache"
+	// TraceResult the outcome of validating a response
+	TraceResult TraceStepKind = "result"
+)
+
+// TraceSignature an RRSIG checked during validation
+type TraceSignature struct {
+	Covered    string    `json:"covered"`
+	Algorithm  uint8     `json:"algorithm"`
+	KeyTag     uint16    `json:"keyTag"`
+	SignerName string    `json:"signerName"`
+	Labels     uint8     `json:"labels"`
+	Inception  time.Time `json:"inception"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// TraceStep a single step of a validation
+type TraceStep struct {
+	Kind      TraceStepKind   `json:"kind"`
+	Zone      string          `json:"zone,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Records   []string        `json:"records,omitempty"`
+	Signature *TraceSignature `json:"signature,omitempty"`
+	Secure    bool            `json:"secure"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// Trace records the steps taken to validate a response. A nil
+// Trace is valid and records nothing. It's safe for concurrent use.
+type Trace struct {
+	mu    sync.Mutex
+	steps []TraceStep
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context that records validation steps into t
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFromContext returns the trace attached to ctx or nil
+func TraceFromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// Steps returns a copy of the recorded steps in order
+func (t *Trace) Steps() []TraceStep {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]TraceStep{}, t.steps...)
+}
+
+// Add records a step
+func (t *Trace) Add(step TraceStep) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.steps = append(t.steps, step)
+}
+
+// TraceRecords formats rrs for a trace step
+func TraceRecords(rrs []dns.RR) []string {
+	var records []string
+	for _, rr := range rrs {
+		records = append(records, rr.String())
+	}
+
+	return records
+}
+
+func traceSignature(sig *dns.RRSIG, now time.Time) *TraceSignature {
+	return &TraceSignature{
+		Covered:    dns.TypeToString[sig.TypeCovered],
+		Algorithm:  sig.Algorithm,
+		KeyTag:     sig.KeyTag,
+		SignerName: sig.SignerName,
+		Labels:     sig.Labels,
+		Inception:  sigTime(sig.Inception, now),
+		Expiration: SigExpiration(sig, now),
+	}
+}
+
+// sigTime an RRSIG timestamp using serial number
+// arithmetic RFC 4034 3.1.5
+func sigTime(t uint32, now time.Time) time.Time {
+	const year68 = 1 << 31
+	mod := (int64(t) - now.Unix()) / year68
+	return time.Unix(int64(t)+mod*year68, 0)
+}
+
+func (z *Zone) traceSig(trace *Trace, sig *dns.RRSIG, err error) {
+	if trace == nil {
+		return
+	}
+
+	step := TraceStep{
+		Kind:      TraceRRSIG,
+		Zone:      z.Name,
+		Name:      sig.Header().Name,
+		Signature: traceSignature(sig, z.now()),
+		Secure:    err == nil,
+	}
+	if err != nil {
+		step.Reason = err.Error()
+	}
+
+	trace.Add(step)
+}
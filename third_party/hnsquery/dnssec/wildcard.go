@@ -0,0 +1,115 @@
+package dnssec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"strings"
+)
+
+var ErrBadWildcard = errors.New("bad wildcard substitution")
+
+// isWildcardOwner checks if owner is a literal wildcard
+// name signed with the given labels
+func isWildcardOwner(owner string, labels uint8) bool {
+	return strings.HasPrefix(owner, "*.") && int(labels) == dns.CountLabel(owner)-1
+}
+
+// wildcardParent returns the closest encloser of a name expanded
+// from a wildcard: the rightmost labels of name
+func wildcardParent(name string, labels uint8) string {
+	parts := dns.SplitDomainName(name)
+	if int(labels) >= len(parts) {
+		return dns.Fqdn(name)
+	}
+
+	return dns.Fqdn(strings.Join(parts[len(parts)-int(labels):], "."))
+}
+
+// nsecClosestEncloser the closest encloser of qname
+// proven by an NSEC covering it
+func nsecClosestEncloser(nsec *dns.NSEC, qname string) int {
+	owner := dns.CompareDomainName(nsec.Header().Name, qname)
+	next := dns.CompareDomainName(nsec.NextDomain, qname)
+	if next > owner {
+		return next
+	}
+
+	return owner
+}
+
+// verifyWildcardAnswer RFC 4035 5.3.4 checks that the answer was expanded
+// from the wildcard at the closest encloser given by the RRSIG labels
+func (z *Zone) verifyWildcardAnswer(msg *dns.Msg, qname string, labels uint8) (bool, error) {
+	ce := wildcardParent(qname, labels)
+	if !dns.IsSubDomain(z.Name, ce) {
+		return false, fmt.Errorf("wildcard *.%s out of zone %s: %w", ce, z.Name, ErrBadWildcard)
+	}
+
+	if !hasRRType(msg.Ns, dns.TypeNSEC) && hasRRType(msg.Ns, dns.TypeNSEC3) {
+		return z.verifyNSEC3Wildcard(msg, qname, labels)
+	}
+
+	for _, rr := range msg.Ns {
+		nsec, ok := rr.(*dns.NSEC)
+		if !ok || !covers(nsec.Header().Name, nsec.NextDomain, qname) {
+			continue
+		}
+
+		// a closer match than the wildcard would
+		// have been used instead of it
+		if nsecClosestEncloser(nsec, qname) == int(labels) {
+			return true, nil
+		}
+	}
+
+	return false, fmt.Errorf("no proof %s doesn't exist below *.%s: %w", qname, ce, ErrBadWildcard)
+}
+
+// verifyWildcardNoData RFC 4035 3.1.3.4 proves qname doesn't exist and
+// the matching wildcard doesn't have qtype. It returns false without
+// an error if the response isn't a wildcard no data proof.
+func (z *Zone) verifyWildcardNoData(msg *dns.Msg, qname string, qtype uint16) (bool, error) {
+	var nameProof *dns.NSEC
+	for _, rr := range msg.Ns {
+		if nsec, ok := rr.(*dns.NSEC); ok && covers(nsec.Header().Name, nsec.NextDomain, qname) {
+			nameProof = nsec
+			break
+		}
+	}
+
+	if nameProof == nil {
+		return false, nil
+	}
+
+	// qname is an empty non-terminal
+	labels := nsecClosestEncloser(nameProof, qname)
+	if labels >= dns.CountLabel(qname) {
+		return false, nil
+	}
+
+	ce := wildcardParent(qname, uint8(labels))
+	if !dns.IsSubDomain(z.Name, ce) {
+		return false, nil
+	}
+
+	wildcard := "*." + ce
+	if ce == "." {
+		wildcard = "*."
+	}
+
+	for _, rr := range msg.Ns {
+		nsec, ok := rr.(*dns.NSEC)
+		if !ok || !strings.EqualFold(nsec.Header().Name, wildcard) {
+			continue
+		}
+
+		if hasType(nsec.TypeBitMap, qtype) || hasType(nsec.TypeBitMap, dns.TypeCNAME) {
+			return false, fmt.Errorf("wildcard %s has type %s: %w", wildcard, dns.TypeToString[qtype], ErrBadWildcard)
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -3,8 +3,10 @@ package hnsquery
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
+	"github.com/miekg/dns"
 	"io"
 	"io/ioutil"
 	"net"
@@ -13,6 +15,48 @@ import (
 	"time"
 )
 
+// dohTransport sends queries using DNS over HTTPS (RFC 8484)
+type dohTransport struct {
+	dns      dns.Client
+	http     http.Client
+	endpoint *url.URL
+}
+
+func newDoHTransport(endpoint *url.URL, tlsConfig *tls.Config) *dohTransport {
+	t := &dohTransport{endpoint: endpoint}
+	t.dns.SingleInflight = true
+	t.dns.Net = "doh"
+	t.http.Timeout = time.Second * 10
+
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig.Clone()
+		t.http.Transport = transport
+	}
+
+	return t
+}
+
+func (t *dohTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	re, _, err := t.dns.ExchangeWithConn(msg, &dns.Conn{Conn: &dohConn{
+		endpoint: t.endpoint,
+		http:     &t.http,
+		ctx:      ctx,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if re.Truncated {
+		return nil, ErrTruncated
+	}
+
+	return re, nil
+}
+
+func (t *dohTransport) String() string {
+	return t.endpoint.String()
+}
+
 type dohConn struct {
 	endpoint *url.URL
 	http     *http.Client
@@ -0,0 +1,54 @@
+package hnsquery
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+)
+
+// ValidationError a response for Name that failed DNSSEC validation.
+// It matches ErrDNSSECFailed and unwraps to Reason which is usually
+// one of the dnssec package errors or a FetchError.
+type ValidationError struct {
+	Name   string
+	Reason error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.Name, e.Reason, ErrDNSSECFailed)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Reason
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrDNSSECFailed
+}
+
+// FetchError failed fetching the records of type Type for Zone
+// needed to build the chain of trust
+type FetchError struct {
+	Zone string
+	Type uint16
+	Err  error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("failed fetching %s %s: %v", e.Zone, dns.TypeToString[e.Type], e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// validationError wraps err in a ValidationError
+// unless it already is one
+func validationError(name string, err error) error {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return err
+	}
+
+	return &ValidationError{Name: name, Reason: err}
+}
@@ -0,0 +1,186 @@
+package hnsquery
+
+import (
+	"fmt"
+	"sync"
+)
+
+// eventBuffer events kept for a subscriber that isn't receiving.
+// The oldest events are dropped once it's full.
+const eventBuffer = 32
+
+// EventType what changed in the node
+type EventType int
+
+const (
+	// EventPeerConnected the number of active peers went up
+	EventPeerConnected EventType = iota + 1
+	// EventPeerDisconnected the number of active peers went down
+	EventPeerDisconnected
+	// EventNewHeight the chain tip moved
+	EventNewHeight
+	// EventSynced the chain caught up with the network
+	EventSynced
+	// EventNameRootChanged the name tree root zones
+	// are resolved against changed
+	EventNameRootChanged
+	// EventError the node stopped with Err
+	EventError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPeerConnected:
+		return "peer connected"
+	case EventPeerDisconnected:
+		return "peer disconnected"
+	case EventNewHeight:
+		return "new height"
+	case EventSynced:
+		return "synced"
+	case EventNameRootChanged:
+		return "name root changed"
+	case EventError:
+		return "error"
+	}
+
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// NodeState what Ready, Progress, Height, PeerCount,
+// ActivePeerCount and NameRoot return
+type NodeState struct {
+	Ready       bool
+	Progress    float32
+	Height      uint64
+	TotalPeers  int
+	ActivePeers int
+	NameRoot    [32]byte
+}
+
+// Event a change of the node
+type Event struct {
+	Type EventType
+
+	// State of the node after the change
+	State NodeState
+
+	// Err why the node stopped for EventError
+	Err error
+}
+
+// EventFeed turns node state updates into events for its subscribers.
+// The zero value is ready to use.
+type EventFeed struct {
+	mu     sync.Mutex
+	state  NodeState
+	subs   map[chan Event]struct{}
+	closed bool
+}
+
+// Subscribe returns a channel receiving events until cancel is
+// called or the feed is closed. The oldest events are dropped if the
+// channel isn't drained so the last change is always delivered.
+func (f *EventFeed) Subscribe() (events <-chan Event, cancel func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan Event, eventBuffer)
+	if f.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if f.subs == nil {
+		f.subs = make(map[chan Event]struct{})
+	}
+	f.subs[ch] = struct{}{}
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// State the last state passed to Update
+func (f *EventFeed) State() NodeState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.state
+}
+
+// Update publishes an event for each change from the previous state
+func (f *EventFeed) Update(s NodeState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prev := f.state
+	f.state = s
+
+	switch {
+	case s.ActivePeers > prev.ActivePeers:
+		f.publish(Event{Type: EventPeerConnected, State: s})
+	case s.ActivePeers < prev.ActivePeers:
+		f.publish(Event{Type: EventPeerDisconnected, State: s})
+	}
+
+	if s.Height != prev.Height {
+		f.publish(Event{Type: EventNewHeight, State: s})
+	}
+
+	if s.Ready && !prev.Ready {
+		f.publish(Event{Type: EventSynced, State: s})
+	}
+
+	if s.NameRoot != prev.NameRoot && s.NameRoot != ([32]byte{}) {
+		f.publish(Event{Type: EventNameRootChanged, State: s})
+	}
+}
+
+// Fail publishes an EventError
+func (f *EventFeed) Fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.publish(Event{Type: EventError, State: f.state, Err: err})
+}
+
+// Close closes the channels of all subscribers
+func (f *EventFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	f.closed = true
+	for ch := range f.subs {
+		close(ch)
+	}
+	f.subs = nil
+}
+
+func (f *EventFeed) publish(e Event) {
+	for ch := range f.subs {
+		select {
+		case ch <- e:
+			continue
+		default:
+		}
+
+		// make room by dropping the oldest event. Only publish
+		// sends and it holds f.mu so the send can't block.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- e
+	}
+}
@@ -0,0 +1,84 @@
+package hnsquery
+
+import (
+	"errors"
+	"testing"
+)
+
+func receive(events <-chan Event) []EventType {
+	var types []EventType
+	for {
+		select {
+		case e := <-events:
+			types = append(types, e.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestEventFeed(t *testing.T) {
+	var f EventFeed
+	events, cancel := f.Subscribe()
+
+	f.Update(NodeState{TotalPeers: 8, ActivePeers: 1, Height: 10})
+	f.Update(NodeState{TotalPeers: 8, ActivePeers: 1, Height: 10})
+	f.Update(NodeState{TotalPeers: 8, ActivePeers: 0, Height: 20, Ready: true, NameRoot: [32]byte{1}})
+
+	got := receive(events)
+	want := []EventType{EventPeerConnected, EventNewHeight, EventPeerDisconnected, EventNewHeight, EventSynced, EventNameRootChanged}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	if f.State().Height != 20 {
+		t.Fatalf("got height %d, want 20", f.State().Height)
+	}
+
+	failed := errors.New("failed opening pool")
+	f.Fail(failed)
+	if e := <-events; e.Type != EventError || e.Err != failed || e.State.Height != 20 {
+		t.Fatalf("got %+v, want error at height 20", e)
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Fatal("channel should be closed after cancel")
+	}
+}
+
+func TestEventFeed_Close(t *testing.T) {
+	var f EventFeed
+	events, _ := f.Subscribe()
+
+	// a subscriber that isn't receiving doesn't block updates
+	for i := 0; i < 2*eventBuffer; i++ {
+		f.Update(NodeState{Height: uint64(i + 1)})
+	}
+	// the oldest events are dropped
+	for i := 0; i < eventBuffer; i++ {
+		if e := <-events; e.State.Height != uint64(eventBuffer+i+1) {
+			t.Fatalf("got height %d, want %d", e.State.Height, eventBuffer+i+1)
+		}
+	}
+	if got := len(receive(events)); got != 0 {
+		t.Fatalf("got %d more events, want none", got)
+	}
+
+	f.Close()
+	if _, ok := <-events; ok {
+		t.Fatal("channel should be closed")
+	}
+
+	late, cancel := f.Subscribe()
+	if _, ok := <-late; ok {
+		t.Fatal("subscribing to a closed feed should return a closed channel")
+	}
+	cancel()
+}
@@ -0,0 +1,73 @@
+package hnsquery
+
+import (
+	"context"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls with the same key
+// so that they share one in-flight call and its result
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+
+	// the context of the caller running fn was done
+	// so the result isn't meaningful to the others
+	cancelled bool
+}
+
+// do calls fn unless a call for key is in flight already in which
+// case it waits for its result. fn runs with the context of the
+// caller that started it. If that caller gives up, the waiters
+// whose ctx is still live start the call again with their own fn.
+// Waiters stop waiting when their own ctx is done. A caller
+// with a trace runs fn itself so that its trace has every step.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	if dnssec.TraceFromContext(ctx) != nil {
+		return fn()
+	}
+
+	for {
+		g.mu.Lock()
+		if g.calls == nil {
+			g.calls = make(map[string]*flightCall)
+		}
+		c, ok := g.calls[key]
+		if !ok {
+			break
+		}
+		g.mu.Unlock()
+
+		select {
+		case <-c.done:
+			if c.cancelled && ctx.Err() == nil {
+				continue
+			}
+			return c.val, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+	c.cancelled = ctx.Err() != nil
+	return c.val, c.err
+}
@@ -0,0 +1,84 @@
+package hnsquery
+
+import (
+	"context"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"testing"
+	"time"
+)
+
+// TestFlightGroup_FirstCallerCancels waiters don't
+// inherit the cancellation of the first caller
+func TestFlightGroup_FirstCallerCancels(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go g.do(ctx, "key", func() (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	done := make(chan struct{})
+	var val interface{}
+	var err error
+	go func() {
+		defer close(done)
+		val, err = g.do(context.Background(), "key", func() (interface{}, error) {
+			return "answer", nil
+		})
+	}()
+
+	// the waiter is waiting on the first call
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter should retry the call")
+	}
+	if err != nil || val != "answer" {
+		t.Fatalf("got %v, %v, want answer", val, err)
+	}
+
+	// a waiter that gave up itself gets its own error
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err = g.do(ctx, "key", func() (interface{}, error) {
+		return nil, ctx.Err()
+	}); err != context.Canceled {
+		t.Fatalf("got %v, want context canceled", err)
+	}
+}
+
+// TestFlightGroup_Trace a traced caller doesn't join
+// a call whose steps it wouldn't see
+func TestFlightGroup_Trace(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	go g.do(context.Background(), "key", func() (interface{}, error) {
+		close(started)
+		<-release
+		return "shared", nil
+	})
+	<-started
+
+	trace := &dnssec.Trace{}
+	ctx := dnssec.WithTrace(context.Background(), trace)
+	val, err := g.do(ctx, "key", func() (interface{}, error) {
+		trace.Add(dnssec.TraceStep{Kind: dnssec.TraceCut, Zone: "example."})
+		return "own", nil
+	})
+	if err != nil || val != "own" {
+		t.Fatalf("got %v, %v, want own", val, err)
+	}
+	if len(trace.Steps()) != 1 {
+		t.Fatalf("got %d steps, want 1", len(trace.Steps()))
+	}
+}
@@ -226,6 +226,9 @@ static void hns_call_cgo_cleanup(uv_work_t *req, int status) {
     if (baton->data_len > 0) {
         free(baton->data);
     }
+    if (baton->proof_len > 0) {
+        free(baton->proof);
+    }
 
     free(baton);
 }
@@ -235,7 +238,8 @@ static void hns_call_cgo(uv_work_t *req) {
     if (!baton)
         return;
 
-    cgoAfterResolve(baton->name, baton->status, baton->exists, baton->data, baton->data_len, baton->ctx);
+    cgoAfterResolve(baton->name, baton->status, baton->exists, baton->data, baton->data_len,
+                    baton->root, baton->height, baton->proof, baton->proof_len, baton->ctx);
 }
 
 static void after_resolve(
@@ -244,6 +248,7 @@ static void after_resolve(
         bool exists,
         const uint8_t *data,
         size_t data_len,
+        const hsk_name_proof_t *proof,
         const void *arg
 ) {
     hns_ctx *ctx = (hns_ctx *) arg;
@@ -256,7 +261,21 @@ static void after_resolve(
     baton->status = hsk_to_hns_err(status);
     baton->exists = exists;
     baton->data_len = data_len;
+    baton->height = 0;
+    baton->proof = NULL;
+    baton->proof_len = 0;
     baton->ctx = ctx;
+    memset(baton->root, 0, 32);
+
+    if (proof && proof->data_len > 0) {
+        baton->proof = (uint8_t *) malloc(proof->data_len);
+        if (baton->proof) {
+            memcpy(baton->proof, proof->data, proof->data_len);
+            memcpy(baton->root, proof->root, 32);
+            baton->height = proof->height;
+            baton->proof_len = proof->data_len;
+        }
+    }
 
     if (data_len > 0) {
         baton->data = (uint8_t *) malloc(data_len);
@@ -301,7 +320,7 @@ static void resolve_name(hns_ctx *ctx, const char *name) {
     }
 
     if (rc == HNS_SUCCESS) {
-        rc = hsk_pool_resolve(ctx->pool, name, after_resolve, (void *) ctx);
+        rc = hsk_pool_resolve_proof(ctx->pool, name, after_resolve, (void *) ctx);
         if (rc == HSK_SUCCESS)
             return;
 
@@ -315,7 +334,11 @@ static void resolve_name(hns_ctx *ctx, const char *name) {
     baton->exists = 0;
     baton->data_len = 0;
     baton->data = NULL;
+    baton->height = 0;
+    baton->proof = NULL;
+    baton->proof_len = 0;
     baton->ctx = ctx;
+    memset(baton->root, 0, 32);
 
     uv_queue_work(ctx->loop, &baton->req, hns_call_cgo, hns_call_cgo_cleanup);
 }
@@ -399,29 +422,40 @@ static void update_pool_state(hns_ctx *ctx) {
             active_peers++;
     }
 
-    uv_rwlock_wrlock(&ctx->pool_state->lock);
-    ctx->pool_state->chain_ready = ready;
-    ctx->pool_state->chain_height = ctx->pool->chain.height;
-    ctx->pool_state->sync_progress = progress;
-    ctx->pool_state->total_peers = total_peers;
-    ctx->pool_state->active_peers = active_peers;
-    uv_rwlock_wrunlock(&ctx->pool_state->lock);
+    uint32_t height = (uint32_t) ctx->pool->chain.height;
 
     // If there's enough proof-of-work
     // on top of the most recent root,
     // it should be safe to use it.
-    uint32_t mod = (uint32_t)ctx->pool->chain.height % 36;
+    uint32_t mod = height % 36;
     if (mod >= 12) mod = 0;
-    uint32_t root_height = (uint32_t)ctx->pool->chain.height - mod;
+    uint32_t root_height = height - mod;
     const hsk_header_t *hdr = hsk_chain_get_by_height(&ctx->pool->chain, root_height);
-    if (!hdr) {
-        return;
-    }
 
-    const uint8_t *root = hdr->name_root;
+    uint8_t root[32];
     uv_rwlock_wrlock(&ctx->pool_state->lock);
-    memcpy(&ctx->pool_state->name_root, root, 32);
+    bool changed = ctx->pool_state->chain_ready != ready ||
+                   ctx->pool_state->chain_height != height ||
+                   ctx->pool_state->total_peers != total_peers ||
+                   ctx->pool_state->active_peers != active_peers;
+
+    ctx->pool_state->chain_ready = ready;
+    ctx->pool_state->chain_height = height;
+    ctx->pool_state->sync_progress = progress;
+    ctx->pool_state->total_peers = total_peers;
+    ctx->pool_state->active_peers = active_peers;
+
+    if (hdr && memcmp(ctx->pool_state->name_root, hdr->name_root, 32) != 0) {
+        memcpy(&ctx->pool_state->name_root, hdr->name_root, 32);
+        changed = true;
+    }
+    memcpy(root, ctx->pool_state->name_root, 32);
     uv_rwlock_wrunlock(&ctx->pool_state->lock);
+
+    // subscribers only hear about changes
+    if (changed) {
+        cgoAfterPoolUpdate(ready, height, progress, total_peers, active_peers, root, ctx);
+    }
 }
 
 static void sync_timer_tick(uv_timer_t *handle) {
@@ -23,7 +23,6 @@ import (
 	"math/rand"
 	"path"
 	"sync"
-	"time"
 	"unsafe"
 
 	"github.com/miekg/dns"
@@ -33,6 +32,7 @@ var ErrNotSynced = fmt.Errorf("client is still syncing")
 var ErrTimeout = fmt.Errorf("request timed out")
 var ErrCancelled = fmt.Errorf("operation cancelled")
 var ErrNoPeers = fmt.Errorf("no peers")
+var ErrRequestFailed = fmt.Errorf("hns request failed")
 
 type Config struct {
 	DataDir string
@@ -111,10 +111,10 @@ func hskCodeToError(code C.int) error {
 	case C.HNS_ENOTSYNCED:
 		return ErrNotSynced
 	case C.HNS_ENOMEM:
-		return fmt.Errorf("out of memory")
+		return fmt.Errorf("out of memory: %w", ErrRequestFailed)
 	}
 
-	return fmt.Errorf("hns error (code: %d)", int(code))
+	return fmt.Errorf("hns error (code: %d): %w", int(code), ErrRequestFailed)
 }
 
 func (client *Client) didStart() bool {
@@ -139,36 +139,53 @@ func (client *Client) Run() error {
 	client.ctx = nil
 
 	defer close(client.closed)
+	defer client.callbacks.events.Close()
 
 	if r != C.HNS_SUCCESS {
-		return hskCodeToError(r)
+		err := hskCodeToError(r)
+		client.callbacks.events.Fail(err)
+		return err
 	}
 
 	return nil
 }
 
+// Subscribe returns a channel receiving changes of the peers, chain tip,
+// sync state and name root until cancel is called or the client stops.
+// Events are dropped if the channel isn't drained.
+func (client *Client) Subscribe() (events <-chan Event, cancel func()) {
+	return client.callbacks.events.Subscribe()
+}
+
 func (client *Client) Start(ready chan error) {
 	stop := make(chan error, 1)
+	events, cancel := client.Subscribe()
 
 	go func() {
 		err := client.Run()
 		stop <- err
 	}()
 
-	readyTicker := time.NewTicker(300 * time.Millisecond)
 	go func() {
+		defer cancel()
+
 		for {
 			select {
 			case err := <-stop:
 				ready <- err
 				return
-			case <-readyTicker.C:
-				if client.Ready() && client.ActivePeerCount() > 0 {
+			case e, ok := <-events:
+				// closed once Run returns
+				if !ok {
+					events = nil
+					continue
+				}
+				if e.State.Ready && e.State.ActivePeers > 0 {
 					ready <- nil
 					return
 				}
 			case <-client.closing:
-				log.Println("shutting down ticker")
+				log.Println("shutting down ready listener")
 				return
 			}
 		}
@@ -181,8 +198,10 @@ func (client *Client) Destroy() error {
 	if client.didStart() {
 		client.shutdown()
 		<-client.closed
+		return nil
 	}
 
+	client.callbacks.events.Close()
 	return nil
 }
 
@@ -207,10 +226,19 @@ func (client *Client) shutdown() error {
 }
 
 func (client *Client) GetZone(ctx context.Context, name string) (rrs []dns.RR, err error) {
+	rrs, _, err = client.GetZoneWithProof(ctx, name)
+	return
+}
+
+// GetZoneWithProof is like GetZone and also returns the Urkel proof
+// the zone was verified with so that it can be verified again with
+// ZoneProof.Verify. The proof is nil if no peer answered.
+func (client *Client) GetZoneWithProof(ctx context.Context, name string) (rrs []dns.RR, proof *ZoneProof, err error) {
 	resultReady := make(chan struct{}, 1)
 
-	var f CallbackFunc = func(res []dns.RR, resErr error) {
+	var f CallbackFunc = func(res []dns.RR, resProof *ZoneProof, resErr error) {
 		rrs = res
+		proof = resProof
 		if resErr != nil {
 			err = fmt.Errorf("failed resolving zone %s: %w", name, resErr)
 		}
@@ -62,6 +62,11 @@ typedef struct hns_cgo_baton {
     bool exists;
     uint8_t * data;
     size_t data_len;
+    // Urkel proof of the name
+    uint8_t root[32];
+    uint32_t height;
+    uint8_t * proof;
+    size_t proof_len;
     hns_ctx *ctx;
 } hns_cgo_baton;
 
@@ -71,6 +76,21 @@ extern void cgoAfterResolve(
         bool exists,
         const uint8_t * data,
         size_t data_len,
+        const uint8_t * root,
+        uint32_t height,
+        const uint8_t * proof,
+        size_t proof_len,
+        const void * arg
+);
+
+// Called from the event loop when the pool state changed
+extern void cgoAfterPoolUpdate(
+        int ready,
+        uint32_t height,
+        float progress,
+        int total_peers,
+        int active_peers,
+        const uint8_t * name_root,
         const void * arg
 );
 
@@ -0,0 +1,411 @@
+package hnsquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/golang-lru"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"time"
+)
+
+// ZoneQuery looks up a TLD in the Handshake root zone
+type ZoneQuery interface {
+	GetZone(ctx context.Context, name string) (rrs []dns.RR, err error)
+}
+
+const (
+	defaultMaxReferrals = 10
+	defaultMaxQueries   = 50
+	maxNSLookupDepth    = 3
+	maxCNAMERestarts    = 8
+	nsCacheSize         = 500
+)
+
+var ErrMaxReferrals = errors.New("max referrals reached")
+var ErrLameDelegation = errors.New("lame delegation")
+
+type nsCacheEntry struct {
+	addrs  []net.IP
+	expire time.Time
+}
+
+type delegation struct {
+	zone string
+	ns   []string
+	glue map[string][]net.IP
+}
+
+// iterator is a Transport that resolves queries by following
+// referrals starting from the TLD nameservers published
+// in the Handshake root zone.
+type iterator struct {
+	zones        ZoneQuery
+	maxReferrals int
+	maxQueries   int
+	nsCache      *lru.Cache
+	timeout      time.Duration
+
+	// for testing
+	serverAddr func(ip net.IP) string
+}
+
+// iterState limits the work done for a single query
+type iterState struct {
+	queries int
+}
+
+func newIterator(zones ZoneQuery, maxReferrals int) (*iterator, error) {
+	c, err := lru.New(nsCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxReferrals <= 0 {
+		maxReferrals = defaultMaxReferrals
+	}
+
+	return &iterator{
+		zones:        zones,
+		maxReferrals: maxReferrals,
+		maxQueries:   defaultMaxQueries,
+		nsCache:      c,
+		timeout:      2 * time.Second,
+		serverAddr: func(ip net.IP) string {
+			return net.JoinHostPort(ip.String(), "53")
+		},
+	}, nil
+}
+
+func (it *iterator) String() string {
+	return "iterative"
+}
+
+func (it *iterator) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	if len(msg.Question) != 1 {
+		return nil, fmt.Errorf("bad question section")
+	}
+
+	return it.resolve(ctx, msg, &iterState{}, 0)
+}
+
+// resolve iterates qname and restarts on CNAMEs like a recursive
+// resolver would so that the full chain is returned
+func (it *iterator) resolve(ctx context.Context, msg *dns.Msg, state *iterState, depth int) (*dns.Msg, error) {
+	q := msg.Question[0]
+	target := dns.CanonicalName(q.Name)
+	visited := make(map[string]struct{})
+	var chain []dns.RR
+
+	for restarts := 0; restarts <= maxCNAMERestarts; restarts++ {
+		if _, ok := visited[target]; ok {
+			return nil, fmt.Errorf("cname loop detected at %s", target)
+		}
+		visited[target] = struct{}{}
+
+		query := msg.Copy()
+		query.Id = dns.Id()
+		query.RecursionDesired = false
+		query.Question[0].Name = target
+
+		re, err := it.iterate(ctx, query, state, depth)
+		if err != nil {
+			return nil, err
+		}
+
+		re.Id = msg.Id
+		re.Question = msg.Question
+		re.Authoritative = false
+		re.RecursionAvailable = true
+		re.Answer = append(chain, re.Answer...)
+
+		next := ""
+		if re.Rcode == dns.RcodeSuccess && q.Qtype != dns.TypeCNAME {
+			next = cnameTarget(re.Answer[len(chain):], target, q.Qtype)
+		}
+		if next == "" {
+			return re, nil
+		}
+
+		chain = re.Answer
+		target = next
+	}
+
+	return nil, fmt.Errorf("max cname restarts reached for %s", q.Name)
+}
+
+// cnameTarget returns the target if the answer only
+// contains a CNAME for name
+func cnameTarget(answer []dns.RR, name string, qtype uint16) string {
+	target := ""
+	for _, rr := range answer {
+		if rr.Header().Rrtype == qtype {
+			return ""
+		}
+		if rr.Header().Rrtype == dns.TypeCNAME && strings.EqualFold(rr.Header().Name, name) {
+			target = dns.CanonicalName(rr.(*dns.CNAME).Target)
+		}
+	}
+
+	return target
+}
+
+func (it *iterator) iterate(ctx context.Context, query *dns.Msg, state *iterState, depth int) (*dns.Msg, error) {
+	qname := query.Question[0].Name
+	labels := dns.SplitDomainName(qname)
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("root zone is served by the trust anchor")
+	}
+
+	tld := labels[len(labels)-1]
+	rrs, err := it.zones.GetZone(ctx, tld)
+	if err != nil {
+		return nil, err
+	}
+
+	// the handshake root is authoritative for DS records
+	// of the TLD and names that don't exist on chain
+	if (len(labels) == 1 && query.Question[0].Qtype == dns.TypeDS) || len(rrs) == 0 {
+		return rootResponse(query, rrs), nil
+	}
+
+	deleg := delegationFromRecords(dns.Fqdn(tld), rrs, ".")
+	if len(deleg.ns) == 0 {
+		return rootResponse(query, rrs), nil
+	}
+
+	for referrals := 0; referrals < it.maxReferrals; referrals++ {
+		re, err := it.queryDelegation(ctx, deleg, query, state, depth)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := referral(re, deleg.zone, qname)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			return re, nil
+		}
+
+		deleg = next
+	}
+
+	return nil, fmt.Errorf("resolving %s: %w", qname, ErrMaxReferrals)
+}
+
+// rootResponse answers a query from the TLD records
+// published on chain
+func rootResponse(query *dns.Msg, rrs []dns.RR) *dns.Msg {
+	re := new(dns.Msg)
+	re.SetReply(query)
+
+	if len(rrs) == 0 {
+		re.Rcode = dns.RcodeNameError
+		return re
+	}
+
+	q := query.Question[0]
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == q.Qtype && strings.EqualFold(rr.Header().Name, q.Name) {
+			re.Answer = append(re.Answer, rr)
+		}
+	}
+
+	return re
+}
+
+// delegationFromRecords extracts NS and glue records for zone. Glue is
+// only accepted if it's within the bailiwick of the parent zone.
+func delegationFromRecords(zone string, rrs []dns.RR, bailiwick string) *delegation {
+	deleg := &delegation{
+		zone: dns.CanonicalName(zone),
+		glue: make(map[string][]net.IP),
+	}
+
+	for _, rr := range rrs {
+		if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(ns.Header().Name, zone) {
+			deleg.ns = append(deleg.ns, dns.CanonicalName(ns.Ns))
+		}
+	}
+
+	for _, rr := range rrs {
+		owner := dns.CanonicalName(rr.Header().Name)
+		if !dns.IsSubDomain(bailiwick, owner) {
+			continue
+		}
+
+		switch glue := rr.(type) {
+		case *dns.A:
+			deleg.glue[owner] = append(deleg.glue[owner], glue.A)
+		case *dns.AAAA:
+			deleg.glue[owner] = append(deleg.glue[owner], glue.AAAA)
+		}
+	}
+
+	return deleg
+}
+
+// referral returns the next delegation if the response is a referral
+// to a child of zone, or nil if it's a final answer
+func referral(re *dns.Msg, zone, qname string) (*delegation, error) {
+	if re.Rcode != dns.RcodeSuccess || len(re.Answer) > 0 {
+		return nil, nil
+	}
+
+	child := ""
+	for _, rr := range re.Ns {
+		switch rr.Header().Rrtype {
+		case dns.TypeSOA:
+			// authoritative negative answer
+			return nil, nil
+		case dns.TypeNS:
+			child = dns.CanonicalName(rr.Header().Name)
+		}
+	}
+
+	if child == "" {
+		return nil, nil
+	}
+
+	// referrals must lead closer to qname
+	if !dns.IsSubDomain(zone, child) || strings.EqualFold(zone, child) || !dns.IsSubDomain(child, qname) {
+		return nil, fmt.Errorf("referral from %s to %s for %s: %w", zone, child, qname, ErrLameDelegation)
+	}
+
+	rrs := append(append([]dns.RR{}, re.Ns...), re.Extra...)
+	return delegationFromRecords(child, rrs, zone), nil
+}
+
+func (it *iterator) queryDelegation(ctx context.Context, deleg *delegation, query *dns.Msg, state *iterState, depth int) (*dns.Msg, error) {
+	lastErr := fmt.Errorf("no usable nameservers for %s", deleg.zone)
+
+	for _, ns := range deleg.ns {
+		addrs, err := it.nsAddrs(ctx, deleg, ns, state, depth)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, ip := range addrs {
+			if state.queries >= it.maxQueries {
+				return nil, fmt.Errorf("max queries reached resolving %s", query.Question[0].Name)
+			}
+			state.queries++
+
+			re, err := it.exchange(ctx, ip, query)
+			if err != nil {
+				lastErr = fmt.Errorf("nameserver %s (%s): %v", ns, ip, err)
+				continue
+			}
+
+			if re.Rcode == dns.RcodeServerFailure || re.Rcode == dns.RcodeRefused {
+				lastErr = fmt.Errorf("nameserver %s (%s) returned %s", ns, ip, dns.RcodeToString[re.Rcode])
+				continue
+			}
+
+			return re, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (it *iterator) exchange(ctx context.Context, ip net.IP, query *dns.Msg) (*dns.Msg, error) {
+	tctx, cancel := context.WithTimeout(ctx, it.timeout)
+	defer cancel()
+
+	return newUDPTransport(it.serverAddr(ip)).Exchange(tctx, query)
+}
+
+// nsAddrs finds addresses for a nameserver from glue, synth records,
+// the nameserver cache or by resolving them
+func (it *iterator) nsAddrs(ctx context.Context, deleg *delegation, ns string, state *iterState, depth int) ([]net.IP, error) {
+	if addrs, ok := deleg.glue[ns]; ok {
+		it.cacheAddrs(ns, addrs, HandshakeTTL)
+		return addrs, nil
+	}
+
+	if ip := synthToIP(ns); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	if entry, ok := it.nsCache.Get(ns); ok {
+		entry := entry.(*nsCacheEntry)
+		if time.Now().Before(entry.expire) {
+			return entry.addrs, nil
+		}
+		it.nsCache.Remove(ns)
+	}
+
+	if depth >= maxNSLookupDepth {
+		return nil, fmt.Errorf("max depth reached resolving nameserver %s", ns)
+	}
+
+	var addrs []net.IP
+	ttl := HandshakeTTL
+	var lastErr error
+	for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(ns, t)
+
+		re, err := it.resolve(ctx, msg, state, depth+1)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, rr := range re.Answer {
+			switch addr := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, addr.A)
+			case *dns.AAAA:
+				addrs = append(addrs, addr.AAAA)
+			default:
+				continue
+			}
+			if rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("nameserver %s has no addresses", ns)
+		}
+		return nil, lastErr
+	}
+
+	it.cacheAddrs(ns, addrs, ttl)
+	return addrs, nil
+}
+
+func (it *iterator) cacheAddrs(ns string, addrs []net.IP, ttl uint32) {
+	it.nsCache.Add(ns, &nsCacheEntry{
+		addrs:  addrs,
+		expire: time.Now().Add(time.Duration(ttl) * time.Second),
+	})
+}
+
+// synthToIP decodes the address of a SYNTH4/SYNTH6
+// nameserver name `_<base32hex ip>._synth.`
+func synthToIP(name string) net.IP {
+	labels := dns.SplitDomainName(name)
+	if len(labels) != 2 || !strings.EqualFold(labels[1], "_synth") || !strings.HasPrefix(labels[0], "_") {
+		return nil
+	}
+
+	ip, err := base32Hex.DecodeString(strings.ToLower(labels[0][1:]))
+	if err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+		return nil
+	}
+
+	return ip
+}
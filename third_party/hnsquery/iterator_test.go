@@ -0,0 +1,387 @@
+package hnsquery
+
+import (
+	"context"
+	"errors"
+	"github.com/imperviousinc/hnsquery/dnssec"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testAuthority is a minimal authoritative server for a set of zones
+type testAuthority struct {
+	zones   map[string][]dns.RR
+	queries int32
+}
+
+func newTestAuthority(zones map[string]string) *testAuthority {
+	a := &testAuthority{zones: make(map[string][]dns.RR)}
+	for apex, zone := range zones {
+		a.zones[dns.CanonicalName(apex)] = zoneRecords(zone)
+	}
+	return a
+}
+
+func zoneRecords(zone string) []dns.RR {
+	var rrs []dns.RR
+	zp := dns.NewZoneParser(strings.NewReader(zone), "", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		panic(err)
+	}
+	return rrs
+}
+
+func (a *testAuthority) ServeDNS(w dns.ResponseWriter, msg *dns.Msg) {
+	atomic.AddInt32(&a.queries, 1)
+
+	re := new(dns.Msg)
+	re.SetReply(msg)
+	q := msg.Question[0]
+	qname := dns.CanonicalName(q.Name)
+
+	apex := ""
+	for zone := range a.zones {
+		if dns.IsSubDomain(zone, qname) && dns.CountLabel(zone) >= dns.CountLabel(apex) {
+			apex = zone
+		}
+	}
+	if apex == "" {
+		re.Rcode = dns.RcodeRefused
+		w.WriteMsg(re)
+		return
+	}
+	records := a.zones[apex]
+
+	// referral to the deepest delegation
+	cut := ""
+	for _, rr := range records {
+		owner := dns.CanonicalName(rr.Header().Name)
+		if rr.Header().Rrtype == dns.TypeNS && owner != apex && dns.IsSubDomain(owner, qname) &&
+			dns.CountLabel(owner) > dns.CountLabel(cut) {
+			cut = owner
+		}
+	}
+	if cut != "" && !(q.Qtype == dns.TypeDS && qname == cut) {
+		for _, rr := range records {
+			if rr.Header().Rrtype == dns.TypeNS && dns.CanonicalName(rr.Header().Name) == cut {
+				re.Ns = append(re.Ns, rr)
+				for _, glue := range records {
+					t := glue.Header().Rrtype
+					if (t == dns.TypeA || t == dns.TypeAAAA) && strings.EqualFold(glue.Header().Name, rr.(*dns.NS).Ns) {
+						re.Extra = append(re.Extra, glue)
+					}
+				}
+			}
+		}
+		w.WriteMsg(re)
+		return
+	}
+
+	re.Authoritative = true
+	exists := false
+	var cname []dns.RR
+	for _, rr := range records {
+		owner := dns.CanonicalName(rr.Header().Name)
+		if dns.IsSubDomain(qname, owner) {
+			exists = true
+		}
+		if owner != qname {
+			continue
+		}
+		if rr.Header().Rrtype == q.Qtype {
+			re.Answer = append(re.Answer, rr)
+		}
+		if rr.Header().Rrtype == dns.TypeCNAME {
+			cname = append(cname, rr)
+		}
+	}
+
+	if len(re.Answer) == 0 {
+		re.Answer = cname
+	}
+
+	if len(re.Answer) == 0 {
+		if !exists {
+			re.Rcode = dns.RcodeNameError
+		}
+		for _, rr := range records {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				re.Ns = append(re.Ns, rr)
+			}
+		}
+	}
+
+	w.WriteMsg(re)
+}
+
+type testRoot map[string]string
+
+func (r testRoot) GetZone(ctx context.Context, name string) ([]dns.RR, error) {
+	zone, ok := r[name]
+	if !ok {
+		return nil, nil
+	}
+	return zoneRecords(zone), nil
+}
+
+// newTestIterator starts authorities and routes
+// the listed addresses to them
+func newTestIterator(t *testing.T, root testRoot, servers map[string]*testAuthority) *iterator {
+	t.Helper()
+
+	addrs := make(map[string]string)
+	for ip, authority := range servers {
+		addrs[ip] = startTestServer(t, authority.ServeDNS)
+	}
+
+	it, err := newIterator(root, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it.serverAddr = func(ip net.IP) string {
+		if addr, ok := addrs[ip.String()]; ok {
+			return addr
+		}
+		// unroutable
+		return "127.0.0.1:1"
+	}
+
+	return it
+}
+
+var testHNSRoot = testRoot{
+	"hns": `
+hns. 21600 IN NS ns1.hns.
+ns1.hns. 21600 IN A 10.0.0.1
+`,
+	"synth": `
+synth. 21600 IN NS _180000g._synth.
+`,
+}
+
+func testHNSAuthorities() map[string]*testAuthority {
+	return map[string]*testAuthority{
+		"10.0.0.1": newTestAuthority(map[string]string{"hns.": `
+hns. 300 IN SOA ns1.hns. admin.hns. 1 3600 600 86400 300
+hns. 300 IN NS ns1.hns.
+ns1.hns. 300 IN A 10.0.0.1
+www.hns. 300 IN A 192.0.2.1
+alias.hns. 300 IN CNAME www.sub.hns.
+sub.hns. 300 IN NS ns.sub.hns.
+ns.sub.hns. 300 IN A 10.0.0.2
+noglue.hns. 300 IN NS ns.sub.hns.
+loop.hns. 300 IN NS ns1.hns.
+`}),
+		"10.0.0.2": newTestAuthority(map[string]string{
+			"sub.hns.": `
+sub.hns. 300 IN SOA ns.sub.hns. admin.sub.hns. 1 3600 600 86400 300
+sub.hns. 300 IN NS ns.sub.hns.
+ns.sub.hns. 300 IN A 10.0.0.2
+www.sub.hns. 300 IN A 192.0.2.2
+`,
+			"noglue.hns.": `
+noglue.hns. 300 IN SOA ns.sub.hns. admin.sub.hns. 1 3600 600 86400 300
+www.noglue.hns. 300 IN A 192.0.2.3
+`,
+			"synth.": `
+synth. 300 IN SOA ns.synth. admin.synth. 1 3600 600 86400 300
+www.synth. 300 IN A 192.0.2.4
+`}),
+	}
+}
+
+func iterQuery(t *testing.T, it *iterator, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	re, err := it.Exchange(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return re
+}
+
+func TestIterator_Exchange(t *testing.T) {
+	it := newTestIterator(t, testHNSRoot, testHNSAuthorities())
+
+	tests := []struct {
+		name   string
+		qtype  uint16
+		rcode  int
+		answer string
+	}{
+		{"www.hns.", dns.TypeA, dns.RcodeSuccess, "192.0.2.1"},
+		{"www.sub.hns.", dns.TypeA, dns.RcodeSuccess, "192.0.2.2"},
+		// cname chain is followed across zones
+		{"alias.hns.", dns.TypeA, dns.RcodeSuccess, "192.0.2.2"},
+		// nameserver without glue is resolved iteratively
+		{"www.noglue.hns.", dns.TypeA, dns.RcodeSuccess, "192.0.2.3"},
+		// synth nameservers encode their address
+		{"www.synth.", dns.TypeA, dns.RcodeSuccess, "192.0.2.4"},
+		{"missing.sub.hns.", dns.TypeA, dns.RcodeNameError, ""},
+		{"www.sub.hns.", dns.TypeTXT, dns.RcodeSuccess, ""},
+		// not on chain
+		{"www.nonexistent.", dns.TypeA, dns.RcodeNameError, ""},
+	}
+
+	for _, test := range tests {
+		re := iterQuery(t, it, test.name, test.qtype)
+		if re.Rcode != test.rcode {
+			t.Fatalf("%s: got rcode %s, want %s", test.name, dns.RcodeToString[re.Rcode], dns.RcodeToString[test.rcode])
+		}
+		if re.Question[0].Name != test.name {
+			t.Fatalf("%s: got question %s", test.name, re.Question[0].Name)
+		}
+
+		got := ""
+		for _, rr := range re.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				got = a.A.String()
+			}
+		}
+		if got != test.answer {
+			t.Fatalf("%s: got answer %q, want %q", test.name, got, test.answer)
+		}
+	}
+}
+
+func TestIterator_NSCache(t *testing.T) {
+	authorities := testHNSAuthorities()
+	it := newTestIterator(t, testHNSRoot, authorities)
+
+	iterQuery(t, it, "www.noglue.hns.", dns.TypeA)
+	if _, ok := it.nsCache.Get("ns.sub.hns."); !ok {
+		t.Fatal("nameserver address should be cached")
+	}
+
+	before := atomic.LoadInt32(&authorities["10.0.0.1"].queries)
+	iterQuery(t, it, "www.noglue.hns.", dns.TypeA)
+
+	// one query for the referral, the nameserver address is cached
+	if got := atomic.LoadInt32(&authorities["10.0.0.1"].queries) - before; got != 1 {
+		t.Fatalf("got %d queries to the tld server, want 1", got)
+	}
+}
+
+func TestIterator_Limits(t *testing.T) {
+	it := newTestIterator(t, testHNSRoot, testHNSAuthorities())
+
+	// loop.hns is delegated back to the same server
+	// which keeps referring to itself
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.loop.hns.", dns.TypeA)
+	if _, err := it.Exchange(context.Background(), msg); !errors.Is(err, ErrLameDelegation) {
+		t.Fatalf("got err = %v, want %v", err, ErrLameDelegation)
+	}
+
+	it.maxReferrals = 1
+	msg.SetQuestion("www.sub.hns.", dns.TypeA)
+	if _, err := it.Exchange(context.Background(), msg); !errors.Is(err, ErrMaxReferrals) {
+		t.Fatalf("got err = %v, want %v", err, ErrMaxReferrals)
+	}
+}
+
+func TestIterator_DS(t *testing.T) {
+	root := testRoot{"hns": testHNSRoot["hns"] + `
+hns. 21600 IN DS 60767 15 2 FAF50B8DC0DED5B28E5388F5047805C7417678BE7CAC3AB5DF93823E9220D87B
+`}
+
+	it := newTestIterator(t, root, nil)
+
+	// answered from chain without contacting the tld servers
+	re := iterQuery(t, it, "hns.", dns.TypeDS)
+	if len(re.Answer) != 1 || re.Answer[0].Header().Rrtype != dns.TypeDS {
+		t.Fatalf("got answer %v, want DS from chain", re.Answer)
+	}
+}
+
+func TestSynthToIP(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+	}{
+		{"_180000g._synth.", "10.0.0.2"},
+		{"_180000G._SYNTH.", "10.0.0.2"},
+		{"_180000g.synth.", "<nil>"},
+		{"180000g._synth.", "<nil>"},
+		{"_18._synth.", "<nil>"},
+	}
+
+	for _, test := range tests {
+		if got := synthToIP(test.name).String(); got != test.ip {
+			t.Fatalf("%s: got %s, want %s", test.name, got, test.ip)
+		}
+	}
+}
+
+func TestResolver_Iterative(t *testing.T) {
+	r, err := NewResolver(&ResolverConfig{
+		Iterative: true,
+		Root:      testHNSRoot,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := newTestIterator(t, testHNSRoot, testHNSAuthorities())
+	r.upstreams.upstreams[0].transport = it
+
+	// tld has no DS so the answer is insecure
+	r.TrustAnchorPointHandler = func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+		if dns.CountLabel(cut) != 1 {
+			return nil, nil
+		}
+		return dnssec.NewZone(cut, nil)
+	}
+
+	msg, err := r.Query(context.Background(), "www.sub.hns.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.AuthenticatedData || len(msg.Answer) != 1 {
+		t.Fatalf("got ad = %v, answer = %v, want insecure answer", msg.AuthenticatedData, msg.Answer)
+	}
+}
+
+func TestResolver_IterativeUpstreams(t *testing.T) {
+	_, err := NewResolver(&ResolverConfig{
+		Iterative: true,
+		Root:      testHNSRoot,
+		Forward:   "https://hs.dnssec.dev/dns-query",
+	})
+	if !errors.Is(err, ErrIterativeUpstreams) {
+		t.Fatalf("got err = %v, want %v", err, ErrIterativeUpstreams)
+	}
+
+	_, err = NewResolver(&ResolverConfig{
+		Iterative: true,
+		Root:      testHNSRoot,
+		Upstreams: []string{"udp://127.0.0.1"},
+	})
+	if !errors.Is(err, ErrIterativeUpstreams) {
+		t.Fatalf("got err = %v, want %v", err, ErrIterativeUpstreams)
+	}
+
+	r, err := NewResolver(&ResolverConfig{
+		Iterative: true,
+		Root:      testHNSRoot,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the iterator answers
+	status := r.UpstreamStatus()
+	if len(status) != 1 || status[0].Name != "iterative" {
+		t.Fatalf("got upstreams %+v, want the iterator only", status)
+	}
+}
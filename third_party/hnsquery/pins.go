@@ -0,0 +1,316 @@
+package hnsquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PinChange how the TLSA set of a service changed since it was last seen
+type PinChange int
+
+const (
+	// PinUnknown the history isn't kept
+	PinUnknown PinChange = iota
+	// PinNew the service wasn't seen before
+	PinNew
+	// PinUnchanged same records as last time
+	PinUnchanged
+	// PinRotated some records were kept like a normal key rollover
+	PinRotated
+	// PinReplaced every record was replaced at once which may
+	// mean the previous key was compromised
+	PinReplaced
+)
+
+func (c PinChange) String() string {
+	switch c {
+	case PinUnknown:
+		return "unknown"
+	case PinNew:
+		return "new"
+	case PinUnchanged:
+		return "unchanged"
+	case PinRotated:
+		return "rotated"
+	case PinReplaced:
+		return "replaced"
+	}
+
+	return fmt.Sprintf("PinChange(%d)", int(c))
+}
+
+// PinEvent a TLSA set of a service was replaced
+type PinEvent struct {
+	Name     string    `json:"name"`
+	Previous []string  `json:"previous"`
+	Current  []string  `json:"current"`
+	Time     time.Time `json:"time"`
+}
+
+const (
+	// maxPins services kept in the history
+	maxPins = 5000
+	// maxPinEvents recent events kept in memory
+	maxPinEvents = 100
+	// pinSaveDelay changes made within it are written at once
+	pinSaveDelay = time.Second
+)
+
+type pinRecord struct {
+	Records   []string  `json:"records"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// PinHistory remembers the TLSA sets seen for each _port._proto.host
+// name on disk. It only detects changes, DANE itself doesn't depend on
+// it. It's safe for concurrent use.
+type PinHistory struct {
+	path string
+
+	// OnReplaced if set is called when a TLSA set is replaced
+	OnReplaced func(PinEvent)
+
+	mu     sync.Mutex
+	pins   map[string]*pinRecord
+	events []PinEvent
+
+	// changes not written yet
+	dirty bool
+	timer *time.Timer
+
+	// serializes writes of the file
+	saveMu sync.Mutex
+}
+
+// OpenPinHistory loads the history stored at path. The file is
+// created on the first write if it doesn't exist. A history that
+// can't be read is logged and replaced on the next write since
+// verification doesn't depend on it.
+func OpenPinHistory(path string) *PinHistory {
+	h := &PinHistory{
+		path: path,
+		pins: make(map[string]*pinRecord),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &h.pins)
+	}
+	if err != nil {
+		log.Printf("pin history: ignoring %s: %v", path, err)
+		h.pins = make(map[string]*pinRecord)
+	}
+
+	return h
+}
+
+// Observe records the TLSA set seen for name and returns how it
+// changed since it was last seen. Changes are written in the
+// background shortly after.
+func (h *PinHistory) Observe(name string, rrs []*dns.TLSA) PinChange {
+	if h == nil || len(rrs) == 0 {
+		return PinUnknown
+	}
+
+	change, event := h.observe(dns.CanonicalName(name), pinRecords(rrs))
+	if event != nil && h.OnReplaced != nil {
+		h.OnReplaced(*event)
+	}
+
+	return change
+}
+
+func (h *PinHistory) observe(name string, records []string) (PinChange, *PinEvent) {
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var event *PinEvent
+	change := PinNew
+	pin, ok := h.pins[name]
+	if ok {
+		change = comparePins(pin.Records, records)
+	}
+
+	switch change {
+	case PinUnchanged:
+		// avoid a write for every connection
+		if now.Sub(pin.LastSeen) < time.Hour {
+			return change, nil
+		}
+		pin.LastSeen = now
+	case PinReplaced:
+		event = &PinEvent{Name: name, Previous: pin.Records, Current: records, Time: now}
+		h.events = append(h.events, *event)
+		if len(h.events) > maxPinEvents {
+			h.events = h.events[1:]
+		}
+		fallthrough
+	default:
+		firstSeen := now
+		if ok {
+			firstSeen = pin.FirstSeen
+		}
+		h.pins[name] = &pinRecord{Records: records, FirstSeen: firstSeen, LastSeen: now}
+	}
+
+	h.evict()
+	h.scheduleSave()
+	return change, event
+}
+
+// Events the recent replaced TLSA sets oldest first
+func (h *PinHistory) Events() []PinEvent {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]PinEvent{}, h.events...)
+}
+
+// evict drops the services seen least recently once the history is full
+func (h *PinHistory) evict() {
+	if len(h.pins) <= maxPins {
+		return
+	}
+
+	names := make([]string, 0, len(h.pins))
+	for name := range h.pins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return h.pins[names[i]].LastSeen.Before(h.pins[names[j]].LastSeen)
+	})
+
+	for _, name := range names[:len(names)-maxPins] {
+		delete(h.pins, name)
+	}
+}
+
+// scheduleSave writes the history after pinSaveDelay
+// h.mu must be held
+func (h *PinHistory) scheduleSave() {
+	h.dirty = true
+	if h.timer != nil {
+		return
+	}
+
+	h.timer = time.AfterFunc(pinSaveDelay, func() {
+		if err := h.Flush(); err != nil {
+			log.Printf("pin history: %v", err)
+		}
+	})
+}
+
+// Flush writes changes that weren't written yet
+func (h *PinHistory) Flush() error {
+	if h == nil {
+		return nil
+	}
+
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+
+	h.mu.Lock()
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	if !h.dirty {
+		h.mu.Unlock()
+		return nil
+	}
+	h.dirty = false
+	data, err := json.Marshal(h.pins)
+	h.mu.Unlock()
+
+	if err == nil {
+		err = h.save(data)
+	}
+	if err != nil {
+		// retried with the next change
+		h.mu.Lock()
+		h.dirty = true
+		h.mu.Unlock()
+	}
+
+	return err
+}
+
+// save writes the history to a temporary file first
+// so that a crash never leaves a partial file behind
+func (h *PinHistory) save(data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(h.path), filepath.Base(h.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed writing pin history: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed writing pin history: %v", err)
+	}
+
+	if err = os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("failed writing pin history: %v", err)
+	}
+
+	return nil
+}
+
+// pinRecords the sorted presentation of a TLSA set
+func pinRecords(rrs []*dns.TLSA) []string {
+	var records []string
+	for _, rr := range rrs {
+		records = append(records, fmt.Sprintf("%d %d %d %s", rr.Usage, rr.Selector, rr.MatchingType, strings.ToLower(rr.Certificate)))
+	}
+	sort.Strings(records)
+
+	return records
+}
+
+func comparePins(previous, current []string) PinChange {
+	seen := make(map[string]bool, len(previous))
+	for _, record := range previous {
+		seen[record] = true
+	}
+
+	kept := 0
+	for _, record := range current {
+		if seen[record] {
+			kept++
+		}
+	}
+
+	switch {
+	case kept == 0:
+		return PinReplaced
+	case kept == len(previous) && kept == len(current):
+		return PinUnchanged
+	}
+
+	return PinRotated
+}
@@ -0,0 +1,81 @@
+package hnsquery
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"github.com/miekg/dns"
+	"time"
+)
+
+// ErrCertPolicy the certificate matched a TLSA record but violates the policy
+var ErrCertPolicy = fmt.Errorf("certificate policy violation: %w", ErrCertVerifyFailed)
+
+var (
+	ErrCertNameOutsideTLD  = fmt.Errorf("certificate names outside the tld: %w", ErrCertPolicy)
+	ErrCertValidityTooLong = fmt.Errorf("certificate validity too long: %w", ErrCertPolicy)
+	ErrCertWeakKey         = fmt.Errorf("certificate key too weak: %w", ErrCertPolicy)
+)
+
+// CertPolicy rules for certificates that matched a TLSA record.
+// Zero values disable a rule.
+type CertPolicy struct {
+	// AppliesTo reports whether host is subject to the policy.
+	// If nil it applies to every host.
+	AppliesTo func(host string) bool
+
+	// RestrictNames rejects DANE-EE certificates with DNS names
+	// outside the TLD of the host since the TLD owner only
+	// vouches for names in its own zone
+	RestrictNames bool
+
+	// MaxValidity the longest validity period accepted for the leaf
+	MaxValidity time.Duration
+
+	// MinRSAKeySize minimum modulus size of RSA leaf keys
+	// dnssec.DefaultMinRSAKeySize matches the DNSSEC requirement
+	MinRSAKeySize int
+
+	// MinECDSAKeySize minimum curve size of ECDSA leaf keys
+	MinECDSAKeySize int
+}
+
+// Check evaluates the rules against leaf. daneEE is set if the
+// leaf was authenticated by a DANE-EE record.
+func (p *CertPolicy) Check(leaf *x509.Certificate, host string, daneEE bool) error {
+	if p == nil || (p.AppliesTo != nil && !p.AppliesTo(host)) {
+		return nil
+	}
+
+	if p.RestrictNames && daneEE {
+		labels := dns.SplitDomainName(host)
+		if len(labels) > 0 {
+			tld := dns.Fqdn(labels[len(labels)-1])
+			for _, name := range leaf.DNSNames {
+				if !dns.IsSubDomain(tld, dns.Fqdn(name)) {
+					return fmt.Errorf("%s isn't in %s: %w", name, tld, ErrCertNameOutsideTLD)
+				}
+			}
+		}
+	}
+
+	if p.MaxValidity > 0 {
+		if validity := leaf.NotAfter.Sub(leaf.NotBefore); validity > p.MaxValidity {
+			return fmt.Errorf("valid for %v, max %v: %w", validity, p.MaxValidity, ErrCertValidityTooLong)
+		}
+	}
+
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if size := key.N.BitLen(); size < p.MinRSAKeySize {
+			return fmt.Errorf("rsa key size %d, min %d: %w", size, p.MinRSAKeySize, ErrCertWeakKey)
+		}
+	case *ecdsa.PublicKey:
+		if size := key.Curve.Params().BitSize; size < p.MinECDSAKeySize {
+			return fmt.Errorf("ecdsa key size %d, min %d: %w", size, p.MinECDSAKeySize, ErrCertWeakKey)
+		}
+	}
+
+	return nil
+}
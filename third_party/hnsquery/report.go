@@ -0,0 +1,176 @@
+package hnsquery
+
+import (
+	"crypto/x509"
+	"github.com/miekg/dns"
+	"strings"
+	"time"
+)
+
+// VerifyReport how the TLSA records of a host compared
+// to the certificate it presented
+type VerifyReport struct {
+	Host     string             `json:"host"`
+	Port     string             `json:"port"`
+	Protocol string             `json:"protocol"`
+	Records  []TLSARecordResult `json:"records,omitempty"`
+	Secure   bool               `json:"secure"`
+
+	// PinChange how the TLSA set changed since it was last seen
+	// if the verifier keeps a pin history
+	PinChange PinChange `json:"pinChange"`
+
+	// AnchorFetched when the trust anchor of the host's TLD was
+	// fetched and AnchorStale if it's served while a newer one
+	// is fetched
+	AnchorFetched time.Time `json:"anchorFetched"`
+	AnchorStale   bool      `json:"anchorStale"`
+}
+
+// TLSARecordResult the outcome of comparing a single TLSA record
+type TLSARecordResult struct {
+	Usage        uint8 `json:"usage"`
+	Selector     uint8 `json:"selector"`
+	MatchingType uint8 `json:"matchingType"`
+
+	// Expected the certificate association data of the record
+	Expected string `json:"expected"`
+
+	// Computed the data computed for each certificate the
+	// record was compared to
+	Computed []TLSADigest `json:"computed,omitempty"`
+
+	Matched bool `json:"matched"`
+
+	// Skipped the record isn't usable and was ignored
+	Skipped bool `json:"skipped"`
+
+	// Reason why the record was skipped or didn't match
+	Reason string `json:"reason,omitempty"`
+}
+
+// TLSADigest association data computed for a certificate
+type TLSADigest struct {
+	Subject string `json:"subject"`
+	Digest  string `json:"digest"`
+}
+
+// tlsaCheck compares TLSA records to a certificate
+// parsing the chains only when a record needs them
+type tlsaCheck struct {
+	info *CertVerifyInfo
+	host string
+	leaf *x509.Certificate
+
+	chain     []*x509.Certificate
+	pkixChain []*x509.Certificate
+}
+
+func (c *tlsaCheck) presentedChain() (chain []*x509.Certificate, err error) {
+	if c.chain == nil {
+		c.chain, err = parseChain(c.leaf, c.info.RawCerts[1:])
+	}
+
+	return c.chain, err
+}
+
+func (c *tlsaCheck) webPKIChain() (chain []*x509.Certificate, err error) {
+	if c.pkixChain == nil {
+		c.pkixChain, err = webPKIChain(c.leaf, c.info)
+	}
+
+	return c.pkixChain, err
+}
+
+// check compares rr to the certificates its usage applies to. Errors
+// are only returned for certificates that can't be parsed.
+func (c *tlsaCheck) check(rr *dns.TLSA) (TLSARecordResult, error) {
+	result := TLSARecordResult{
+		Usage:        rr.Usage,
+		Selector:     rr.Selector,
+		MatchingType: rr.MatchingType,
+		Expected:     strings.ToLower(rr.Certificate),
+	}
+
+	// RFC 6698 4.1 records with unknown
+	// parameters are unusable
+	if rr.Selector > 1 || rr.MatchingType > 2 {
+		result.Skipped = true
+		result.Reason = "unsupported selector or matching type"
+		return result, nil
+	}
+
+	var chain, candidates []*x509.Certificate
+	var err error
+
+	switch rr.Usage {
+	case 0, 1:
+		// PKIX usages can't make an unverified
+		// chain valid only constrain it
+		if !c.info.WebPKIVerified {
+			result.Skipped = true
+			result.Reason = "pkix usage needs a webpki verified chain"
+			return result, nil
+		}
+
+		if chain, err = c.webPKIChain(); err != nil {
+			return result, err
+		}
+
+		// RFC 6698 2.1.1 PKIX-EE must match the leaf and
+		// PKIX-TA any CA certificate in the verified chain
+		if rr.Usage == 1 {
+			candidates = chain[:1]
+			break
+		}
+		for _, cert := range chain[1:] {
+			if cert.IsCA {
+				candidates = append(candidates, cert)
+			}
+		}
+	case 2:
+		if chain, err = c.presentedChain(); err != nil {
+			return result, err
+		}
+		candidates = chain[1:]
+	case 3:
+		candidates = []*x509.Certificate{c.leaf}
+	default:
+		result.Skipped = true
+		result.Reason = "unsupported usage"
+		return result, nil
+	}
+
+	for _, cert := range candidates {
+		digest, err := dns.CertificateToDANE(rr.Selector, rr.MatchingType, cert)
+		if err != nil {
+			return result, err
+		}
+
+		result.Computed = append(result.Computed, TLSADigest{Subject: cert.Subject.String(), Digest: digest})
+		if result.Matched || digest != result.Expected {
+			continue
+		}
+
+		// the trust anchor must also issue the leaf
+		if rr.Usage == 2 {
+			if err := verifyTA(cert, chain, c.host, !c.info.DisableNameCheck); err != nil {
+				result.Reason = err.Error()
+				continue
+			}
+		}
+
+		result.Matched = true
+		result.Reason = ""
+	}
+
+	if !result.Matched && result.Reason == "" {
+		if len(candidates) == 0 {
+			result.Reason = "no certificate in chain for this usage"
+		} else {
+			result.Reason = "no certificate matched"
+		}
+	}
+
+	return result, nil
+}
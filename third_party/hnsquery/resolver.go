@@ -2,15 +2,15 @@ package hnsquery
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/hashicorp/golang-lru"
 	"github.com/imperviousinc/hnsquery/dnssec"
 	"github.com/miekg/dns"
 	"log"
-	"net/http"
-	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,25 +21,32 @@ type TrustAnchorPointFunc func(ctx context.Context, cut string) (*dnssec.Zone, e
 
 var ErrDNSFatal = errors.New("unrecoverable lookup error")
 var ErrDNSSECFailed = errors.New("dnssec verify failed")
+var ErrMalformedResponse = errors.New("malformed response")
+var ErrAliasLoop = errors.New("cname or dname chain loops")
 
 type Resolver struct {
-	http             http.Client
-	url              *url.URL
-	dns              dns.Client
-	forward          string
-	CheckingDisabled bool
-	TrustAnchorPointHandler  TrustAnchorPointFunc
-	zoneCuts         *lru.Cache
+	upstreams               *upstreamPool
+	CheckingDisabled        bool
+	TrustAnchorPointHandler TrustAnchorPointFunc
+
+	// the current version of the trust anchors, the TLDs
+	// loaded again since it was set and a counter of tag
+	// changes and refreshes
+	anchorMu     sync.RWMutex
+	anchorTag    string
+	anchorLoaded map[string]bool
+	anchorGen    uint64
+
+	zoneCuts *lru.Cache
+	cache    *messageCache
+
+	// concurrent validations of the same zone cut
+	flights flightGroup
 
 	// for testing
 	exchangeTest func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
 }
 
-type recordCache struct {
-	rrs    []*dns.TLSA
-	expire time.Time
-}
-
 func (r *Resolver) queryInternal(ctx context.Context, qname string, qtype uint16) (re *dns.Msg, err error) {
 	msg := new(dns.Msg)
 	msg.SetQuestion(qname, qtype)
@@ -100,25 +107,58 @@ func (r *Resolver) ZoneInsecure(ctx context.Context, qname string) (bool, error)
 
 func (r *Resolver) Query(ctx context.Context, qname string, qtype uint16) (msg *dns.Msg, err error) {
 	qname = dns.CanonicalName(qname)
+	trace := dnssec.TraceFromContext(ctx)
+	key := newCacheKey(qname, qtype, r.CheckingDisabled)
+
+	// a new anchor drops the responses it no longer proves
+	if r.anchorStale(qname) {
+		if _, err = r.getTrustAnchor(ctx, tldOf(qname)); err != nil {
+			return nil, err
+		}
+	}
+
+	if msg, ok := r.cache.get(key); ok {
+		trace.Add(dnssec.TraceStep{
+			Kind:   dnssec.TraceCache,
+			Name:   qname,
+			Secure: msg.AuthenticatedData,
+			Reason: "answered from the response cache",
+		})
+		return msg, nil
+	}
+	if msg := r.synthesizeNegative(qname, qtype); msg != nil {
+		trace.Add(dnssec.TraceStep{
+			Kind:    dnssec.TraceCache,
+			Name:    qname,
+			Records: dnssec.TraceRecords(msg.Ns),
+			Secure:  true,
+			Reason:  "synthesized from cached NSEC records",
+		})
+		return msg, nil
+	}
+
 	if msg, err = r.queryInternal(ctx, qname, qtype); err != nil {
 		return
 	}
 	if len(msg.Question) != 1 {
-		return nil, fmt.Errorf("bad question section")
+		return nil, fmt.Errorf("bad question section: %w", ErrMalformedResponse)
 	}
 	if !strings.EqualFold(msg.Question[0].Name, qname) || msg.Question[0].Qtype != qtype {
-		return nil, fmt.Errorf("question mismatch")
+		return nil, fmt.Errorf("question mismatch: %w", ErrMalformedResponse)
 	}
 
+	// a response isn't cached past its signatures
+	sigExpire := earliestSigExpiration(msg, time.Now())
+
 	answerSection := msg.Answer
 	if err := r.verifyMessage(ctx, msg); err != nil {
-		return nil, fmt.Errorf("%v: %w", err, ErrDNSSECFailed)
+		return nil, validationError(qname, err)
 	}
 
-	// response may have a CNAME chain that was omitted
-	// during validation
-	chase := msg.AuthenticatedData && len(answerSection) > len(msg.Answer)
-	if !chase {
+	// response may have a CNAME or DNAME chain that
+	// was omitted during validation
+	if !msg.AuthenticatedData {
+		r.cache.add(key, msg, sigExpire)
 		return msg, nil
 	}
 
@@ -133,9 +173,12 @@ func (r *Resolver) Query(ctx context.Context, qname string, qtype uint16) (msg *
 	}
 
 	if target == "" {
+		r.cache.add(key, msg, sigExpire)
 		return msg, nil
 	}
 
+	// names already in the chain
+	seen := map[string]struct{}{qname: {}}
 	attempts := 0
 
 chaseCNAME:
@@ -149,13 +192,19 @@ chaseCNAME:
 			break
 		}
 
+		target = dns.CanonicalName(target)
+		if _, ok := seen[target]; ok {
+			return nil, fmt.Errorf("alias chain loop at %s: %w", target, ErrAliasLoop)
+		}
+		seen[target] = struct{}{}
+
 		targetAnswer := extractSecureRRSet(target, qtype, answerSection)
 		if len(targetAnswer) > 0 {
 			targetMsg := new(dns.Msg)
 			targetMsg.SetQuestion(target, qtype)
 			targetMsg.Answer = targetAnswer
 			if err := r.verifyMessage(ctx, targetMsg); err != nil {
-				return nil, fmt.Errorf("faild verifying cname target: %s: %w", target, ErrDNSSECFailed)
+				return nil, validationError(target, err)
 			}
 
 			msg.Answer = append(msg.Answer, targetMsg.Answer...)
@@ -164,13 +213,36 @@ chaseCNAME:
 			break
 		}
 
+		// names below a DNAME can't own other records
+		// the CNAME is synthesized during validation
+		if owner := findDNAMEOwner(target, answerSection); owner != "" {
+			targetMsg := new(dns.Msg)
+			targetMsg.SetQuestion(target, qtype)
+			targetMsg.Answer = extractSecureRRSet(owner, dns.TypeDNAME, answerSection)
+			if err := r.verifyMessage(ctx, targetMsg); err != nil {
+				return nil, validationError(owner, err)
+			}
+
+			target = ""
+			msg.Answer = append(msg.Answer, targetMsg.Answer...)
+			msg.AuthenticatedData = msg.AuthenticatedData && targetMsg.AuthenticatedData
+			for _, rr := range targetMsg.Answer {
+				if rr.Header().Rrtype == dns.TypeCNAME {
+					target = rr.(*dns.CNAME).Target
+					continue chaseCNAME
+				}
+			}
+
+			break
+		}
+
 		targetCNAME := extractSecureRRSet(target, dns.TypeCNAME, answerSection)
 		if len(targetCNAME) > 0 {
 			targetMsg := new(dns.Msg)
 			targetMsg.SetQuestion(target, qtype)
 			targetMsg.Answer = targetCNAME
 			if err := r.verifyMessage(ctx, targetMsg); err != nil {
-				return nil, fmt.Errorf("faild verifying cname target: %s: %w", target, ErrDNSSECFailed)
+				return nil, validationError(target, err)
 			}
 
 			target = ""
@@ -188,9 +260,100 @@ chaseCNAME:
 		break
 	}
 
+	r.cache.add(key, msg, sigExpire)
 	return msg, nil
 }
 
+// QueryWithTrace is like Query and also returns the steps
+// taken to validate the answer even if it fails
+func (r *Resolver) QueryWithTrace(ctx context.Context, qname string, qtype uint16) (*dns.Msg, *dnssec.Trace, error) {
+	trace := &dnssec.Trace{}
+	msg, err := r.Query(dnssec.WithTrace(ctx, trace), qname, qtype)
+	return msg, trace, err
+}
+
+// traceZone records the trust anchors of zone
+// or why it's insecure
+func traceZone(ctx context.Context, zone *dnssec.Zone, source string) {
+	trace := dnssec.TraceFromContext(ctx)
+	if trace == nil {
+		return
+	}
+
+	if len(zone.TrustAnchors) == 0 && !zone.Secure() {
+		trace.Add(dnssec.TraceStep{
+			Kind:   dnssec.TraceInsecure,
+			Zone:   zone.Name,
+			Reason: source + " has no DS records",
+		})
+		return
+	}
+
+	var rrs []dns.RR
+	for _, ds := range zone.TrustAnchors {
+		rrs = append(rrs, ds)
+	}
+	trace.Add(dnssec.TraceStep{
+		Kind:    dnssec.TraceDS,
+		Zone:    zone.Name,
+		Records: dnssec.TraceRecords(rrs),
+		Secure:  true,
+		Reason:  source,
+	})
+}
+
+// synthesizeNegative answers NXDOMAIN or NODATA from validated NSEC
+// records of the closest cached zone without a lookup RFC 8198
+func (r *Resolver) synthesizeNegative(qname string, qtype uint16) *dns.Msg {
+	if r.CheckingDisabled || r.zoneCuts == nil {
+		return nil
+	}
+
+	sname := qname
+	for {
+		// the DS set of a zone is proven by its parent
+		v, ok := r.zoneCuts.Peek(sname)
+		if ok && !(qtype == dns.TypeDS && sname == qname) {
+			zone := v.(*dnssec.Zone)
+			if !time.Now().Before(zone.Expire) || r.anchorStale(sname) {
+				return nil
+			}
+
+			msg := zone.SynthesizeNegative(qname, qtype)
+			if msg == nil {
+				return nil
+			}
+
+			log.Printf("synthesized negative answer for %s from zone %s", qname, zone.Name)
+			msg.AuthenticatedData = true
+			return msg
+		}
+
+		off, end := dns.NextLabel(sname, 0)
+		if end {
+			return nil
+		}
+		sname = sname[off:]
+	}
+}
+
+// findDNAMEOwner finds the owner of a DNAME in section that
+// applies to name
+func findDNAMEOwner(name string, section []dns.RR) string {
+	owner := ""
+	for _, rr := range section {
+		if rr.Header().Rrtype != dns.TypeDNAME || !dnssec.IsSubDomainStrict(rr.Header().Name, name) {
+			continue
+		}
+
+		if dns.CountLabel(rr.Header().Name) > dns.CountLabel(owner) {
+			owner = rr.Header().Name
+		}
+	}
+
+	return owner
+}
+
 func extractSecureRRSet(sname string, stype uint16, section []dns.RR) []dns.RR {
 	var rrs []dns.RR
 	for _, rr := range section {
@@ -214,7 +377,9 @@ func findCutFromMsg(qname string, msg *dns.Msg) string {
 		if rr.Header().Rrtype == dns.TypeRRSIG {
 			sig := rr.(*dns.RRSIG)
 			// if signer name is in bailiwick use it
-			if strings.EqualFold(sig.Header().Name, qname) && dns.IsSubDomain(sig.SignerName, qname) {
+			owner := sig.Header().Name
+			if (strings.EqualFold(owner, qname) || sig.TypeCovered == dns.TypeDNAME && dns.IsSubDomain(owner, qname)) &&
+				dns.IsSubDomain(sig.SignerName, owner) {
 				return sig.SignerName
 			}
 		}
@@ -233,7 +398,20 @@ func findCutFromMsg(qname string, msg *dns.Msg) string {
 	return ""
 }
 
-func (r *Resolver) queryCut(ctx context.Context, qname string) (cut string, err error) {
+// queryCut concurrent callers asking for the
+// same name share a single lookup
+func (r *Resolver) queryCut(ctx context.Context, qname string) (string, error) {
+	cut, err := r.flights.do(ctx, "cut/"+strings.ToLower(qname), func() (interface{}, error) {
+		return r.lookupCut(ctx, qname)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return cut.(string), nil
+}
+
+func (r *Resolver) lookupCut(ctx context.Context, qname string) (string, error) {
 	sname := qname
 	for {
 		msg := new(dns.Msg)
@@ -241,7 +419,7 @@ func (r *Resolver) queryCut(ctx context.Context, qname string) (cut string, err
 
 		soa, soaErr := r.ExchangeContext(ctx, msg)
 		if soaErr != nil {
-			return "", fmt.Errorf("failed finding zone cut: %v", soaErr)
+			return "", &FetchError{Zone: sname, Type: dns.TypeSOA, Err: soaErr}
 		}
 
 		for _, rr := range soa.Answer {
@@ -286,14 +464,22 @@ func (r *Resolver) loadKeys(ctx context.Context, zone *dnssec.Zone) error {
 
 	re, err := r.ExchangeContext(ctx, msg)
 	if err != nil {
-		return err
+		return &FetchError{Zone: zone.Name, Type: dns.TypeDNSKEY, Err: err}
 	}
 
+	trace := dnssec.TraceFromContext(ctx)
 	keys, err := zone.VerifyDNSKeys(re)
 	if err != nil {
+		trace.Add(dnssec.TraceStep{Kind: dnssec.TraceDNSKEY, Zone: zone.Name, Reason: err.Error()})
 		return err
 	}
 
+	var rrs []dns.RR
+	for _, key := range keys {
+		rrs = append(rrs, key)
+	}
+	trace.Add(dnssec.TraceStep{Kind: dnssec.TraceDNSKEY, Zone: zone.Name, Records: dnssec.TraceRecords(rrs), Secure: true})
+
 	zone.Keys = keys
 	return nil
 }
@@ -311,35 +497,246 @@ func (r *Resolver) zoneFromDS(ctx context.Context, name string, dsSet []dns.RR)
 	return zone, nil
 }
 
-func (r *Resolver) getTrustAnchor(ctx context.Context, cut string) (zone *dnssec.Zone, err error) {
+// cachedZone the cached zone of cut unless it expired
+func (r *Resolver) cachedZone(ctx context.Context, cut string) (*dnssec.Zone, bool) {
 	if zone, ok := r.zoneCuts.Get(cut); ok {
 		zone := zone.(*dnssec.Zone)
 
 		if time.Now().Before(zone.Expire) {
+			// kept to reuse its keys if the anchor didn't change
+			if r.anchorStale(cut) {
+				log.Printf("resolver cache stale for cut: %s", cut)
+				return nil, false
+			}
+
 			log.Printf("resolver cache hit for cut: %s", cut)
-			return zone, nil
+			traceZone(ctx, zone, "zone from cache")
+			return zone, true
 		}
 
 		r.zoneCuts.Remove(cut)
 	}
 
+	return nil, false
+}
+
+// AnchorTag the current version of the trust anchors
+func (r *Resolver) AnchorTag() string {
+	r.anchorMu.RLock()
+	defer r.anchorMu.RUnlock()
+
+	return r.anchorTag
+}
+
+// SetAnchorTag sets the current version of the trust anchors.
+// Zones below an anchor tagged with another version are loaded
+// again from TrustAnchorPointHandler once before use.
+func (r *Resolver) SetAnchorTag(tag string) {
+	r.anchorMu.Lock()
+	defer r.anchorMu.Unlock()
+
+	if tag != r.anchorTag {
+		r.anchorTag = tag
+		r.anchorLoaded = nil
+		r.anchorGen++
+	}
+}
+
+// RefreshAnchor loads the anchor of tld again before the next query
+// if it's tagged with an older version. TrustAnchorPointHandler may
+// serve an old anchor while it fetches a new one and call this once
+// it's done.
+func (r *Resolver) RefreshAnchor(tld string) {
+	r.anchorMu.Lock()
+	defer r.anchorMu.Unlock()
+
+	delete(r.anchorLoaded, dns.CanonicalName(tld))
+	r.anchorGen++
+}
+
+// anchorGeneration the state a trust anchor load starts at
+func (r *Resolver) anchorGeneration() uint64 {
+	r.anchorMu.RLock()
+	defer r.anchorMu.RUnlock()
+
+	return r.anchorGen
+}
+
+// anchorReloaded records that the zone of tld was loaded again and
+// stored at the current tag. Until the tag changes or RefreshAnchor
+// is called the anchor is used even if it's still tagged with an
+// older version. Nothing is recorded if either happened since the
+// load started at gen so that the refresh isn't lost.
+func (r *Resolver) anchorReloaded(tld string, gen uint64) {
+	r.anchorMu.Lock()
+	defer r.anchorMu.Unlock()
+
+	if r.anchorTag == "" || gen != r.anchorGen {
+		return
+	}
+	if r.anchorLoaded == nil {
+		r.anchorLoaded = make(map[string]bool)
+	}
+	r.anchorLoaded[dns.CanonicalName(tld)] = true
+}
+
+// anchorStale the TLD above cut was loaded with a trust
+// anchor older than AnchorTag and wasn't loaded again
+func (r *Resolver) anchorStale(cut string) bool {
+	if r.zoneCuts == nil {
+		return false
+	}
+
+	tld := tldOf(cut)
+	if tld == "" {
+		return false
+	}
+
+	v, ok := r.zoneCuts.Peek(tld)
+	if !ok {
+		return false
+	}
+
+	r.anchorMu.RLock()
+	defer r.anchorMu.RUnlock()
+
+	zone := v.(*dnssec.Zone)
+	return r.anchorTag != "" && zone.Tag != "" && zone.Tag != r.anchorTag && !r.anchorLoaded[dns.CanonicalName(tld)]
+}
+
+// CachedTrustAnchor the cached zone of the TLD of name or nil
+func (r *Resolver) CachedTrustAnchor(name string) *dnssec.Zone {
+	if r == nil || r.zoneCuts == nil {
+		return nil
+	}
+
+	tld := tldOf(dns.CanonicalName(name))
+	if tld == "" {
+		return nil
+	}
+
+	v, ok := r.zoneCuts.Peek(tld)
+	if !ok {
+		return nil
+	}
+
+	return v.(*dnssec.Zone)
+}
+
+// tldOf the last label of name or empty for the root
+func tldOf(name string) string {
+	offs := dns.Split(name)
+	if len(offs) == 0 {
+		return ""
+	}
+
+	return name[offs[len(offs)-1]:]
+}
+
+// reuseKeys takes the keys of the previous zone of the cut if it was
+// loaded with the same DS set. Otherwise anything cached below the cut
+// was validated with keys that are no longer trusted and is removed.
+func (r *Resolver) reuseKeys(cut string, zone *dnssec.Zone) {
+	v, ok := r.zoneCuts.Peek(cut)
+	if !ok {
+		return
+	}
+
+	prev := v.(*dnssec.Zone)
+	if sameAnchors(prev.TrustAnchors, zone.TrustAnchors) {
+		if len(zone.Keys) == 0 && len(prev.Keys) > 0 && time.Now().Before(prev.Expire) {
+			zone.Keys = prev.Keys
+		}
+		return
+	}
+
+	log.Printf("resolver trust anchor changed for cut: %s", cut)
+	r.removeBelow(cut)
+}
+
+// removeBelow removes the cached zones and responses at or below cut
+func (r *Resolver) removeBelow(cut string) {
+	for _, key := range r.zoneCuts.Keys() {
+		if name := key.(string); dns.IsSubDomain(cut, name) {
+			r.zoneCuts.Remove(name)
+		}
+	}
+	r.cache.removeBelow(cut)
+}
+
+func sameAnchors(a, b []*dns.DS) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := make(map[string]int)
+	for _, ds := range a {
+		seen[dsKey(ds)]++
+	}
+	for _, ds := range b {
+		if seen[dsKey(ds)] == 0 {
+			return false
+		}
+		seen[dsKey(ds)]--
+	}
+
+	return true
+}
+
+func dsKey(ds *dns.DS) string {
+	return fmt.Sprintf("%d %d %d %s", ds.KeyTag, ds.Algorithm, ds.DigestType, strings.ToLower(ds.Digest))
+}
+
+// getTrustAnchor concurrent callers asking for the
+// same cut share a single trust anchor lookup
+func (r *Resolver) getTrustAnchor(ctx context.Context, cut string) (*dnssec.Zone, error) {
+	if zone, ok := r.cachedZone(ctx, cut); ok {
+		return zone, nil
+	}
+
+	zone, err := r.flights.do(ctx, "anchor/"+strings.ToLower(cut), func() (interface{}, error) {
+		// cached by a caller that finished first
+		if zone, ok := r.cachedZone(ctx, cut); ok {
+			return zone, nil
+		}
+
+		return r.loadTrustAnchor(ctx, cut)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return zone.(*dnssec.Zone), nil
+}
+
+func (r *Resolver) loadTrustAnchor(ctx context.Context, cut string) (zone *dnssec.Zone, err error) {
 	if r.TrustAnchorPointHandler == nil {
 		return nil, fmt.Errorf("no trust anchor callback set")
 	}
+	gen := r.anchorGeneration()
 	if zone, err = r.TrustAnchorPointHandler(ctx, cut); err != nil {
+		r.RefreshAnchor(cut)
 		return nil, fmt.Errorf("failed getting trust anchor: %w", err)
 	}
 	if zone == nil {
+		// the name of a tagged anchor no longer exists
+		if v, ok := r.zoneCuts.Peek(cut); ok && v.(*dnssec.Zone).Tag != "" {
+			r.removeBelow(cut)
+		}
 		return nil, nil
 	}
+	traceZone(ctx, zone, "trust anchor")
+	r.reuseKeys(cut, zone)
 	if len(zone.TrustAnchors) > 0 && len(zone.Keys) == 0 {
 		if err = r.loadKeys(ctx, zone); err != nil {
-			return nil, fmt.Errorf("failed getting dnskeys for zone %s", zone.Name)
+			r.RefreshAnchor(cut)
+			return nil, fmt.Errorf("failed getting dnskeys for zone %s: %w", zone.Name, err)
 		}
 	}
 
 	log.Printf("resolver caching cut: %s", cut)
 	r.zoneCuts.Add(cut, zone)
+	r.anchorReloaded(cut, gen)
 	return
 }
 
@@ -358,6 +755,7 @@ loopFindCut:
 			if err != nil {
 				return nil, err
 			}
+			dnssec.TraceFromContext(ctx).Add(dnssec.TraceStep{Kind: dnssec.TraceCut, Zone: cut, Name: sname})
 
 			baseZone, err = r.getTrustAnchor(ctx, cut)
 			if err != nil {
@@ -391,6 +789,7 @@ loopFindCut:
 // verifyChain verifies all zone cuts and returns the last verified zone cut
 func (r *Resolver) verifyChain(ctx context.Context, cuts []string, base *dnssec.Zone) (*dnssec.Zone, error) {
 	insecure := false
+	trace := dnssec.TraceFromContext(ctx)
 
 	for i := len(cuts) - 1; i >= 0; i-- {
 		cut := cuts[i]
@@ -399,6 +798,11 @@ func (r *Resolver) verifyChain(ctx context.Context, cuts []string, base *dnssec.
 		// mark the remaining cuts insecure
 		if insecure {
 			log.Printf("verify chain: cut %s has unsigned parent - marking insecure", cut)
+			trace.Add(dnssec.TraceStep{
+				Kind:   dnssec.TraceInsecure,
+				Zone:   cut,
+				Reason: fmt.Sprintf("parent zone %s is insecure", base.Name),
+			})
 			insecureCut, err := dnssec.NewZone(cut, nil)
 			if err != nil {
 				return nil, err
@@ -409,65 +813,205 @@ func (r *Resolver) verifyChain(ctx context.Context, cuts []string, base *dnssec.
 			continue
 		}
 
-		msg := new(dns.Msg)
-		msg.SetQuestion(cut, dns.TypeDS)
-		msg.SetEdns0(4096, true)
-		msg.AuthenticatedData = true
-
-		re, err := r.ExchangeContext(ctx, msg)
+		zone, err := r.delegation(ctx, cut, base)
 		if err != nil {
 			return nil, err
 		}
 
-		log.Printf("verify chain: verifying cut %s with zone %s", cut, base.Name)
+		// first insecure cut found in the chain
+		insecure = len(zone.TrustAnchors) == 0
+		base = zone
+	}
 
-		secure, err := base.Verify(ctx, re, cut, dns.TypeDS)
-		if err != nil {
-			return nil, err
+	return base, nil
+}
+
+// delegation validates the DS RRSet of cut with its parent zone and
+// returns the zone of the cut. Concurrent callers share one validation.
+func (r *Resolver) delegation(ctx context.Context, cut string, parent *dnssec.Zone) (*dnssec.Zone, error) {
+	zone, err := r.flights.do(ctx, "ds/"+strings.ToLower(cut), func() (interface{}, error) {
+		// cached by a caller that finished first
+		if zone, ok := r.cachedZone(ctx, cut); ok {
+			return zone, nil
 		}
 
-		if secure {
-			if base, err = r.zoneFromDS(ctx, cut, re.Answer); err != nil {
-				return nil, err
-			}
+		return r.loadDelegation(ctx, cut, parent)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			r.zoneCuts.Add(cut, base)
-			continue
-		}
+	return zone.(*dnssec.Zone), nil
+}
 
-		log.Printf("verify chain: cut %s insecure verified by %s", cut, base.Name)
+func (r *Resolver) loadDelegation(ctx context.Context, cut string, parent *dnssec.Zone) (*dnssec.Zone, error) {
+	trace := dnssec.TraceFromContext(ctx)
 
-		// first insecure cut found in the chain
-		insecure = true
-		insecureCut, err := dnssec.NewZone(cut, nil)
-		if err != nil {
+	msg := new(dns.Msg)
+	msg.SetQuestion(cut, dns.TypeDS)
+	msg.SetEdns0(4096, true)
+	msg.AuthenticatedData = true
+
+	re, err := r.ExchangeContext(ctx, msg)
+	if err != nil {
+		return nil, &FetchError{Zone: cut, Type: dns.TypeDS, Err: err}
+	}
+
+	log.Printf("verify chain: verifying cut %s with zone %s", cut, parent.Name)
+
+	secure, err := parent.Verify(ctx, re, cut, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+
+	var zone *dnssec.Zone
+	if secure {
+		trace.Add(dnssec.TraceStep{Kind: dnssec.TraceDS, Zone: cut, Records: dnssec.TraceRecords(re.Answer), Secure: true})
+		if zone, err = r.zoneFromDS(ctx, cut, re.Answer); err != nil {
 			return nil, err
 		}
 
-		r.zoneCuts.Add(cut, insecureCut)
-		base = insecureCut
+		var keys []dns.RR
+		for _, key := range zone.Keys {
+			keys = append(keys, key)
+		}
+		zone.Expire = cutExpire(parent, re.Answer, keys)
+	} else {
+		log.Printf("verify chain: cut %s insecure verified by %s", cut, parent.Name)
+		trace.Add(dnssec.TraceStep{
+			Kind:   dnssec.TraceInsecure,
+			Zone:   cut,
+			Reason: fmt.Sprintf("%s proves there is no DS record for the cut", parent.Name),
+		})
+
+		if zone, err = dnssec.NewZone(cut, nil); err != nil {
+			return nil, err
+		}
+		zone.Expire = cutExpire(parent, re.Ns)
 	}
 
-	return base, nil
+	r.zoneCuts.Add(cut, zone)
+	return zone, nil
+}
+
+// cutExpire a zone proven by its parent can be reused until
+// the records that proved it or the parent itself expire
+func cutExpire(parent *dnssec.Zone, proofs ...[]dns.RR) time.Time {
+	expire := parent.Expire
+	for _, rrs := range proofs {
+		for _, rr := range rrs {
+			ttl := time.Now().Add(time.Duration(rr.Header().Ttl) * time.Second)
+			if ttl.Before(expire) {
+				expire = ttl
+			}
+		}
+	}
+
+	return expire
 }
 
 type ResolverConfig struct {
+	// Forward the upstream resolver url the scheme
+	// selects the transport (udp, tcp, tls, https or quic)
 	Forward string
+
+	// Upstreams an ordered list of upstream resolver urls
+	// used in addition to Forward
+	Upstreams []string
+
+	// Strategy how queries are distributed among upstreams
+	Strategy UpstreamStrategy
+
+	// UpstreamTimeout max time a single upstream has to answer
+	UpstreamTimeout time.Duration
+
+	// MaxFailures consecutive failures before an upstream is
+	// ejected for EjectCooldown
+	MaxFailures   int
+	EjectCooldown time.Duration
+
+	// TLSConfig optional config used by tls:// and https:// upstreams
+	TLSConfig *tls.Config
+
+	// Iterative resolves names by following referrals starting
+	// from the TLD nameservers in Root instead of using upstreams.
+	// Forward and Upstreams must be empty.
+	Iterative bool
+	Root      ZoneQuery
+
+	// MaxReferrals max referrals followed per query in iterative mode
+	MaxReferrals int
+
+	// CacheSize max number of cached responses and CacheMaxBytes
+	// their max total wire size. CacheMaxTTL caps how long
+	// a response is cached. Set DisableCache to turn it off.
+	CacheSize     int
+	CacheMaxBytes int
+	CacheMaxTTL   time.Duration
+	DisableCache  bool
 }
 
 func NewResolver(config *ResolverConfig) (r *Resolver, err error) {
 	r = &Resolver{}
-	r.dns.SingleInflight = true
-	r.dns.Net = "doh"
-	r.http.Timeout = time.Second * 10
+	r.upstreams = newUpstreamPool(config.Strategy)
+	if config.UpstreamTimeout > 0 {
+		r.upstreams.timeout = config.UpstreamTimeout
+	}
+	if config.MaxFailures > 0 {
+		r.upstreams.maxFailures = config.MaxFailures
+	}
+	if config.EjectCooldown > 0 {
+		r.upstreams.cooldown = config.EjectCooldown
+	}
 
-	r.url, err = url.Parse(config.Forward)
+	if config.Iterative {
+		if config.Forward != "" || len(config.Upstreams) > 0 {
+			return nil, ErrIterativeUpstreams
+		}
+		if config.Root == nil {
+			return nil, fmt.Errorf("iterative mode requires a root zone")
+		}
+
+		it, err := newIterator(config.Root, config.MaxReferrals)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.UpstreamTimeout == 0 {
+			r.upstreams.timeout = 15 * time.Second
+		}
+		r.upstreams.add(it.String(), it)
+	}
+
+	var upstreams []string
+	if config.Forward != "" {
+		upstreams = append(upstreams, config.Forward)
+	}
+	upstreams = append(upstreams, config.Upstreams...)
+	if len(upstreams) == 0 && !config.Iterative {
+		return nil, ErrNoUpstreams
+	}
+
+	for _, upstream := range upstreams {
+		t, err := NewTransport(upstream, config.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		r.upstreams.add(upstream, t)
+	}
 
 	r.zoneCuts, err = lru.New(300)
 	if err != nil {
 		return nil, fmt.Errorf("failed cache init: %v", err)
 	}
 
+	if !config.DisableCache {
+		r.cache, err = newMessageCache(config.CacheSize, config.CacheMaxBytes, config.CacheMaxTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed cache init: %v", err)
+		}
+	}
+
 	return
 }
 
@@ -476,26 +1020,16 @@ func (r *Resolver) ExchangeContext(ctx context.Context, msg *dns.Msg) (re *dns.M
 		return r.exchangeTest(ctx, msg)
 	}
 
-	for i := 0; i < 3; i++ {
-		if r.dns.Net == "doh" {
-			re, _, err = r.dns.ExchangeWithConn(msg, &dns.Conn{Conn: &dohConn{
-				endpoint: r.url,
-				http:     &r.http,
-				ctx:      ctx,
-			}})
-		} else {
-			re, _, err = r.dns.ExchangeContext(ctx, msg, r.forward)
-		}
-
-		if err == nil {
-			if re.Truncated {
-				err = errors.New("response truncated")
-				continue
-			}
+	// the pool already fails over to the next upstream
+	return r.upstreams.Exchange(ctx, msg)
+}
 
-			return
-		}
-	}
+// CacheStats returns message cache counters
+func (r *Resolver) CacheStats() CacheStats {
+	return r.cache.stats()
+}
 
-	return
+// UpstreamStatus returns health information for each upstream
+func (r *Resolver) UpstreamStatus() []UpstreamStatus {
+	return r.upstreams.status()
 }
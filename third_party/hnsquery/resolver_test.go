@@ -2,54 +2,760 @@ package hnsquery
 
 import (
 	"context"
+	"crypto"
+	"errors"
 	"fmt"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/imperviousinc/hnsquery/dnssec"
 	"github.com/miekg/dns"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewResolver(t *testing.T) {
 	c, _ := lru.New(100)
 	called := false
 	r := &Resolver{
-		TrustAnchorFunc: func(ctx context.Context, cut string) (*dnssec.Zone, bool, error) {
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
 			if called {
 				t.Fatal("should only be called once")
-				return nil, false, nil
+				return nil, nil
 			}
 
 			called = true
 			if cut == "." {
-				return nil, false, fmt.Errorf("failed")
+				return nil, fmt.Errorf("failed")
 			}
 
 			if cut == "proofofconcept." {
-				z, err := dnssec.NewZone("proofofconcept.", nil)
-				return z, true, err
+				zone, err := dnssec.NewZone("proofofconcept.", nil)
+				if err == nil {
+					zone.Expire = time.Now().Add(time.Hour)
+				}
+				return zone, err
 			}
 
-			return nil, false, nil
+			return nil, nil
 		},
 		zoneCuts: c,
 		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
-			t.Fatal("insecure zone shouldn't call exchange")
-			return nil, nil
+			re := new(dns.Msg)
+			re.SetReply(msg)
+
+			q := msg.Question[0]
+			switch q.Qtype {
+			case dns.TypeSOA:
+				soa, _ := dns.NewRR("proofofconcept. 300 IN SOA ns1.proofofconcept. admin.proofofconcept. 1 300 300 300 300")
+				re.Ns = append(re.Ns, soa)
+			case dns.TypeTLSA:
+				tlsa, _ := dns.NewRR(q.Name + " 300 IN TLSA 3 1 1 " + strings.Repeat("ab", 32))
+				re.Answer = append(re.Answer, tlsa)
+			}
+			return re, nil
 		},
 	}
 
-	_, err := r.Query(context.Background(), "_443._tcp.proofofconcept.", dns.TypeTLSA)
+	// an insecure zone is answered without validation
+	msg, err := r.Query(context.Background(), "_443._tcp.proofofconcept.", dns.TypeTLSA)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if msg.AuthenticatedData || len(msg.Answer) != 1 {
+		t.Fatalf("got AD = %v with %d answers, want an insecure answer", msg.AuthenticatedData, len(msg.Answer))
+	}
 
 	_, err = r.getTrustAnchor(context.Background(), "proofofconcept.")
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	// the trust anchor is cached
 	_, err = r.Query(context.Background(), "_443._tcp.proofofconcept.", dns.TypeTLSA)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
+
+// testZoneSigner signs records for a secure test zone
+type testZoneSigner struct {
+	t          *testing.T
+	zone       string
+	key        *dns.DNSKEY
+	priv       crypto.Signer
+	expiration time.Time
+}
+
+func newTestZoneSigner(t *testing.T) *testZoneSigner {
+	return newTestZone(t, "example.")
+}
+
+// newTestZone a signer for zone
+func newTestZone(t *testing.T, zone string) *testZoneSigner {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testZoneSigner{t: t, zone: zone, key: key, priv: priv.(crypto.Signer), expiration: time.Now().Add(time.Hour)}
+}
+
+func (s *testZoneSigner) sign(records ...string) []dns.RR {
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrs[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 300},
+		Algorithm:  s.key.Algorithm,
+		SignerName: s.zone,
+		KeyTag:     s.key.KeyTag(),
+		Inception:  uint32(s.expiration.Add(-2 * time.Hour).Unix()),
+		Expiration: uint32(s.expiration.Unix()),
+	}
+	if err := sig.Sign(s.priv, rrs); err != nil {
+		s.t.Fatal(err)
+	}
+
+	return append(rrs, sig)
+}
+
+// resolver returns a resolver trusting the test zone that
+// answers queries from answers
+func (s *testZoneSigner) resolver(answers map[string][]dns.RR) *Resolver {
+	zoneCuts, _ := lru.New(10)
+	cache, _ := newMessageCache(10, 0, 0)
+	return &Resolver{
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+			return &dnssec.Zone{
+				Name:   s.zone,
+				Keys:   map[uint16]*dns.DNSKEY{s.key.KeyTag(): s.key},
+				Expire: time.Now().Add(time.Hour),
+				MinRSA: dnssec.DefaultMinRSAKeySize,
+			}, nil
+		},
+		zoneCuts: zoneCuts,
+		cache:    cache,
+		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			re := new(dns.Msg)
+			re.SetReply(msg)
+			re.Answer = answers[msg.Question[0].Name]
+			return re, nil
+		},
+	}
+}
+
+func TestResolver_DNAME(t *testing.T) {
+	s := newTestZoneSigner(t)
+	sign := s.sign
+
+	unsigned := func(record string) dns.RR {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rr
+	}
+
+	answers := map[string][]dns.RR{}
+
+	// server sends a forged synthesized CNAME
+	answers["www.sub.example."] = append(append(
+		sign("sub.example. 300 IN DNAME other.example."),
+		unsigned("www.sub.example. 300 IN CNAME www.evil.example.")),
+		sign("www.other.example. 300 IN A 192.0.2.1")...)
+
+	// CNAME to a name below a DNAME
+	answers["alias.example."] = append(append(append(
+		sign("alias.example. 300 IN CNAME www.sub.example."),
+		sign("sub.example. 300 IN DNAME other.example.")...),
+		unsigned("www.sub.example. 300 IN CNAME www.other.example.")),
+		sign("www.other.example. 300 IN A 192.0.2.1")...)
+
+	// loop across both record kinds
+	answers["c.example."] = append(append(
+		sign("c.example. 300 IN CNAME x.d.example."),
+		sign("d.example. 300 IN DNAME e.example.")...),
+		sign("x.e.example. 300 IN CNAME c.example.")...)
+
+	r := s.resolver(answers)
+
+	for _, qname := range []string{"www.sub.example.", "alias.example."} {
+		msg, err := r.Query(context.Background(), qname, dns.TypeA)
+		if err != nil {
+			t.Fatalf("%s: %v", qname, err)
+		}
+		if !msg.AuthenticatedData {
+			t.Fatalf("%s: want secure answer", qname)
+		}
+
+		var cname *dns.CNAME
+		var a *dns.A
+		for _, rr := range msg.Answer {
+			switch rr := rr.(type) {
+			case *dns.CNAME:
+				if rr.Hdr.Name == "www.sub.example." {
+					cname = rr
+				}
+			case *dns.A:
+				a = rr
+			}
+		}
+
+		if cname == nil || cname.Target != "www.other.example." {
+			t.Fatalf("%s: want cname synthesized from dname, got %v", qname, msg.Answer)
+		}
+		if a == nil || a.Hdr.Name != "www.other.example." {
+			t.Fatalf("%s: want A record of the dname target, got %v", qname, msg.Answer)
+		}
+	}
+
+	if _, err := r.Query(context.Background(), "c.example.", dns.TypeA); !errors.Is(err, ErrAliasLoop) {
+		t.Fatalf("got err %v, want %v", err, ErrAliasLoop)
+	}
+}
+
+func TestResolver_QueryWithTrace(t *testing.T) {
+	s := newTestZoneSigner(t)
+
+	bogus := s.sign("bogus.example. 300 IN A 192.0.2.1")
+	bogus[0].(*dns.A).A[3] = 2
+
+	r := s.resolver(map[string][]dns.RR{
+		"www.example.":   s.sign("www.example. 300 IN A 192.0.2.1"),
+		"bogus.example.": bogus,
+	})
+
+	_, trace, err := r.QueryWithTrace(context.Background(), "www.example.", dns.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := map[dnssec.TraceStepKind]dnssec.TraceStep{}
+	for _, step := range trace.Steps() {
+		kinds[step.Kind] = step
+	}
+
+	if step := kinds[dnssec.TraceCut]; step.Zone != "example." {
+		t.Fatalf("got cut %q, want example.", step.Zone)
+	}
+	if step := kinds[dnssec.TraceRRSIG]; !step.Secure || step.Signature == nil || step.Signature.Covered != "A" {
+		t.Fatalf("got rrsig step %+v", step)
+	}
+	if step := kinds[dnssec.TraceResult]; !step.Secure {
+		t.Fatalf("got result step %+v, want secure", step)
+	}
+
+	// the answer is cached now
+	_, trace, _ = r.QueryWithTrace(context.Background(), "www.example.", dns.TypeA)
+	if steps := trace.Steps(); len(steps) != 1 || steps[0].Kind != dnssec.TraceCache {
+		t.Fatalf("got steps %+v, want cache hit", steps)
+	}
+
+	_, trace, err = r.QueryWithTrace(context.Background(), "bogus.example.", dns.TypeA)
+	if !errors.Is(err, ErrDNSSECFailed) {
+		t.Fatalf("got err %v, want %v", err, ErrDNSSECFailed)
+	}
+
+	steps := trace.Steps()
+	last := steps[len(steps)-1]
+	if last.Kind != dnssec.TraceResult || last.Secure || last.Reason == "" {
+		t.Fatalf("got result step %+v, want bogus with a reason", last)
+	}
+	for _, step := range steps {
+		if step.Kind == dnssec.TraceRRSIG && (step.Secure || step.Reason == "") {
+			t.Fatalf("got rrsig step %+v, want failure reason", step)
+		}
+	}
+}
+
+func TestResolver_ValidationError(t *testing.T) {
+	s := newTestZoneSigner(t)
+	answers := map[string][]dns.RR{
+		"www.example.": s.sign("www.example. 300 IN A 192.0.2.1"),
+	}
+
+	s.expiration = time.Now().Add(-time.Minute)
+	answers["expired.example."] = s.sign("expired.example. 300 IN A 192.0.2.1")
+
+	r := s.resolver(answers)
+	r.exchangeTest = func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		re := new(dns.Msg)
+		re.SetReply(msg)
+		re.Answer = answers[msg.Question[0].Name]
+		if msg.Question[0].Name == "mismatch.example." {
+			re.Question[0].Qtype = dns.TypeAAAA
+		}
+		return re, nil
+	}
+
+	tests := []struct {
+		qname string
+		want  error
+	}{
+		{"expired.example.", dnssec.ErrInvalidSignaturePeriod},
+		{"missing.example.", dnssec.ErrNoSignatures},
+		{"mismatch.example.", ErrMalformedResponse},
+	}
+
+	for _, test := range tests {
+		_, err := r.Query(context.Background(), test.qname, dns.TypeA)
+		if !errors.Is(err, test.want) {
+			t.Fatalf("%s: got err %v, want %v", test.qname, err, test.want)
+		}
+	}
+
+	_, err := r.Query(context.Background(), "expired.example.", dns.TypeA)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Name != "expired.example." || !errors.Is(err, ErrDNSSECFailed) {
+		t.Fatalf("got err %v, want validation error for expired.example.", err)
+	}
+}
+
+func TestResolver_Coalescing(t *testing.T) {
+	ca, caKey := testIssue(t, "Test CA", nil, nil)
+	leaf, _ := testIssue(t, "www.sub.example", ca, caKey)
+
+	tld := newTestZone(t, "example.")
+	sub := newTestZone(t, "sub.example.")
+
+	tlsa := &dns.TLSA{Hdr: dns.RR_Header{Name: "_443._tcp.www.sub.example.", Rrtype: dns.TypeTLSA, Class: dns.ClassINET, Ttl: 300}}
+	if err := tlsa.Sign(3, 1, 1, leaf); err != nil {
+		t.Fatal(err)
+	}
+	ds := sub.key.ToDS(dns.SHA256)
+	ds.Hdr.Ttl = 300
+
+	soa := func(zone string) []dns.RR {
+		rr, err := dns.NewRR(zone + " 300 IN SOA ns." + zone + " hostmaster." + zone + " 1 3600 600 86400 300")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []dns.RR{rr}
+	}
+
+	type question struct {
+		name  string
+		qtype uint16
+	}
+	answers := map[question][]dns.RR{
+		{"example.", dns.TypeSOA}:                    soa("example."),
+		{"example.", dns.TypeDNSKEY}:                 tld.sign(tld.key.String()),
+		{"sub.example.", dns.TypeDS}:                 tld.sign(ds.String()),
+		{"sub.example.", dns.TypeDNSKEY}:             sub.sign(sub.key.String()),
+		{"www.sub.example.", dns.TypeA}:              sub.sign("www.sub.example. 300 IN A 192.0.2.1"),
+		{"_443._tcp.www.sub.example.", dns.TypeTLSA}: sub.sign(tlsa.String()),
+	}
+
+	var mu sync.Mutex
+	exchanges := map[question]int{}
+	anchors := 0
+
+	zoneCuts, _ := lru.New(10)
+	r := &Resolver{
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+			if cut != "example." {
+				return nil, nil
+			}
+
+			mu.Lock()
+			anchors++
+			mu.Unlock()
+
+			zone, err := dnssec.NewZone(cut, []dns.RR{tld.key.ToDS(dns.SHA256)})
+			if err != nil {
+				return nil, err
+			}
+			zone.Expire = time.Now().Add(time.Hour)
+			return zone, nil
+		},
+		zoneCuts: zoneCuts,
+		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			q := question{msg.Question[0].Name, msg.Question[0].Qtype}
+			mu.Lock()
+			exchanges[q]++
+			mu.Unlock()
+
+			// keep exchanges in flight so that callers overlap
+			time.Sleep(50 * time.Millisecond)
+
+			re := new(dns.Msg)
+			re.SetReply(msg)
+			re.Answer = answers[q]
+			return re, nil
+		},
+	}
+	v, _ := NewDNSCertVerifier(r)
+
+	const callers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			msg, err := r.Query(context.Background(), "www.sub.example.", dns.TypeA)
+			if err != nil || !msg.AuthenticatedData {
+				t.Errorf("got err %v, want secure answer", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			secure, err := v.VerifyService(context.Background(), "www.sub.example", "443", "tcp", [][]byte{leaf.Raw})
+			if err != nil || !secure {
+				t.Errorf("got secure = %v, err = %v, want secure", secure, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if anchors != 1 {
+		t.Fatalf("trust anchor loaded %d times, want 1", anchors)
+	}
+	for q, n := range exchanges {
+		// answers aren't coalesced only the validation
+		if q.qtype == dns.TypeA {
+			continue
+		}
+		if n != 1 {
+			t.Fatalf("%s %s exchanged %d times, want 1", q.name, dns.TypeToString[q.qtype], n)
+		}
+	}
+}
+
+func TestResolver_AnchorTag(t *testing.T) {
+	tld := newTestZone(t, "example.")
+	sub := newTestZone(t, "sub.example.")
+	ds := sub.key.ToDS(dns.SHA256)
+	ds.Hdr.Ttl = 300
+
+	type question struct {
+		name  string
+		qtype uint16
+	}
+	soa, err := dns.NewRR("example. 300 IN SOA ns.example. hostmaster.example. 1 3600 600 86400 300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers := map[question][]dns.RR{
+		{"example.", dns.TypeSOA}:        {soa},
+		{"example.", dns.TypeDNSKEY}:     tld.sign(tld.key.String()),
+		{"sub.example.", dns.TypeDS}:     tld.sign(ds.String()),
+		{"sub.example.", dns.TypeDNSKEY}: sub.sign(sub.key.String()),
+		{"www.sub.example.", dns.TypeA}:  sub.sign("www.sub.example. 300 IN A 192.0.2.1"),
+	}
+
+	var mu sync.Mutex
+	exchanges := map[question]int{}
+	anchors := 0
+	tag := "root-1"
+
+	zoneCuts, _ := lru.New(10)
+	cache, _ := newMessageCache(10, 0, 0)
+	r := &Resolver{
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+			if cut != "example." {
+				return nil, nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			anchors++
+
+			zone, err := dnssec.NewZone(cut, []dns.RR{tld.key.ToDS(dns.SHA256)})
+			if err != nil {
+				return nil, err
+			}
+			zone.Expire = time.Now().Add(time.Hour)
+			zone.Tag = tag
+			return zone, nil
+		},
+		zoneCuts: zoneCuts,
+		cache:    cache,
+		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			q := question{msg.Question[0].Name, msg.Question[0].Qtype}
+			exchanges[q]++
+
+			re := new(dns.Msg)
+			re.SetReply(msg)
+			re.Answer = answers[q]
+			return re, nil
+		},
+	}
+	r.SetAnchorTag(tag)
+
+	query := func(want string) {
+		msg, err := r.Query(context.Background(), "www.sub.example.", dns.TypeA)
+		if err != nil || !msg.AuthenticatedData {
+			t.Fatalf("got err %v, want secure answer", err)
+		}
+		if got := msg.Answer[0].(*dns.A).A.String(); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+
+	query("192.0.2.1")
+
+	// a new root with the same DS keeps the keys and cached answers
+	mu.Lock()
+	tag = "root-2"
+	mu.Unlock()
+	r.SetAnchorTag(tag)
+	query("192.0.2.1")
+
+	if anchors != 2 {
+		t.Fatalf("trust anchor loaded %d times, want 2", anchors)
+	}
+	for q, n := range exchanges {
+		if n != 1 {
+			t.Fatalf("%s %s exchanged %d times, want 1", q.name, dns.TypeToString[q.qtype], n)
+		}
+	}
+
+	// the DS was rotated on chain
+	mu.Lock()
+	tld = newTestZone(t, "example.")
+	answers[question{"example.", dns.TypeDNSKEY}] = tld.sign(tld.key.String())
+	answers[question{"sub.example.", dns.TypeDS}] = tld.sign(ds.String())
+	answers[question{"www.sub.example.", dns.TypeA}] = sub.sign("www.sub.example. 300 IN A 192.0.2.2")
+	tag = "root-3"
+	mu.Unlock()
+	r.SetAnchorTag(tag)
+	query("192.0.2.2")
+
+	if n := exchanges[question{"example.", dns.TypeDNSKEY}]; n != 2 {
+		t.Fatalf("keys of the rotated zone fetched %d times, want 2", n)
+	}
+	if n := exchanges[question{"sub.example.", dns.TypeDS}]; n != 2 {
+		t.Fatalf("delegation below the rotated zone validated %d times, want 2", n)
+	}
+}
+
+// TestResolver_StaleAnchor an anchor still tagged with an old
+// root after it was loaded again is used until RefreshAnchor
+func TestResolver_StaleAnchor(t *testing.T) {
+	zoneCuts, _ := lru.New(10)
+	anchors := 0
+	served := "root-1"
+	var failed error
+
+	r := &Resolver{
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+			if cut != "proofofconcept." {
+				return nil, nil
+			}
+			if failed != nil {
+				return nil, failed
+			}
+
+			anchors++
+			zone, err := dnssec.NewZone(cut, nil)
+			if err == nil {
+				zone.Expire = time.Now().Add(time.Hour)
+				zone.Tag = served
+			}
+			return zone, err
+		},
+		zoneCuts: zoneCuts,
+		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			re := new(dns.Msg)
+			re.SetReply(msg)
+
+			q := msg.Question[0]
+			switch q.Qtype {
+			case dns.TypeSOA:
+				soa, _ := dns.NewRR("proofofconcept. 300 IN SOA ns1.proofofconcept. admin.proofofconcept. 1 300 300 300 300")
+				re.Ns = append(re.Ns, soa)
+			case dns.TypeTLSA:
+				tlsa, _ := dns.NewRR(q.Name + " 300 IN TLSA 3 1 1 " + strings.Repeat("ab", 32))
+				re.Answer = append(re.Answer, tlsa)
+			}
+			return re, nil
+		},
+	}
+	r.SetAnchorTag("root-1")
+
+	query := func(want int) {
+		t.Helper()
+		if _, err := r.Query(context.Background(), "_443._tcp.proofofconcept.", dns.TypeTLSA); err != nil {
+			t.Fatal(err)
+		}
+		if anchors != want {
+			t.Fatalf("trust anchor loaded %d times, want %d", anchors, want)
+		}
+	}
+	query(1)
+
+	// the handler serves the old anchor while it fetches a new one
+	r.SetAnchorTag("root-2")
+	for i := 0; i < 3; i++ {
+		query(2)
+	}
+
+	served = "root-2"
+	r.RefreshAnchor("proofofconcept")
+	query(3)
+	query(3)
+
+	// failing to load a stale anchor fails the query
+	failed = errors.New("no peers")
+	r.SetAnchorTag("root-3")
+	if _, err := r.Query(context.Background(), "_443._tcp.proofofconcept.", dns.TypeTLSA); !errors.Is(err, failed) {
+		t.Fatalf("got %v, want %v", err, failed)
+	}
+}
+
+// TestResolver_StaleAnchorKeys a new anchor whose keys fail
+// to load isn't recorded as loaded again
+func TestResolver_StaleAnchorKeys(t *testing.T) {
+	old := newTestZone(t, "proofofconcept.")
+	rotated := newTestZone(t, "proofofconcept.")
+	zoneCuts, _ := lru.New(10)
+	anchors := 0
+	current := old
+	served := "root-1"
+	keysFail := false
+
+	r := &Resolver{
+		TrustAnchorPointHandler: func(ctx context.Context, cut string) (*dnssec.Zone, error) {
+			if cut != "proofofconcept." {
+				return nil, nil
+			}
+
+			anchors++
+			zone, err := dnssec.NewZone(cut, []dns.RR{current.key.ToDS(dns.SHA256)})
+			if err == nil {
+				zone.Expire = time.Now().Add(time.Hour)
+				zone.Tag = served
+			}
+			return zone, err
+		},
+		zoneCuts: zoneCuts,
+		exchangeTest: func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			re := new(dns.Msg)
+			re.SetReply(msg)
+
+			q := msg.Question[0]
+			switch q.Qtype {
+			case dns.TypeDNSKEY:
+				if keysFail {
+					re.Rcode = dns.RcodeServerFailure
+					return re, nil
+				}
+				re.Answer = current.sign(current.key.String())
+			case dns.TypeSOA:
+				re.Ns = current.sign("proofofconcept. 300 IN SOA ns1.proofofconcept. admin.proofofconcept. 1 300 300 300 300")
+			case dns.TypeTLSA:
+				re.Answer = current.sign(q.Name + " 300 IN TLSA 3 1 1 " + strings.Repeat("ab", 32))
+			}
+			return re, nil
+		},
+	}
+	r.SetAnchorTag("root-1")
+
+	query := func() error {
+		_, err := r.Query(context.Background(), "_443._tcp.proofofconcept.", dns.TypeTLSA)
+		return err
+	}
+	if err := query(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the name root changed along with the keys
+	// of the TLD but they can't be fetched yet
+	current, served, keysFail = rotated, "root-2", true
+	r.SetAnchorTag("root-2")
+	if err := query(); err == nil {
+		t.Fatal("query should fail without the new keys")
+	}
+	if r.anchorLoaded["proofofconcept."] {
+		t.Fatal("a failed load shouldn't count as loaded again")
+	}
+
+	keysFail = false
+	if err := query(); err != nil {
+		t.Fatal(err)
+	}
+	if anchors != 3 {
+		t.Fatalf("trust anchor loaded %d times, want 3", anchors)
+	}
+	if !r.anchorLoaded["proofofconcept."] || r.anchorStale("proofofconcept.") {
+		t.Fatal("the stored anchor should count as loaded again")
+	}
+}
+
+// TestResolver_SynthesizeDS a DS query at the apex of a cached
+// child zone is answered from the parent's NSEC records only
+func TestResolver_SynthesizeDS(t *testing.T) {
+	parent := newTestZone(t, "example.")
+	child := newTestZone(t, "sub.example.")
+	zoneCuts, _ := lru.New(10)
+	r := &Resolver{zoneCuts: zoneCuts}
+
+	cache := func(s *testZoneSigner, qname string, qtype uint16, ns ...[]dns.RR) {
+		zone, ok := zoneCuts.Get(s.zone)
+		if !ok {
+			zone = &dnssec.Zone{
+				Name:   s.zone,
+				Keys:   map[uint16]*dns.DNSKEY{s.key.KeyTag(): s.key},
+				Expire: time.Now().Add(time.Hour),
+				MinRSA: dnssec.DefaultMinRSAKeySize,
+			}
+			zoneCuts.Add(s.zone, zone)
+		}
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(qname, qtype)
+		msg.Response = true
+		for _, rrs := range ns {
+			msg.Ns = append(msg.Ns, rrs...)
+		}
+		if secure, err := zone.(*dnssec.Zone).Verify(context.Background(), msg, qname, qtype); err != nil || !secure {
+			t.Fatalf("%s: got secure = %v, %v", qname, secure, err)
+		}
+	}
+
+	// the child apex NSEC never has the DS bit
+	cache(child, "sub.example.", dns.TypeTXT,
+		child.sign("sub.example. 3600 IN SOA ns.sub.example. admin.sub.example. 1 3600 600 86400 300"),
+		child.sign("sub.example. 3600 IN NSEC a.sub.example. NS SOA RRSIG NSEC DNSKEY"))
+	cache(parent, "a.example.", dns.TypeTXT,
+		parent.sign("example. 3600 IN SOA ns.example. admin.example. 1 3600 600 86400 300"),
+		parent.sign("a.example. 3600 IN NSEC sub.example. A RRSIG NSEC"))
+
+	if msg := r.synthesizeNegative("sub.example.", dns.TypeTXT); msg == nil {
+		t.Fatal("want NODATA from the child zone")
+	}
+	if msg := r.synthesizeNegative("sub.example.", dns.TypeDS); msg != nil {
+		t.Fatal("DS synthesized without a proof from the parent")
+	}
+
+	// an insecure delegation proven by the parent
+	cache(parent, "sub.example.", dns.TypeDS,
+		parent.sign("example. 3600 IN SOA ns.example. admin.example. 1 3600 600 86400 300"),
+		parent.sign("sub.example. 3600 IN NSEC z.example. NS RRSIG NSEC"))
+
+	msg := r.synthesizeNegative("sub.example.", dns.TypeDS)
+	if msg == nil {
+		t.Fatal("want NODATA from the parent zone")
+	}
+	for _, rr := range msg.Ns {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.SignerName != "example." {
+			t.Fatalf("got proof signed by %s, want example.", sig.SignerName)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package hnsquery
+
+import (
+	"context"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+)
+
+// maxAliasChain AliasMode records followed before giving up
+const maxAliasChain = 8
+
+// HTTPSEndpoint a ServiceMode HTTPS record with its SvcParams parsed
+type HTTPSEndpoint struct {
+	Priority uint16 `json:"priority"`
+
+	// Target the name to connect to. A "." target
+	// is replaced with the owner of the record.
+	Target string `json:"target"`
+
+	// Port if set replaces the default port
+	Port uint16 `json:"port,omitempty"`
+
+	ALPN          []string `json:"alpn,omitempty"`
+	NoDefaultALPN bool     `json:"noDefaultAlpn,omitempty"`
+
+	// ECHConfig the ECHConfigList for Encrypted ClientHello
+	ECHConfig []byte `json:"echConfig,omitempty"`
+
+	IPv4Hints []net.IP `json:"ipv4Hints,omitempty"`
+	IPv6Hints []net.IP `json:"ipv6Hints,omitempty"`
+}
+
+// HTTPSResult the endpoints advertised for a host
+type HTTPSResult struct {
+	// Name the owner of the ServiceMode records after following any
+	// AliasMode records. If there are no endpoints clients should
+	// connect to Name as usual. It's "." if the service isn't available.
+	Name string `json:"name"`
+
+	// Endpoints ordered by priority
+	Endpoints []HTTPSEndpoint `json:"endpoints,omitempty"`
+
+	// Secure every answer in the chain was validated
+	Secure bool `json:"secure"`
+}
+
+// LookupHTTPS resolves the HTTPS records of host following AliasMode
+// records (RFC 9460). The answers are validated like any other query,
+// Secure is only set if each of them was signed. An AliasMode target
+// of "." means the service isn't available and returns no endpoints.
+func (r *Resolver) LookupHTTPS(ctx context.Context, host string) (*HTTPSResult, error) {
+	name := dns.CanonicalName(host)
+	result := &HTTPSResult{Name: name, Secure: true}
+	seen := map[string]struct{}{name: {}}
+
+	for i := 0; i < maxAliasChain; i++ {
+		msg, err := r.Query(ctx, name, dns.TypeHTTPS)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
+			return nil, fmt.Errorf("received non-success rcode: %d: %w", msg.Rcode, ErrServerFailure)
+		}
+
+		result.Secure = result.Secure && msg.AuthenticatedData
+
+		var alias *dns.HTTPS
+		var service []*dns.HTTPS
+		for _, rr := range msg.Answer {
+			https, ok := rr.(*dns.HTTPS)
+			if !ok {
+				continue
+			}
+			if https.Priority == 0 {
+				alias = https
+				continue
+			}
+			service = append(service, https)
+		}
+
+		// ServiceMode records are ignored
+		// if there's an AliasMode record
+		if alias == nil {
+			result.Endpoints = httpsEndpoints(service)
+			return result, nil
+		}
+
+		target := dns.CanonicalName(alias.Target)
+		if target == "." {
+			result.Name = target
+			return result, nil
+		}
+		if _, ok := seen[target]; ok {
+			return nil, fmt.Errorf("alias chain loop at %s: %w", target, ErrAliasLoop)
+		}
+		seen[target] = struct{}{}
+
+		name = target
+		result.Name = name
+	}
+
+	return nil, fmt.Errorf("more than %d https aliases from %s: %w", maxAliasChain, host, ErrAliasLoop)
+}
+
+// httpsEndpoints parses the ServiceMode records skipping
+// those with mandatory keys that aren't supported
+func httpsEndpoints(rrs []*dns.HTTPS) []HTTPSEndpoint {
+	var endpoints []HTTPSEndpoint
+
+	for _, rr := range rrs {
+		endpoint := HTTPSEndpoint{
+			Priority: rr.Priority,
+			Target:   dns.CanonicalName(rr.Target),
+		}
+		if endpoint.Target == "." {
+			endpoint.Target = dns.CanonicalName(rr.Hdr.Name)
+		}
+
+		supported := true
+		for _, kv := range rr.Value {
+			switch kv := kv.(type) {
+			case *dns.SVCBMandatory:
+				for _, key := range kv.Code {
+					if key > dns.SVCB_IPV6HINT {
+						supported = false
+					}
+				}
+			case *dns.SVCBAlpn:
+				endpoint.ALPN = kv.Alpn
+			case *dns.SVCBNoDefaultAlpn:
+				endpoint.NoDefaultALPN = true
+			case *dns.SVCBPort:
+				endpoint.Port = kv.Port
+			case *dns.SVCBIPv4Hint:
+				endpoint.IPv4Hints = kv.Hint
+			case *dns.SVCBECHConfig:
+				endpoint.ECHConfig = kv.ECH
+			case *dns.SVCBIPv6Hint:
+				endpoint.IPv6Hints = kv.Hint
+			}
+		}
+
+		if supported {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Priority < endpoints[j].Priority
+	})
+
+	return endpoints
+}
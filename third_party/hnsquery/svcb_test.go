@@ -0,0 +1,59 @@
+package hnsquery
+
+import (
+	"context"
+	"errors"
+	"github.com/miekg/dns"
+	"testing"
+)
+
+func TestResolver_LookupHTTPS(t *testing.T) {
+	s := newTestZoneSigner(t)
+	answers := map[string][]dns.RR{
+		"alias.example.": s.sign(`alias.example. 300 IN HTTPS 0 svc.example.`),
+		"svc.example.": s.sign(
+			`svc.example. 300 IN HTTPS 2 . alpn="h2" ipv4hint="192.0.2.1"`,
+			`svc.example. 300 IN HTTPS 1 pool.example. alpn="h3,h2" port="8443" echconfig="AQID" ipv6hint="2001:db8::1"`,
+			`svc.example. 300 IN HTTPS 3 other.example. mandatory="key65000" key65000="x"`,
+		),
+		"gone.example.":   s.sign(`gone.example. 300 IN HTTPS 0 .`),
+		"loop-a.example.": s.sign(`loop-a.example. 300 IN HTTPS 0 loop-b.example.`),
+		"loop-b.example.": s.sign(`loop-b.example. 300 IN HTTPS 0 loop-a.example.`),
+		"bogus.example.": func() []dns.RR {
+			rrs := s.sign(`bogus.example. 300 IN HTTPS 1 . alpn="h2"`)
+			rrs[0].(*dns.HTTPS).Priority = 2
+			return rrs
+		}(),
+	}
+	r := s.resolver(answers)
+
+	result, err := r.LookupHTTPS(context.Background(), "Alias.Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Name != "svc.example." || !result.Secure || len(result.Endpoints) != 2 {
+		t.Fatalf("got %+v, want 2 secure endpoints for svc.example.", result)
+	}
+
+	first, second := result.Endpoints[0], result.Endpoints[1]
+	if first.Target != "pool.example." || first.Port != 8443 || len(first.ALPN) != 2 || first.ALPN[0] != "h3" ||
+		string(first.ECHConfig) != "\x01\x02\x03" || len(first.IPv6Hints) != 1 {
+		t.Fatalf("got first endpoint %+v", first)
+	}
+	if second.Target != "svc.example." || second.Port != 0 || len(second.IPv4Hints) != 1 ||
+		second.IPv4Hints[0].String() != "192.0.2.1" {
+		t.Fatalf("got second endpoint %+v", second)
+	}
+
+	if result, err = r.LookupHTTPS(context.Background(), "gone.example"); err != nil || result.Name != "." || len(result.Endpoints) != 0 {
+		t.Fatalf("got %+v, %v, want an unavailable service", result, err)
+	}
+
+	if _, err = r.LookupHTTPS(context.Background(), "loop-a.example"); !errors.Is(err, ErrAliasLoop) {
+		t.Fatalf("got %v, want %v", err, ErrAliasLoop)
+	}
+
+	if _, err = r.LookupHTTPS(context.Background(), "bogus.example"); !errors.Is(err, ErrDNSSECFailed) {
+		t.Fatalf("got %v, want %v", err, ErrDNSSECFailed)
+	}
+}
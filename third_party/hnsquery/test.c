@@ -13,11 +13,27 @@ void cgoAfterResolve(
         bool exists,
         const uint8_t *data,
         size_t data_len,
+        const uint8_t *root,
+        uint32_t height,
+        const uint8_t *proof,
+        size_t proof_len,
         const void *arg
 ) {
     printf("cgo: received name: %s\n", name);
 }
 
+void cgoAfterPoolUpdate(
+        int ready,
+        uint32_t height,
+        float progress,
+        int total_peers,
+        int active_peers,
+        const uint8_t *name_root,
+        const void *arg
+) {
+    printf("cgo: pool update height: %d, ready: %d\n", height, ready);
+}
+
 void hns_thread(void *arg) {
     hns_ctx *ctx = (hns_ctx *) arg;
     assert(ctx);
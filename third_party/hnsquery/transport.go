@@ -0,0 +1,162 @@
+package hnsquery
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrTruncated = errors.New("response truncated")
+var ErrUnsupportedTransport = errors.New("unsupported upstream transport")
+
+// Transport exchanges DNS messages with a single upstream server
+type Transport interface {
+	Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+}
+
+// TransportFunc adapts a function to a Transport
+type TransportFunc func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+
+func (f TransportFunc) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	return f(ctx, msg)
+}
+
+// NewTransport creates a transport for the upstream url. The scheme
+// selects the transport: udp://, tcp://, tls:// (DNS over TLS) or
+// https:// (DNS over HTTPS). quic:// is reserved for DNS over QUIC
+// and fails with ErrUnsupportedTransport. tlsConfig may be nil and
+// is only used by encrypted transports.
+func NewTransport(upstream string, tlsConfig *tls.Config) (Transport, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("bad upstream `%s`: %v", upstream, err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "udp":
+		return newUDPTransport(hostPort(u.Host, "53")), nil
+	case "tcp":
+		return newTCPTransport(hostPort(u.Host, "53")), nil
+	case "tls":
+		return newTLSTransport(hostPort(u.Host, "853"), tlsConfig), nil
+	case "https":
+		return newDoHTransport(u, tlsConfig), nil
+	case "quic":
+		// DoQ (RFC 9250) needs a QUIC stack
+		// it's a separate TODO
+		return nil, fmt.Errorf("%s: %w", upstream, ErrUnsupportedTransport)
+	}
+
+	return nil, fmt.Errorf("bad upstream scheme `%s`: %w", u.Scheme, ErrUnsupportedTransport)
+}
+
+func hostPort(host, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+}
+
+// udpTransport sends queries over UDP and retries
+// over TCP if the response is truncated
+type udpTransport struct {
+	udp  dns.Client
+	tcp  dns.Client
+	addr string
+}
+
+func newUDPTransport(addr string) *udpTransport {
+	t := &udpTransport{addr: addr}
+	t.udp.Net = "udp"
+	t.tcp.Net = "tcp"
+	return t
+}
+
+func (t *udpTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	re, err := exchangeContext(ctx, &t.udp, msg, t.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !re.Truncated {
+		return re, nil
+	}
+
+	re, err = exchangeContext(ctx, &t.tcp, msg, t.addr)
+	if err != nil {
+		return nil, fmt.Errorf("tcp retry after truncated response: %v", err)
+	}
+	if re.Truncated {
+		return nil, ErrTruncated
+	}
+
+	return re, nil
+}
+
+func (t *udpTransport) String() string {
+	return "udp://" + t.addr
+}
+
+// streamTransport is used for TCP and DNS over TLS
+type streamTransport struct {
+	dns    dns.Client
+	addr   string
+	scheme string
+}
+
+func newTCPTransport(addr string) *streamTransport {
+	t := &streamTransport{addr: addr, scheme: "tcp"}
+	t.dns.Net = "tcp"
+	return t
+}
+
+func newTLSTransport(addr string, tlsConfig *tls.Config) *streamTransport {
+	t := &streamTransport{addr: addr, scheme: "tls"}
+	t.dns.Net = "tcp-tls"
+	t.dns.Timeout = 10 * time.Second
+
+	if tlsConfig != nil {
+		t.dns.TLSConfig = tlsConfig.Clone()
+	} else {
+		t.dns.TLSConfig = &tls.Config{}
+	}
+
+	if t.dns.TLSConfig.ServerName == "" {
+		host, _, _ := net.SplitHostPort(addr)
+		t.dns.TLSConfig.ServerName = host
+	}
+
+	return t
+}
+
+func (t *streamTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	re, err := exchangeContext(ctx, &t.dns, msg, t.addr)
+	if err != nil {
+		return nil, err
+	}
+	if re.Truncated {
+		return nil, ErrTruncated
+	}
+
+	return re, nil
+}
+
+func (t *streamTransport) String() string {
+	return t.scheme + "://" + t.addr
+}
+
+// exchangeContext is c.ExchangeContext on a copy of c's settings.
+// ExchangeContext sets the client's dialer on every call so a
+// client can't be shared by concurrent queries.
+func exchangeContext(ctx context.Context, c *dns.Client, msg *dns.Msg, addr string) (*dns.Msg, error) {
+	client := &dns.Client{Net: c.Net, TLSConfig: c.TLSConfig, Timeout: c.Timeout}
+	re, _, err := client.ExchangeContext(ctx, msg, addr)
+	return re, err
+}
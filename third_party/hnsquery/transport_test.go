@@ -0,0 +1,295 @@
+package hnsquery
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/miekg/dns"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testAnswer(msg *dns.Msg) *dns.Msg {
+	re := new(dns.Msg)
+	re.SetReply(msg)
+	a, _ := dns.NewRR(msg.Question[0].Name + " 300 IN A 127.0.0.1")
+	re.Answer = append(re.Answer, a)
+	return re
+}
+
+// startTestServer starts an in-process dns server on udp and tcp
+// using the same port and returns its address
+func startTestServer(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+
+	udp := &dns.Server{PacketConn: pc, Handler: handler}
+	tcp := &dns.Server{Listener: l, Handler: handler}
+	go udp.ActivateAndServe()
+	go tcp.ActivateAndServe()
+
+	t.Cleanup(func() {
+		udp.Shutdown()
+		tcp.Shutdown()
+	})
+
+	return pc.LocalAddr().String()
+}
+
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		upstream string
+		want     string
+		err      error
+	}{
+		{"udp://127.0.0.1", "udp://127.0.0.1:53", nil},
+		{"udp://127.0.0.1:5353", "udp://127.0.0.1:5353", nil},
+		{"tcp://[::1]", "tcp://[::1]:53", nil},
+		{"tls://dns.example", "tls://dns.example:853", nil},
+		{"https://hs.dnssec.dev/dns-query", "https://hs.dnssec.dev/dns-query", nil},
+		{"quic://dns.example", "", ErrUnsupportedTransport},
+		{"ftp://dns.example", "", ErrUnsupportedTransport},
+	}
+
+	for _, test := range tests {
+		tr, err := NewTransport(test.upstream, nil)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Fatalf("%s: got err = %v, want %v", test.upstream, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.upstream, err)
+		}
+
+		if got := tr.(interface{ String() string }).String(); got != test.want {
+			t.Fatalf("got transport %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestUDPTransport_TruncatedRetry(t *testing.T) {
+	var tcpQueries int32
+	addr := startTestServer(t, func(w dns.ResponseWriter, msg *dns.Msg) {
+		if w.RemoteAddr().Network() == "udp" {
+			re := new(dns.Msg)
+			re.SetReply(msg)
+			re.Truncated = true
+			w.WriteMsg(re)
+			return
+		}
+
+		atomic.AddInt32(&tcpQueries, 1)
+		w.WriteMsg(testAnswer(msg))
+	})
+
+	tr, err := NewTransport("udp://"+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeA)
+	re, err := tr.Exchange(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if re.Truncated || len(re.Answer) != 1 {
+		t.Fatalf("got truncated = %v, answers = %d, want full answer", re.Truncated, len(re.Answer))
+	}
+	if got := atomic.LoadInt32(&tcpQueries); got != 1 {
+		t.Fatalf("got %d tcp queries, want 1", got)
+	}
+}
+
+// TestTransport_Concurrent a transport is shared by
+// every lookup of the resolver
+func TestTransport_Concurrent(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, msg *dns.Msg) {
+		w.WriteMsg(testAnswer(msg))
+	})
+
+	for _, scheme := range []string{"udp", "tcp"} {
+		tr, err := NewTransport(scheme+"://"+addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				msg := new(dns.Msg)
+				msg.SetQuestion("example.", dns.TypeA)
+				_, err := tr.Exchange(context.Background(), msg)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("%s: %v", scheme, err)
+			}
+		}
+	}
+}
+
+func TestTCPTransport(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, msg *dns.Msg) {
+		if w.RemoteAddr().Network() != "tcp" {
+			t.Error("got query over udp, want tcp")
+		}
+		w.WriteMsg(testAnswer(msg))
+	})
+
+	tr, err := NewTransport("tcp://"+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeA)
+	if _, err := tr.Exchange(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSTransport(t *testing.T) {
+	cert, pool := testCertificate(t)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &dns.Server{Listener: l, Net: "tcp-tls", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, msg *dns.Msg) {
+		w.WriteMsg(testAnswer(msg))
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeA)
+
+	// untrusted certificate
+	tr, err := NewTransport("tls://"+l.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Exchange(context.Background(), msg); err == nil {
+		t.Fatal("got no error, want certificate error")
+	}
+
+	tr, err = NewTransport("tls://"+l.Addr().String(), &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := tr.Exchange(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(re.Answer) != 1 {
+		t.Fatalf("got %d answers, want 1", len(re.Answer))
+	}
+}
+
+func TestDoHTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		msg := new(dns.Msg)
+		if err := msg.Unpack(body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		buf, _ := testAnswer(msg).Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(buf)
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	u, _ := url.Parse(server.URL + "/dns-query")
+	tr, err := NewTransport(u.String(), &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeA)
+	re, err := tr.Exchange(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(re.Answer) != 1 {
+		t.Fatalf("got %d answers, want 1", len(re.Answer))
+	}
+}
@@ -0,0 +1,286 @@
+package hnsquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UpstreamStrategy decides how queries are distributed among upstreams
+type UpstreamStrategy int
+
+const (
+	// StrategyFailover queries one upstream at a time starting
+	// with the healthiest one and moves on to the next on failure
+	StrategyFailover UpstreamStrategy = iota
+
+	// StrategyRace queries all healthy upstreams in parallel
+	// and uses the first successful response
+	StrategyRace
+)
+
+const (
+	defaultUpstreamTimeout = 5 * time.Second
+	defaultMaxFailures     = 3
+	defaultEjectCooldown   = 30 * time.Second
+)
+
+var ErrNoUpstreams = errors.New("no upstream resolvers configured")
+var ErrIterativeUpstreams = errors.New("iterative mode doesn't use upstream resolvers")
+var ErrServerFailure = errors.New("upstream server failure")
+
+// UpstreamStatus a health snapshot of a single upstream
+type UpstreamStatus struct {
+	Name     string
+	RTT      time.Duration
+	Queries  uint64
+	Errors   uint64
+	Failures int
+	Ejected  bool
+}
+
+type upstream struct {
+	name      string
+	transport Transport
+
+	sync.Mutex
+	// smoothed round trip time zero if not measured yet
+	rtt time.Duration
+	// consecutive failures
+	failures     int
+	queries      uint64
+	errors       uint64
+	ejectedUntil time.Time
+}
+
+// upstreamPool is a Transport that spreads queries over
+// multiple upstreams and tracks their health
+type upstreamPool struct {
+	upstreams   []*upstream
+	strategy    UpstreamStrategy
+	timeout     time.Duration
+	maxFailures int
+	cooldown    time.Duration
+
+	// for testing
+	now func() time.Time
+}
+
+func newUpstreamPool(strategy UpstreamStrategy) *upstreamPool {
+	return &upstreamPool{
+		strategy:    strategy,
+		timeout:     defaultUpstreamTimeout,
+		maxFailures: defaultMaxFailures,
+		cooldown:    defaultEjectCooldown,
+		now:         time.Now,
+	}
+}
+
+func (p *upstreamPool) add(name string, t Transport) {
+	p.upstreams = append(p.upstreams, &upstream{name: name, transport: t})
+}
+
+// ordered returns upstreams sorted by health, ejected
+// upstreams are only returned if all are ejected
+func (p *upstreamPool) ordered() []*upstream {
+	type candidate struct {
+		u        *upstream
+		ejected  bool
+		until    time.Time
+		failures int
+		rtt      time.Duration
+	}
+
+	now := p.now()
+	candidates := make([]candidate, len(p.upstreams))
+	healthy := 0
+	for i, u := range p.upstreams {
+		u.Lock()
+		candidates[i] = candidate{
+			u:        u,
+			ejected:  now.Before(u.ejectedUntil),
+			until:    u.ejectedUntil,
+			failures: u.failures,
+			rtt:      u.rtt,
+		}
+		u.Unlock()
+
+		if !candidates[i].ejected {
+			healthy++
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.ejected != b.ejected {
+			return !a.ejected
+		}
+		if a.ejected {
+			return a.until.Before(b.until)
+		}
+		if a.failures != b.failures {
+			return a.failures < b.failures
+		}
+		// unmeasured upstreams keep their configured order
+		// after the measured ones
+		if a.rtt == 0 || b.rtt == 0 {
+			return a.rtt != 0 && b.rtt == 0
+		}
+		return a.rtt < b.rtt
+	})
+
+	if healthy == 0 {
+		healthy = len(candidates)
+	}
+
+	ordered := make([]*upstream, healthy)
+	for i := range ordered {
+		ordered[i] = candidates[i].u
+	}
+	return ordered
+}
+
+func (p *upstreamPool) record(u *upstream, rtt time.Duration, err error) {
+	u.Lock()
+	defer u.Unlock()
+
+	u.queries++
+	if err == nil {
+		u.failures = 0
+		if u.rtt == 0 {
+			u.rtt = rtt
+		} else {
+			u.rtt = (u.rtt*7 + rtt) / 8
+		}
+		return
+	}
+
+	u.errors++
+	u.failures++
+	if u.failures >= p.maxFailures {
+		u.ejectedUntil = p.now().Add(p.cooldown)
+	}
+}
+
+func (p *upstreamPool) try(ctx context.Context, u *upstream, msg *dns.Msg) (*dns.Msg, error) {
+	tctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := p.now()
+	re, err := u.transport.Exchange(tctx, msg)
+	if err == nil && (re.Rcode == dns.RcodeServerFailure || re.Rcode == dns.RcodeRefused) {
+		err = fmt.Errorf("returned %s: %w", dns.RcodeToString[re.Rcode], ErrServerFailure)
+	}
+
+	// the caller gave up, it's not the upstream's fault
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	p.record(u, p.now().Sub(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: %w", u.name, err)
+	}
+
+	return re, nil
+}
+
+func (p *upstreamPool) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	if len(p.upstreams) == 0 {
+		return nil, ErrNoUpstreams
+	}
+
+	upstreams := p.ordered()
+	if p.strategy == StrategyRace && len(upstreams) > 1 {
+		return p.race(ctx, upstreams, msg)
+	}
+
+	var lastErr error
+	for _, u := range upstreams {
+		re, err := p.try(ctx, u, msg)
+		if err == nil {
+			return re, nil
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (p *upstreamPool) race(ctx context.Context, upstreams []*upstream, msg *dns.Msg) (*dns.Msg, error) {
+	type result struct {
+		re  *dns.Msg
+		err error
+	}
+
+	// slower upstreams keep going after the first answer until their
+	// own timeout so they're measured. They only stop early if the
+	// caller gives up before any upstream answered.
+	rctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	results := make(chan result, len(upstreams))
+	for _, u := range upstreams {
+		wg.Add(1)
+		go func(u *upstream, msg *dns.Msg) {
+			defer wg.Done()
+			re, err := p.try(rctx, u, msg)
+			results <- result{re, err}
+		}(u, msg.Copy())
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	var lastErr error
+	for range upstreams {
+		res := <-results
+		if res.err == nil {
+			return res.re, nil
+		}
+		lastErr = res.err
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return nil, lastErr
+}
+
+func (p *upstreamPool) status() []UpstreamStatus {
+	now := p.now()
+	status := make([]UpstreamStatus, len(p.upstreams))
+	for i, u := range p.upstreams {
+		u.Lock()
+		status[i] = UpstreamStatus{
+			Name:     u.name,
+			RTT:      u.rtt,
+			Queries:  u.queries,
+			Errors:   u.errors,
+			Failures: u.failures,
+			Ejected:  now.Before(u.ejectedUntil),
+		}
+		u.Unlock()
+	}
+
+	return status
+}
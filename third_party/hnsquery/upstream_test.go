@@ -0,0 +1,249 @@
+package hnsquery
+
+import (
+	"context"
+	"errors"
+	"github.com/miekg/dns"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeTransport struct {
+	calls int32
+	delay time.Duration
+	err   error
+}
+
+func (f *fakeTransport) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	atomic.AddInt32(&f.calls, 1)
+
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return testAnswer(msg), nil
+}
+
+func (f *fakeTransport) count() int {
+	return int(atomic.LoadInt32(&f.calls))
+}
+
+func testQuery() *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeA)
+	return msg
+}
+
+func TestUpstreamPool_Failover(t *testing.T) {
+	now := time.Unix(1000, 0)
+	bad := &fakeTransport{err: errors.New("connection refused")}
+	good := &fakeTransport{}
+
+	p := newUpstreamPool(StrategyFailover)
+	p.now = func() time.Time { return now }
+	p.maxFailures = 2
+	p.cooldown = time.Minute
+	p.add("bad", bad)
+	p.add("good", good)
+
+	for i := 0; i < 4; i++ {
+		if _, err := p.Exchange(context.Background(), testQuery()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// bad upstream is tried first, then deprioritized
+	// after failing and ejected after 2 failures
+	if bad.count() != 1 {
+		t.Fatalf("got %d queries to bad upstream, want 1", bad.count())
+	}
+	if good.count() != 4 {
+		t.Fatalf("got %d queries to good upstream, want 4", good.count())
+	}
+
+	// force a second failure
+	good.err = errors.New("timeout")
+	if _, err := p.Exchange(context.Background(), testQuery()); err == nil {
+		t.Fatal("got no error, want all upstreams failed")
+	}
+
+	status := p.status()
+	if !status[0].Ejected || status[0].Failures != 2 {
+		t.Fatalf("got bad upstream status %+v, want ejected", status[0])
+	}
+
+	// ejected upstream is skipped while the other is healthy
+	good.err = nil
+	calls := bad.count()
+	if _, err := p.Exchange(context.Background(), testQuery()); err != nil {
+		t.Fatal(err)
+	}
+	if bad.count() != calls {
+		t.Fatal("ejected upstream shouldn't be queried")
+	}
+
+	// after cooldown it's tried again and recovers
+	now = now.Add(2 * time.Minute)
+	bad.err = nil
+	good.err = errors.New("timeout")
+	if _, err := p.Exchange(context.Background(), testQuery()); err != nil {
+		t.Fatal(err)
+	}
+	if status := p.status(); status[0].Ejected || status[0].Failures != 0 {
+		t.Fatalf("got status %+v, want recovered upstream", status[0])
+	}
+}
+
+func TestUpstreamPool_StuckUpstream(t *testing.T) {
+	stuck := &fakeTransport{delay: time.Hour}
+	good := &fakeTransport{}
+
+	p := newUpstreamPool(StrategyFailover)
+	p.timeout = 20 * time.Millisecond
+	p.maxFailures = 1
+	p.add("stuck", stuck)
+	p.add("good", good)
+
+	if _, err := p.Exchange(context.Background(), testQuery()); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := p.status(); !status[0].Ejected {
+		t.Fatal("stuck upstream should be ejected")
+	}
+
+	if _, err := p.Exchange(context.Background(), testQuery()); err != nil {
+		t.Fatal(err)
+	}
+	if stuck.count() != 1 {
+		t.Fatalf("got %d queries to stuck upstream, want 1", stuck.count())
+	}
+}
+
+func TestUpstreamPool_Race(t *testing.T) {
+	slow := &fakeTransport{delay: time.Hour}
+	fast := &fakeTransport{}
+
+	p := newUpstreamPool(StrategyRace)
+	p.timeout = 50 * time.Millisecond
+	p.maxFailures = 1
+	p.add("slow", slow)
+	p.add("fast", fast)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := p.Exchange(context.Background(), testQuery()); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("race should return the fastest response")
+	}
+
+	// the slow upstream may still be starting
+	// after the fast one answered
+	deadline := time.Now().Add(5 * time.Second)
+	for slow.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if slow.count() != 1 || fast.count() != 1 {
+		t.Fatal("race should query all healthy upstreams")
+	}
+
+	// the slow upstream times out after the fast one answered
+	for p.status()[0].Failures == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if status := p.status(); !status[0].Ejected || status[1].Failures != 0 {
+		t.Fatalf("got status %+v, want slow upstream ejected", status)
+	}
+}
+
+func TestUpstreamPool_RaceCancelled(t *testing.T) {
+	slow := &fakeTransport{delay: time.Hour}
+
+	p := newUpstreamPool(StrategyRace)
+	p.timeout = time.Hour
+	p.add("a", slow)
+	p.add("b", slow)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Exchange(ctx, testQuery()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want deadline exceeded", err)
+	}
+
+	// the caller gave up, it's not the upstreams' fault
+	for _, status := range p.status() {
+		if status.Failures != 0 || status.Queries != 0 {
+			t.Fatalf("got status %+v, want nothing recorded", status)
+		}
+	}
+}
+
+func TestUpstreamPool_ServerFailure(t *testing.T) {
+	servfail := TransportFunc(func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		re := new(dns.Msg)
+		re.SetRcode(msg, dns.RcodeServerFailure)
+		return re, nil
+	})
+	good := &fakeTransport{}
+
+	p := newUpstreamPool(StrategyFailover)
+	p.add("servfail", servfail)
+	p.add("good", good)
+
+	re, err := p.Exchange(context.Background(), testQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re.Rcode != dns.RcodeSuccess || good.count() != 1 {
+		t.Fatal("want failover on SERVFAIL")
+	}
+}
+
+func TestUpstreamPool_PrefersLowLatency(t *testing.T) {
+	p := newUpstreamPool(StrategyFailover)
+	p.add("a", &fakeTransport{})
+	p.add("b", &fakeTransport{})
+
+	p.upstreams[0].rtt = 200 * time.Millisecond
+	p.upstreams[1].rtt = 20 * time.Millisecond
+
+	if got := p.ordered()[0].name; got != "b" {
+		t.Fatalf("got first upstream %s, want b", got)
+	}
+}
+
+func TestNewResolver_Upstreams(t *testing.T) {
+	if _, err := NewResolver(&ResolverConfig{}); !errors.Is(err, ErrNoUpstreams) {
+		t.Fatalf("got err = %v, want %v", err, ErrNoUpstreams)
+	}
+
+	r, err := NewResolver(&ResolverConfig{
+		Forward:   "https://hs.dnssec.dev/dns-query",
+		Upstreams: []string{"udp://127.0.0.1", "tls://127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := r.UpstreamStatus()
+	if len(status) != 3 || status[0].Name != "https://hs.dnssec.dev/dns-query" {
+		t.Fatalf("got upstreams %+v, want forward first", status)
+	}
+}
@@ -0,0 +1,402 @@
+// Package urkel verifies Urkel tree proofs of Handshake names.
+// It's a port of the radix tree proofs in hnsd proof.c.
+package urkel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+	"strings"
+)
+
+// ErrBadProof the proof doesn't prove the key against the root
+var ErrBadProof = errors.New("invalid urkel proof")
+
+var (
+	ErrProofEncoding = fmt.Errorf("malformed proof: %w", ErrBadProof)
+	ErrSamePath      = fmt.Errorf("key is in the short path: %w", ErrBadProof)
+	ErrSameKey       = fmt.Errorf("collision with the same key: %w", ErrBadProof)
+	ErrNegativeDepth = fmt.Errorf("negative depth: %w", ErrBadProof)
+	ErrPathMismatch  = fmt.Errorf("path mismatch: %w", ErrBadProof)
+	ErrTooDeep       = fmt.Errorf("proof too deep: %w", ErrBadProof)
+	ErrHashMismatch  = fmt.Errorf("root hash mismatch: %w", ErrBadProof)
+)
+
+// ProofType how the proof ends
+type ProofType uint8
+
+const (
+	// TypeDeadEnd the key's path ends at an empty node
+	TypeDeadEnd ProofType = iota
+	// TypeShort the key's path diverges from the prefix of an internal node
+	TypeShort
+	// TypeCollision the key's path ends at the leaf of another key
+	TypeCollision
+	// TypeExists the key exists with Value
+	TypeExists
+)
+
+const (
+	// MaxValueSize the largest value hnsd accepts
+	MaxValueSize = 668
+
+	keySize  = 32
+	hashSize = 32
+	maxDepth = 256
+)
+
+var (
+	leafPrefix     = []byte{0x00}
+	internalPrefix = []byte{0x01}
+	skipPrefix     = []byte{0x02}
+)
+
+// ProofNode a sibling on the path from the root to the key
+type ProofNode struct {
+	// Prefix the bits skipped by the node
+	Prefix     []byte
+	PrefixBits uint16
+	Hash       [hashSize]byte
+}
+
+// Proof an Urkel tree proof
+type Proof struct {
+	Type  ProofType
+	Depth uint16
+	Nodes []ProofNode
+
+	// TypeShort the internal node the path diverged from
+	Prefix      []byte
+	PrefixBits  uint16
+	Left, Right [hashSize]byte
+
+	// TypeCollision the key and value hash of the other leaf
+	Key  [keySize]byte
+	Hash [hashSize]byte
+
+	// TypeExists the value of the key
+	Value []byte
+}
+
+// HashName the tree key of a Handshake name
+func HashName(name string) [keySize]byte {
+	return sha3.Sum256([]byte(strings.ToLower(strings.TrimSuffix(name, "."))))
+}
+
+// ParseProof decodes a proof in the format used by the proof p2p message
+func ParseProof(data []byte) (*Proof, error) {
+	r := &proofReader{data: data}
+	p := &Proof{}
+
+	field := r.u16()
+	p.Type = ProofType(field >> 14)
+	p.Depth = field &^ (3 << 14)
+	if p.Depth > maxDepth {
+		return nil, fmt.Errorf("depth %d: %w", p.Depth, ErrProofEncoding)
+	}
+
+	count := int(r.u16())
+	if count > maxDepth {
+		return nil, fmt.Errorf("%d nodes: %w", count, ErrProofEncoding)
+	}
+
+	bitmap := r.bytes((count + 7) / 8)
+	for i := 0; i < count && r.err == nil; i++ {
+		var node ProofNode
+		if hasBit(bitmap, i) {
+			node.Prefix, node.PrefixBits = r.bits()
+		}
+		copy(node.Hash[:], r.bytes(hashSize))
+		p.Nodes = append(p.Nodes, node)
+	}
+
+	switch p.Type {
+	case TypeShort:
+		p.Prefix, p.PrefixBits = r.bits()
+		copy(p.Left[:], r.bytes(hashSize))
+		copy(p.Right[:], r.bytes(hashSize))
+	case TypeCollision:
+		copy(p.Key[:], r.bytes(keySize))
+		copy(p.Hash[:], r.bytes(hashSize))
+	case TypeExists:
+		size := int(r.u16())
+		if size > MaxValueSize {
+			return nil, fmt.Errorf("value size %d: %w", size, ErrProofEncoding)
+		}
+		p.Value = append([]byte{}, r.bytes(size)...)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes: %w", len(r.data), ErrProofEncoding)
+	}
+
+	return p, nil
+}
+
+// Encode the proof in the format read by ParseProof
+func (p *Proof) Encode() []byte {
+	var buf bytes.Buffer
+	writeU16(&buf, uint16(p.Type)<<14|p.Depth)
+	writeU16(&buf, uint16(len(p.Nodes)))
+
+	bitmap := make([]byte, (len(p.Nodes)+7)/8)
+	for i, node := range p.Nodes {
+		if node.PrefixBits > 0 {
+			bitmap[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+	buf.Write(bitmap)
+
+	for _, node := range p.Nodes {
+		if node.PrefixBits > 0 {
+			writeBits(&buf, node.Prefix, node.PrefixBits)
+		}
+		buf.Write(node.Hash[:])
+	}
+
+	switch p.Type {
+	case TypeShort:
+		writeBits(&buf, p.Prefix, p.PrefixBits)
+		buf.Write(p.Left[:])
+		buf.Write(p.Right[:])
+	case TypeCollision:
+		buf.Write(p.Key[:])
+		buf.Write(p.Hash[:])
+	case TypeExists:
+		writeU16(&buf, uint16(len(p.Value)))
+		buf.Write(p.Value)
+	}
+
+	return buf.Bytes()
+}
+
+// Verify checks the proof of key against root. It returns
+// the value of key or nil if the proof shows it doesn't exist.
+func (p *Proof) Verify(root, key [hashSize]byte) ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+
+	var next [hashSize]byte
+
+	// re-create the leaf
+	switch p.Type {
+	case TypeDeadEnd:
+	case TypeShort:
+		if hasPrefix(p.Prefix, p.PrefixBits, key, int(p.Depth)) {
+			return nil, ErrSamePath
+		}
+		next = hashInternal(p.Prefix, p.PrefixBits, p.Left, p.Right)
+	case TypeCollision:
+		if p.Key == key {
+			return nil, ErrSameKey
+		}
+		next = hashLeaf(p.Key, p.Hash)
+	case TypeExists:
+		next = hashLeaf(key, blake2b.Sum256(p.Value))
+	default:
+		return nil, fmt.Errorf("unknown type %d: %w", p.Type, ErrProofEncoding)
+	}
+
+	// traverse bits right to left
+	depth := int(p.Depth)
+	for i := len(p.Nodes) - 1; i >= 0; i-- {
+		node := p.Nodes[i]
+		if depth < int(node.PrefixBits)+1 {
+			return nil, ErrNegativeDepth
+		}
+
+		depth--
+		if hasBit(key[:], depth) {
+			next = hashInternal(node.Prefix, node.PrefixBits, node.Hash, next)
+		} else {
+			next = hashInternal(node.Prefix, node.PrefixBits, next, node.Hash)
+		}
+
+		depth -= int(node.PrefixBits)
+		if !hasPrefix(node.Prefix, node.PrefixBits, key, depth) {
+			return nil, ErrPathMismatch
+		}
+	}
+
+	if depth != 0 {
+		return nil, ErrTooDeep
+	}
+	if next != root {
+		return nil, ErrHashMismatch
+	}
+
+	if p.Type != TypeExists {
+		return nil, nil
+	}
+
+	return append([]byte{}, p.Value...), nil
+}
+
+// check the proof is within the limits of the encoding
+// in case it wasn't decoded by ParseProof
+func (p *Proof) check() error {
+	validPrefix := func(prefix []byte, bits uint16) bool {
+		return bits <= maxDepth && len(prefix) >= (int(bits)+7)/8
+	}
+
+	if p.Depth > maxDepth || len(p.Nodes) > maxDepth || len(p.Value) > MaxValueSize {
+		return ErrProofEncoding
+	}
+	if p.Type == TypeShort && (p.PrefixBits == 0 || !validPrefix(p.Prefix, p.PrefixBits)) {
+		return ErrProofEncoding
+	}
+	for _, node := range p.Nodes {
+		if !validPrefix(node.Prefix, node.PrefixBits) {
+			return ErrProofEncoding
+		}
+	}
+
+	return nil
+}
+
+// VerifyName checks the proof of name against root and returns
+// the resource of the name or nil if it doesn't exist
+func VerifyName(root [hashSize]byte, name string, proof []byte) ([]byte, error) {
+	p, err := ParseProof(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.Verify(root, HashName(name))
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	return NameStateResource(value)
+}
+
+// NameStateResource the resource stored in an encoded name state
+func NameStateResource(value []byte) ([]byte, error) {
+	r := &proofReader{data: value}
+	r.bytes(int(r.u8()))
+	resource := r.bytes(int(r.u16()))
+	if r.err != nil {
+		return nil, fmt.Errorf("name state: %w", r.err)
+	}
+
+	return append([]byte{}, resource...), nil
+}
+
+func hashInternal(prefix []byte, bits uint16, left, right [hashSize]byte) [hashSize]byte {
+	h, _ := blake2b.New256(nil)
+	if bits == 0 {
+		h.Write(internalPrefix)
+	} else {
+		var size [2]byte
+		binary.LittleEndian.PutUint16(size[:], bits)
+		h.Write(skipPrefix)
+		h.Write(size[:])
+		h.Write(prefix[:(bits+7)/8])
+	}
+	h.Write(left[:])
+	h.Write(right[:])
+
+	var out [hashSize]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+func hashLeaf(key, hash [hashSize]byte) [hashSize]byte {
+	h, _ := blake2b.New256(nil)
+	h.Write(leafPrefix)
+	h.Write(key[:])
+	h.Write(hash[:])
+
+	var out [hashSize]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+func hasBit(data []byte, i int) bool {
+	return (data[i>>3]>>(7-uint(i&7)))&1 == 1
+}
+
+// hasPrefix whether the bits of key starting at depth begin with prefix
+func hasPrefix(prefix []byte, bits uint16, key [keySize]byte, depth int) bool {
+	n := int(bits)
+	if rest := maxDepth - depth; rest < n {
+		return false
+	}
+
+	for i := 0; i < n; i++ {
+		if hasBit(prefix, i) != hasBit(key[:], depth+i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type proofReader struct {
+	data []byte
+	err  error
+}
+
+func (r *proofReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = fmt.Errorf("short read: %w", ErrProofEncoding)
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *proofReader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (r *proofReader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+
+	return 0
+}
+
+// bits reads a bit length followed by the bits
+func (r *proofReader) bits() ([]byte, uint16) {
+	size := uint16(r.u8())
+	if size&0x80 != 0 {
+		size = (size&^0x80)<<8 | uint16(r.u8())
+	}
+	if r.err == nil && (size == 0 || size > maxDepth) {
+		r.err = fmt.Errorf("prefix of %d bits: %w", size, ErrProofEncoding)
+	}
+
+	return append([]byte{}, r.bytes((int(size)+7)/8)...), size
+}
+
+func writeU16(buf *bytes.Buffer, v uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeBits(buf *bytes.Buffer, prefix []byte, bits uint16) {
+	if bits >= 0x80 {
+		buf.WriteByte(byte(bits>>8) | 0x80)
+	}
+	buf.WriteByte(byte(bits))
+	buf.Write(prefix[:(bits+7)/8])
+}
@@ -0,0 +1,306 @@
+package urkel
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/blake2b"
+	"io/ioutil"
+	"testing"
+)
+
+func testKey(first byte) (key [keySize]byte) {
+	key[0] = first
+	key[31] = 0xaa
+	return
+}
+
+func testLeaf(key [keySize]byte, value []byte) [hashSize]byte {
+	return hashLeaf(key, blake2b.Sum256(value))
+}
+
+// testTree a tree with keys 0000.. and 0001.. below an internal
+// node that skips two bits and key 1... on the other side of the root
+type testTree struct {
+	a, b, c                    [keySize]byte
+	va, vb, vc                 []byte
+	leafA, leafB, leafC, inner [hashSize]byte
+	root                       [hashSize]byte
+}
+
+func newTestTree() *testTree {
+	t := &testTree{
+		a: testKey(0x00), b: testKey(0x10), c: testKey(0x80),
+		va: []byte("a"), vb: []byte("b"), vc: []byte("c"),
+	}
+	t.leafA, t.leafB, t.leafC = testLeaf(t.a, t.va), testLeaf(t.b, t.vb), testLeaf(t.c, t.vc)
+	t.inner = hashInternal([]byte{0x00}, 2, t.leafA, t.leafB)
+	t.root = hashInternal(nil, 0, t.inner, t.leafC)
+	return t
+}
+
+func TestProof_Verify(t *testing.T) {
+	tree := newTestTree()
+
+	tests := []struct {
+		name  string
+		key   [keySize]byte
+		proof *Proof
+		value []byte
+		err   error
+	}{
+		{
+			name: "exists",
+			key:  tree.a,
+			proof: &Proof{Type: TypeExists, Depth: 4, Value: tree.va, Nodes: []ProofNode{
+				{Hash: tree.leafC},
+				{Prefix: []byte{0x00}, PrefixBits: 2, Hash: tree.leafB},
+			}},
+			value: tree.va,
+		},
+		{
+			name:  "exists next to the root",
+			key:   tree.c,
+			proof: &Proof{Type: TypeExists, Depth: 1, Value: tree.vc, Nodes: []ProofNode{{Hash: tree.inner}}},
+			value: tree.vc,
+		},
+		{
+			name: "short",
+			key:  testKey(0x20),
+			proof: &Proof{Type: TypeShort, Depth: 1, Nodes: []ProofNode{{Hash: tree.leafC}},
+				Prefix: []byte{0x00}, PrefixBits: 2, Left: tree.leafA, Right: tree.leafB},
+		},
+		{
+			name:  "collision",
+			key:   testKey(0x81),
+			proof: &Proof{Type: TypeCollision, Depth: 1, Nodes: []ProofNode{{Hash: tree.inner}}, Key: tree.c, Hash: blake2b.Sum256(tree.vc)},
+		},
+		{
+			name: "short with the key in the path",
+			key:  tree.a,
+			proof: &Proof{Type: TypeShort, Depth: 1, Nodes: []ProofNode{{Hash: tree.leafC}},
+				Prefix: []byte{0x00}, PrefixBits: 2, Left: tree.leafA, Right: tree.leafB},
+			err: ErrSamePath,
+		},
+		{
+			name:  "collision with the same key",
+			key:   tree.c,
+			proof: &Proof{Type: TypeCollision, Depth: 1, Nodes: []ProofNode{{Hash: tree.inner}}, Key: tree.c, Hash: blake2b.Sum256(tree.vc)},
+			err:   ErrSameKey,
+		},
+		{
+			name:  "wrong value",
+			key:   tree.c,
+			proof: &Proof{Type: TypeExists, Depth: 1, Value: []byte("d"), Nodes: []ProofNode{{Hash: tree.inner}}},
+			err:   ErrHashMismatch,
+		},
+		{
+			name: "wrong key",
+			key:  tree.b,
+			proof: &Proof{Type: TypeExists, Depth: 4, Value: tree.va, Nodes: []ProofNode{
+				{Hash: tree.leafC},
+				{Prefix: []byte{0x00}, PrefixBits: 2, Hash: tree.leafB},
+			}},
+			err: ErrHashMismatch,
+		},
+		{
+			name: "path mismatch",
+			key:  testKey(0x30),
+			proof: &Proof{Type: TypeExists, Depth: 4, Value: tree.va, Nodes: []ProofNode{
+				{Hash: tree.leafC},
+				{Prefix: []byte{0x00}, PrefixBits: 2, Hash: tree.leafB},
+			}},
+			err: ErrPathMismatch,
+		},
+		{
+			name:  "too deep",
+			key:   tree.c,
+			proof: &Proof{Type: TypeExists, Depth: 2, Value: tree.vc, Nodes: []ProofNode{{Hash: tree.inner}}},
+			err:   ErrTooDeep,
+		},
+		{
+			name:  "negative depth",
+			key:   tree.c,
+			proof: &Proof{Type: TypeExists, Depth: 0, Value: tree.vc, Nodes: []ProofNode{{Hash: tree.inner}}},
+			err:   ErrNegativeDepth,
+		},
+		{
+			name:  "short without a prefix",
+			key:   testKey(0x20),
+			proof: &Proof{Type: TypeShort, Depth: 1, Nodes: []ProofNode{{Hash: tree.leafC}}},
+			err:   ErrProofEncoding,
+		},
+	}
+
+	for _, test := range tests {
+		// every proof must survive the wire format
+		proof, err := ParseProof(test.proof.Encode())
+		if err != nil {
+			if test.err == ErrProofEncoding {
+				continue
+			}
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		value, err := proof.Verify(tree.root, test.key)
+		if !errors.Is(err, test.err) || (err != nil && !errors.Is(err, ErrBadProof)) {
+			t.Fatalf("%s: got err = %v, want %v", test.name, err, test.err)
+		}
+		if !bytes.Equal(value, test.value) || (test.value != nil) != (value != nil) {
+			t.Fatalf("%s: got value %q, want %q", test.name, value, test.value)
+		}
+	}
+}
+
+// testVector a proof in testdata/proofs.json. Besides the empty
+// mainnet genesis tree, the proofs are of a tree holding the name
+// states of mainnet updates in resource/testdata. They were checked
+// with hsk_proof_decode and hsk_proof_verify of hnsd.
+type testVector struct {
+	Description string  `json:"description"`
+	Name        string  `json:"name"`
+	Root        string  `json:"root"`
+	Type        string  `json:"type"`
+	Proof       string  `json:"proof"`
+	Resource    *string `json:"resource"`
+}
+
+func readTestVectors(t *testing.T) []testVector {
+	data, err := ioutil.ReadFile("testdata/proofs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []testVector
+	if err = json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	return vectors
+}
+
+func TestProof_Vectors(t *testing.T) {
+	types := map[string]ProofType{
+		"deadend":   TypeDeadEnd,
+		"short":     TypeShort,
+		"collision": TypeCollision,
+		"exists":    TypeExists,
+	}
+
+	for _, v := range readTestVectors(t) {
+		var root [hashSize]byte
+		proof, _ := hex.DecodeString(v.Proof)
+		hex.Decode(root[:], []byte(v.Root))
+
+		p, err := ParseProof(proof)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Description, err)
+		}
+		if p.Type != types[v.Type] || !bytes.Equal(p.Encode(), proof) {
+			t.Fatalf("%s: got type %d, want %s", v.Description, p.Type, v.Type)
+		}
+
+		resource, err := VerifyName(root, v.Name, proof)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Description, err)
+		}
+		if v.Resource == nil {
+			if resource != nil {
+				t.Fatalf("%s: got resource %x, want none", v.Description, resource)
+			}
+			continue
+		}
+		if want, _ := hex.DecodeString(*v.Resource); !bytes.Equal(resource, want) {
+			t.Fatalf("%s: got resource %x, want %x", v.Description, resource, want)
+		}
+
+		// the proof doesn't hold for another name or root
+		if _, err = VerifyName(root, v.Name+"x", proof); !errors.Is(err, ErrBadProof) {
+			t.Fatalf("%s: got %v for another name, want %v", v.Description, err, ErrBadProof)
+		}
+		root[0] ^= 1
+		if _, err = VerifyName(root, v.Name, proof); !errors.Is(err, ErrHashMismatch) {
+			t.Fatalf("%s: got %v for another root, want %v", v.Description, err, ErrHashMismatch)
+		}
+	}
+}
+
+func TestProof_DeadEnd(t *testing.T) {
+	a := testKey(0x00)
+	leafA := testLeaf(a, []byte("a"))
+	root := hashInternal(nil, 0, leafA, [hashSize]byte{})
+
+	proof := &Proof{Type: TypeDeadEnd, Depth: 1, Nodes: []ProofNode{{Hash: leafA}}}
+	if value, err := proof.Verify(root, testKey(0x80)); err != nil || value != nil {
+		t.Fatalf("got %q, %v, want no value", value, err)
+	}
+}
+
+func TestParseProof(t *testing.T) {
+	prefix := bytes.Repeat([]byte{0xa5}, 25)
+	proof := &Proof{
+		Type:  TypeShort,
+		Depth: 7,
+		Nodes: []ProofNode{
+			{Hash: [hashSize]byte{1}},
+			{Prefix: prefix, PrefixBits: 200, Hash: [hashSize]byte{2}},
+			{Prefix: []byte{0x80}, PrefixBits: 1, Hash: [hashSize]byte{3}},
+		},
+		Prefix:     []byte{0x40},
+		PrefixBits: 3,
+		Left:       [hashSize]byte{4},
+		Right:      [hashSize]byte{5},
+	}
+
+	data := proof.Encode()
+	parsed, err := ParseProof(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.Encode(), data) || parsed.Nodes[1].PrefixBits != 200 || !bytes.Equal(parsed.Nodes[1].Prefix, prefix) {
+		t.Fatalf("got %+v after round trip", parsed)
+	}
+
+	bad := [][]byte{
+		nil,
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+		// depth over 256
+		{0x01, 0x01, 0x00, 0x00},
+		// value over the max size
+		{0x00, 0xc0, 0x00, 0x00, 0xff, 0xff},
+	}
+	for _, data := range bad {
+		if _, err := ParseProof(data); !errors.Is(err, ErrProofEncoding) {
+			t.Fatalf("got %v for %x, want %v", err, data, ErrProofEncoding)
+		}
+	}
+}
+
+func TestVerifyName(t *testing.T) {
+	resource := []byte{0x00, 0x01, 0x02}
+	value := append([]byte{6}, "beacon"...)
+	value = append(value, byte(len(resource)), 0)
+	value = append(value, resource...)
+	// the rest of the name state is ignored
+	value = append(value, 0xff, 0xff)
+
+	key := HashName("Beacon.")
+	root := testLeaf(key, value)
+
+	proof := (&Proof{Type: TypeExists, Value: value}).Encode()
+	got, err := VerifyName(root, "beacon", proof)
+	if err != nil || !bytes.Equal(got, resource) {
+		t.Fatalf("got %x, %v, want %x", got, err, resource)
+	}
+
+	absent := (&Proof{Type: TypeCollision, Key: key, Hash: blake2b.Sum256(value)}).Encode()
+	if got, err = VerifyName(root, "other", absent); err != nil || got != nil {
+		t.Fatalf("got %x, %v, want no resource", got, err)
+	}
+
+	if _, err = VerifyName([hashSize]byte{1}, "beacon", proof); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrHashMismatch)
+	}
+}
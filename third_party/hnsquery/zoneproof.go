@@ -0,0 +1,45 @@
+package hnsquery
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/imperviousinc/hnsquery/urkel"
+)
+
+// ZoneProof the Urkel proof a zone was resolved with
+type ZoneProof struct {
+	Name string
+
+	// Resource the encoded resource of the name
+	// or nil if the name doesn't exist
+	Resource []byte
+
+	// Proof the encoded Urkel proof
+	Proof []byte
+
+	// Height of the block committing to Root
+	Height uint32
+
+	// Root the name tree root the proof was verified against
+	Root [32]byte
+}
+
+// Verify checks the proof again against Root
+func (z *ZoneProof) Verify() error {
+	return z.VerifyRoot(z.Root)
+}
+
+// VerifyRoot checks that the proof shows Resource is the
+// resource of Name in the tree with the given root
+func (z *ZoneProof) VerifyRoot(root [32]byte) error {
+	resource, err := urkel.VerifyName(root, z.Name, z.Proof)
+	if err != nil {
+		return fmt.Errorf("proof for %s: %w", z.Name, err)
+	}
+
+	if (resource == nil) != (z.Resource == nil) || !bytes.Equal(resource, z.Resource) {
+		return fmt.Errorf("resource of %s doesn't match proof: %w", z.Name, urkel.ErrBadProof)
+	}
+
+	return nil
+}
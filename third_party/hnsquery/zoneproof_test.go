@@ -0,0 +1,94 @@
+package hnsquery
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/imperviousinc/hnsquery/urkel"
+	"golang.org/x/crypto/blake2b"
+	"io/ioutil"
+	"testing"
+)
+
+func TestZoneProof_Verify(t *testing.T) {
+	resource := []byte{0x00, 0x05}
+	value := append([]byte{6}, "beacon"...)
+	value = append(value, byte(len(resource)), 0)
+	value = append(value, resource...)
+
+	// a tree with a single leaf
+	key := urkel.HashName("beacon")
+	valueHash := blake2b.Sum256(value)
+	root := blake2b.Sum256(append(append([]byte{0x00}, key[:]...), valueHash[:]...))
+
+	proof := &ZoneProof{
+		Name:     "beacon",
+		Resource: resource,
+		Proof:    (&urkel.Proof{Type: urkel.TypeExists, Value: value}).Encode(),
+		Height:   72,
+		Root:     root,
+	}
+	if err := proof.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proof.VerifyRoot([32]byte{1}); !errors.Is(err, urkel.ErrHashMismatch) {
+		t.Fatalf("got %v, want %v", err, urkel.ErrHashMismatch)
+	}
+
+	proof.Resource = []byte{0x00, 0x06}
+	if err := proof.Verify(); !errors.Is(err, urkel.ErrBadProof) {
+		t.Fatalf("got %v, want %v", err, urkel.ErrBadProof)
+	}
+
+	// a proof of absence doesn't prove a resource
+	proof.Name = "other"
+	proof.Proof = (&urkel.Proof{Type: urkel.TypeCollision, Key: key, Hash: valueHash}).Encode()
+	if err := proof.Verify(); !errors.Is(err, urkel.ErrBadProof) {
+		t.Fatalf("got %v, want %v", err, urkel.ErrBadProof)
+	}
+	proof.Resource = nil
+	if err := proof.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestZoneProof_Vectors proofs checked with hnsd, see urkel/testdata
+func TestZoneProof_Vectors(t *testing.T) {
+	data, err := ioutil.ReadFile("urkel/testdata/proofs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []struct {
+		Name     string  `json:"name"`
+		Root     string  `json:"root"`
+		Proof    string  `json:"proof"`
+		Resource *string `json:"resource"`
+	}
+	if err = json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		proof := &ZoneProof{Name: v.Name}
+		proof.Proof, _ = hex.DecodeString(v.Proof)
+		hex.Decode(proof.Root[:], []byte(v.Root))
+		if v.Resource != nil {
+			proof.Resource, _ = hex.DecodeString(*v.Resource)
+		}
+
+		if err = proof.Verify(); err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+
+		// nor does it for any other resource
+		proof.Resource = []byte{}
+		if v.Resource == nil {
+			proof.Resource = []byte{0x00}
+		}
+		if err = proof.Verify(); !errors.Is(err, urkel.ErrBadProof) {
+			t.Fatalf("%s: got %v, want %v", v.Name, err, urkel.ErrBadProof)
+		}
+	}
+}